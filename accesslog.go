@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// requestIDHeader carries the request ID in both directions: a proxy in
+// front may set it, and every response echoes it
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// requestID is the ID accessLog gave r, or "" outside a request
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns 16 random hex characters
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts an upstream ID only if it is short and plain, so
+// it can't smuggle anything into the logs
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// quietPaths are polled by probes and scrapers; their access lines are
+// only logged at debug level
+var quietPaths = map[string]bool{"/healthz": true, "/readyz": true, "/metrics": true}
+
+// accessLog gives each request an ID, which requestLog adds to anything
+// logged while serving it and which is returned in the X-Request-ID
+// header, then logs one line per request once it is done. Plain-text
+// server errors (http.Error) also get the ID appended, so a user can
+// quote it when reporting the problem.
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		if rec.code >= 500 && strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+			fmt.Fprintf(w, "Request ID: %s\n", id)
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case rec.code >= 500:
+			level = slog.LevelError
+		case quietPaths[r.URL.Path]:
+			level = slog.LevelDebug
+		}
+		requestLog(r).Log(r.Context(), level, "request",
+			"path", r.URL.Path,
+			"status", rec.code,
+			"duration", time.Since(start).Round(time.Microsecond).String(),
+			"remote", r.RemoteAddr)
+	})
+}
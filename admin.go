@@ -0,0 +1,586 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adminCookie holds the random session ID of a signed-in admin
+const adminCookie = "admin"
+
+// adminSessionTTL is how long an admin stays signed in
+const adminSessionTTL = 12 * time.Hour
+
+// Only a hash of each session ID is stored, as with share link tokens, so
+// a copy of the database can't be used to sign in
+const adminSessionsSchema = `
+	CREATE TABLE IF NOT EXISTS admin_sessions (
+		id_hash TEXT PRIMARY KEY,
+		expires_at DATETIME NOT NULL
+	);
+`
+
+// An address that sends adminSignInLimit wrong tokens within
+// adminSignInWindow can't sign in again until the window has passed
+const (
+	adminSignInLimit  = 5
+	adminSignInWindow = 15 * time.Minute
+)
+
+// signInFailures counts wrong tokens per client address. It lives in this
+// process only: with several instances each allows adminSignInLimit, which
+// still leaves a 16+ character token out of reach. Behind a proxy every
+// client shares its address, so a lockout there locks out everyone.
+var signInFailures = struct {
+	sync.Mutex
+	byAddr map[string]failedSignIns
+}{byAddr: map[string]failedSignIns{}}
+
+// failedSignIns are the wrong tokens from one address since first
+type failedSignIns struct {
+	count int
+	first time.Time
+}
+
+// clientAddr is the address a request came from, without its port
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// signInLockedFor returns how long addr must still wait before it may try
+// another token, or 0
+func signInLockedFor(addr string, now time.Time) time.Duration {
+	signInFailures.Lock()
+	defer signInFailures.Unlock()
+	f, ok := signInFailures.byAddr[addr]
+	if !ok || f.count < adminSignInLimit {
+		return 0
+	}
+	return max(0, f.first.Add(adminSignInWindow).Sub(now))
+}
+
+// recordSignIn counts a wrong token from addr, or forgets addr's failures
+// after it signs in
+func recordSignIn(addr string, ok bool, now time.Time) {
+	signInFailures.Lock()
+	defer signInFailures.Unlock()
+	if ok {
+		delete(signInFailures.byAddr, addr)
+		return
+	}
+	for a, f := range signInFailures.byAddr {
+		if now.Sub(f.first) >= adminSignInWindow {
+			delete(signInFailures.byAddr, a)
+		}
+	}
+	f := signInFailures.byAddr[addr]
+	if f.count == 0 {
+		f.first = now
+	}
+	f.count++
+	signInFailures.byAddr[addr] = f
+}
+
+// startedAt is when the process started, for the uptime on /admin
+var startedAt = time.Now()
+
+// adminToken is ADMIN_TOKEN; empty turns /admin off. There are no user
+// accounts, so the one token is the admin role and /admin has no users to
+// manage; the only cohorts are the kiosk locations, set in KIOSK_LOCATIONS.
+var adminToken string
+
+// loadAdminConfig reads ADMIN_TOKEN, which must be at least 16 characters
+// when set
+func loadAdminConfig() (string, error) {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token != "" && len(token) < 16 {
+		return "", fmt.Errorf("admin: ADMIN_TOKEN must be at least 16 characters")
+	}
+	return token, nil
+}
+
+// newAdminSession stores a fresh session and returns its ID for the cookie
+func newAdminSession() (string, error) {
+	id, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	if _, err := db.Exec(`DELETE FROM admin_sessions WHERE expires_at < ?`, now); err != nil {
+		return "", err
+	}
+	_, err = db.Exec(`INSERT INTO admin_sessions (id_hash, expires_at) VALUES (?, ?)`,
+		hashShareToken(id), now.Add(adminSessionTTL))
+	return id, err
+}
+
+// validAdminSession reports whether id is a stored session that has not
+// expired
+func validAdminSession(id string) bool {
+	var expires time.Time
+	err := db.QueryRow(`SELECT expires_at FROM admin_sessions WHERE id_hash = ?`, hashShareToken(id)).Scan(&expires)
+	return err == nil && time.Now().Before(expires)
+}
+
+// endAdminSession deletes a session, so its cookie stops working even if
+// a copy of it is kept
+func endAdminSession(id string) error {
+	_, err := db.Exec(`DELETE FROM admin_sessions WHERE id_hash = ?`, hashShareToken(id))
+	return err
+}
+
+// isAdmin reports whether the request carries the admin cookie or an
+// "Authorization: Bearer <ADMIN_TOKEN>" header. Bearer tokens count
+// towards the same per-address limit as the sign-in form.
+func isAdmin(r *http.Request) bool {
+	if adminToken == "" {
+		return false
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		addr, now := clientAddr(r), time.Now()
+		if signInLockedFor(addr, now) > 0 {
+			return false
+		}
+		ok := subtle.ConstantTimeCompare([]byte(bearer), []byte(adminToken)) == 1
+		recordSignIn(addr, ok, now)
+		return ok
+	}
+	c, err := r.Cookie(adminCookie)
+	return err == nil && validAdminSession(c.Value)
+}
+
+// adminOnly wraps an admin handler: 404 while ADMIN_TOKEN is unset, so the
+// area is invisible, 429 for an address locked out by wrong tokens and 401
+// for anyone else not signed in
+func adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		if !isAdmin(r) {
+			if wait := signInLockedFor(clientAddr(r), time.Now()); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+				http.Error(w, "Too many wrong tokens from this address; try again later", http.StatusTooManyRequests)
+				return
+			}
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tableCount is the number of rows in one table
+type tableCount struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+// SystemHealth is the payload of /api/admin/health
+type SystemHealth struct {
+	Status     string       `json:"status"`
+	Uptime     string       `json:"uptime"`
+	GoVersion  string       `json:"go_version"`
+	Goroutines int          `json:"goroutines"`
+	MemoryMB   float64      `json:"memory_mb"`
+	Demo       bool         `json:"demo"`
+	DBPing     string       `json:"db_ping"`
+	DBError    string       `json:"db_error,omitempty"`
+	DBSizeMB   float64      `json:"db_size_mb"`
+	Tables     []tableCount `json:"tables"`
+	Jobs       []JobStatus  `json:"jobs"`
+	// Queue is the number of queued jobs waiting or running; DeadJobs are
+	// the ones that ran out of attempts
+	Queue    int         `json:"queue"`
+	DeadJobs []QueuedJob `json:"dead_jobs"`
+}
+
+// systemHealth gathers the figures shown on /admin. A database failure is
+// reported in the result rather than returned, so the page still renders.
+func systemHealth() SystemHealth {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	h := SystemHealth{
+		Status:     "ok",
+		Uptime:     time.Since(startedAt).Round(time.Second).String(),
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		MemoryMB:   roundTo(float64(mem.Alloc)/(1<<20), 1),
+		Demo:       demoMode,
+		Tables:     []tableCount{},
+		Jobs:       scheduler.Jobs(),
+		DeadJobs:   []QueuedJob{},
+	}
+
+	start := time.Now()
+	if err := db.Ping(); err != nil {
+		h.Status, h.DBError = "degraded", err.Error()
+		return h
+	}
+	h.DBPing = time.Since(start).String()
+
+	var pages, pageSize int64
+	db.QueryRow(`PRAGMA page_count`).Scan(&pages)
+	db.QueryRow(`PRAGMA page_size`).Scan(&pageSize)
+	h.DBSizeMB = roundTo(float64(pages*pageSize)/(1<<20), 2)
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		h.Status, h.DBError = "degraded", err.Error()
+		return h
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+	for _, name := range names {
+		t := tableCount{Name: name}
+		// name comes from sqlite_master, not the request
+		db.QueryRow(`SELECT COUNT(*) FROM "` + name + `"`).Scan(&t.Rows)
+		h.Tables = append(h.Tables, t)
+	}
+
+	h.Queue, _ = queueBacklog()
+	if dead, err := deadJobs(); err == nil {
+		h.DeadJobs = dead
+	}
+
+	for _, j := range h.Jobs {
+		if j.LastError != "" {
+			h.Status = "degraded"
+		}
+	}
+	return h
+}
+
+// scoreRule is one setting of the scoring formula as /admin edits it.
+// Key is the field name, the same as in the config file.
+type scoreRule struct {
+	Key   string
+	Input string
+	Unit  string
+	Value float64
+}
+
+// scoreRules describe the running formula as burnoutScore and
+// recoveryCredit apply it
+func scoreRules() []scoreRule {
+	s := currentScoring()
+	return []scoreRule{
+		{"deadline_weight", "Deadlines", "points each", s.DeadlineWeight},
+		{"stress_weight", "Stress (1-5)", "points per point", s.StressWeight},
+		{"sleep_target", "Sleep target", "hours", s.SleepTarget},
+		{"sleep_weight", "Sleep", "points per hour under the target (taken off per hour over)", s.SleepWeight},
+		{"study_weight", "Study", "points per hour", s.StudyWeight},
+		{"exercise_recovery", "Exercise", "points off", s.ExerciseRecovery},
+		{"habit_recovery_bonus", "Any recovery habit done", "points off", s.HabitRecoveryBonus},
+		{"healthy_max", "Healthy up to", "score", s.HealthyMax},
+		{"at_risk_max", "At risk up to", "score", s.AtRiskMax},
+		{"high_risk_max", "High risk up to", "score; severe above", s.HighRiskMax},
+	}
+}
+
+// handleAdminSetScoring saves the formula edited on /admin, or with
+// reset=1 goes back to the configured one. It applies to check-ins from
+// now on; stored scores are not recalculated.
+func handleAdminSetScoring(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("reset") == "1" {
+		if err := saveScoring(nil); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin?notice=Scoring+formula+reset", http.StatusSeeOther)
+		return
+	}
+	s := currentScoring()
+	for key, field := range map[string]*float64{
+		"deadline_weight": &s.DeadlineWeight, "stress_weight": &s.StressWeight, "sleep_target": &s.SleepTarget,
+		"sleep_weight": &s.SleepWeight, "study_weight": &s.StudyWeight,
+		"exercise_recovery": &s.ExerciseRecovery, "habit_recovery_bonus": &s.HabitRecoveryBonus,
+		"healthy_max": &s.HealthyMax, "at_risk_max": &s.AtRiskMax, "high_risk_max": &s.HighRiskMax,
+	} {
+		v, err := strconv.ParseFloat(strings.TrimSpace(r.FormValue(key)), 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("scoring: %s must be a number", key), http.StatusBadRequest)
+			return
+		}
+		*field = v
+	}
+	if err := s.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := saveScoring(&s); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin?notice=Scoring+formula+saved", http.StatusSeeOther)
+}
+
+// advicePart is one body or action of the advice as /admin edits it
+type advicePart struct {
+	Key   string
+	Label string
+	Text  string
+}
+
+// adviceParts lists the advice's bodies and actions in the order
+// generateAIAdvice considers them
+func adviceParts(t adviceTemplates) []advicePart {
+	return []advicePart{
+		{"severe", "Body when severe", t.Severe},
+		{"high_risk", "Body when high risk", t.HighRisk},
+		{"at_risk", "Body when at risk", t.AtRisk},
+		{"healthy", "Body when healthy", t.Healthy},
+		{"low_sleep", "Action for under 5 hours of sleep", t.LowSleep},
+		{"high_stress", "Action for stress above 3", t.HighStress},
+		{"many_deadlines", "Action for more than 4 deadlines", t.ManyDeadlines},
+		{"otherwise", "Action otherwise", t.Otherwise},
+	}
+}
+
+// handleAdminSetAdvice saves the advice edited on /admin, or with reset=1
+// goes back to the built-in advice
+func handleAdminSetAdvice(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("reset") == "1" {
+		if err := saveAdvice(nil); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/admin?notice=Advice+reset", http.StatusSeeOther)
+		return
+	}
+	var t adviceTemplates
+	for _, line := range strings.Split(r.FormValue("intros"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			t.Intros = append(t.Intros, line)
+		}
+	}
+	for key, field := range t.fields() {
+		*field = strings.TrimSpace(r.FormValue(key))
+	}
+	if err := t.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := saveAdvice(&t); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin?notice=Advice+saved", http.StatusSeeOther)
+}
+
+// adminPage is the data behind templates/admin.html
+type adminPage struct {
+	SignedIn bool
+	Error    string
+	Notice   string
+	Health   SystemHealth
+	Rules    []scoreRule
+	// RulesSaved is set when the formula comes from /admin rather than
+	// the config file
+	RulesSaved bool
+	// Advice is in force; AdviceSaved is set when it comes from /admin
+	// rather than the built-in defaults
+	Advice      adviceTemplates
+	AdviceParts []advicePart
+	AdviceSaved bool
+	Kiosk       kioskConfig
+	// KioskCheckins counts each location's check-ins by code
+	KioskCheckins map[string]int
+	ShareLinks    []ShareLink
+	Webhooks      []Webhook
+	// WebhookEvents are the events a webhook can subscribe to
+	WebhookEvents []string
+	Crisis        crisisDirectory
+	Flags         []FlagStatus
+	Maintenance   maintenanceStatus
+}
+
+// handleAdmin renders /admin. Signed out it shows the token form, which
+// POSTs back here to sign in; too many wrong tokens from one address get a
+// 429 until adminSignInWindow has passed.
+func handleAdmin(w http.ResponseWriter, r *http.Request) {
+	if adminToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+	tmpl, err := loadTemplate("admin.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		addr, now := clientAddr(r), time.Now()
+		if wait := signInLockedFor(addr, now); wait > 0 {
+			retry := "in a minute"
+			if minutes := int(wait/time.Minute) + 1; minutes > 1 {
+				retry = fmt.Sprintf("in %d minutes", minutes)
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait/time.Second)+1))
+			w.WriteHeader(http.StatusTooManyRequests)
+			tmpl.Execute(w, adminPage{Error: "Too many wrong tokens from this address. Try again " + retry + "."})
+			return
+		}
+		token := r.FormValue("token")
+		ok := subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1
+		recordSignIn(addr, ok, now)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			tmpl.Execute(w, adminPage{Error: "That token is not right."})
+			return
+		}
+		session, err := newAdminSession()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: adminCookie, Value: session, Path: "/", MaxAge: int(adminSessionTTL / time.Second),
+			HttpOnly: true, SameSite: http.SameSiteStrictMode, Secure: r.TLS != nil})
+		http.Redirect(w, r, "/admin", http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isAdmin(r) {
+		tmpl.Execute(w, adminPage{})
+		return
+	}
+	links, err := listShareLinks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hooks, err := listWebhooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	kioskCheckins, err := kioskCheckinCounts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, rulesSaved := loadScoring()
+	advice, adviceSaved := loadAdvice()
+	tmpl.Execute(w, adminPage{
+		SignedIn:      true,
+		Notice:        r.URL.Query().Get("notice"),
+		Health:        systemHealth(),
+		Rules:         scoreRules(),
+		RulesSaved:    rulesSaved,
+		Advice:        advice,
+		AdviceParts:   adviceParts(advice),
+		AdviceSaved:   adviceSaved,
+		Kiosk:         kiosk,
+		KioskCheckins: kioskCheckins,
+		ShareLinks:    links,
+		Webhooks:      hooks,
+		WebhookEvents: webhookEvents,
+		Crisis:        crisis,
+		Flags:         flagStatuses(),
+		Maintenance:   maintenance.current(),
+	})
+}
+
+// handleAdminLogout ends the session and clears the admin cookie
+func handleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(adminCookie); err == nil {
+		if err := endAdminSession(c.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: adminCookie, Value: "", Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// handleAdminHealth returns the system health as JSON, e.g. for a probe
+// holding the token
+func handleAdminHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, systemHealth())
+}
+
+// handleAdminRunJob starts a scheduler job now
+func handleAdminRunJob(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !scheduler.RunNow(name) {
+		http.Error(w, "No such job, or it is already running", http.StatusConflict)
+		return
+	}
+	http.Redirect(w, r, "/admin?"+url.Values{"notice": {name + " started"}}.Encode(), http.StatusSeeOther)
+}
+
+// handleAdminRevokeShareLink revokes a share link from the admin page
+func handleAdminRevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ok, err := revokeShareLink(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Share link not found or already revoked", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/admin?notice=Share+link+revoked", http.StatusSeeOther)
+}
+
+// handleAdminAddWebhook adds a webhook from the admin page
+func handleAdminAddWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := addWebhook(strings.TrimSpace(r.PostForm.Get("url")), r.PostForm["event"], r.PostForm.Get("secret")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/admin?notice=Webhook+added", http.StatusSeeOther)
+}
+
+// handleAdminDeleteWebhook removes a webhook from the admin page
+func handleAdminDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ok, err := deleteWebhook(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/admin?notice=Webhook+deleted", http.StatusSeeOther)
+}
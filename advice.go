@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// adviceTemplates are the sentences generateAIAdvice puts together: an
+// opening picked at random, a body for the level and an action for the
+// inputs. Bodies and actions are text/template, with the check-in's
+// .Sleep, .Deadlines, .Stress and .Score.
+type adviceTemplates struct {
+	Intros   []string `json:"intros"`
+	Severe   string   `json:"severe"`
+	HighRisk string   `json:"high_risk"`
+	AtRisk   string   `json:"at_risk"`
+	Healthy  string   `json:"healthy"`
+	// The first action that applies is used: under 5 hours of sleep,
+	// stress above 3, more than 4 deadlines, anything else
+	LowSleep      string `json:"low_sleep"`
+	HighStress    string `json:"high_stress"`
+	ManyDeadlines string `json:"many_deadlines"`
+	Otherwise     string `json:"otherwise"`
+}
+
+// defaultAdvice is the advice the app has always given
+var defaultAdvice = adviceTemplates{
+	Intros: []string{
+		"Based on your current workload patterns,",
+		"Analyzing your physiological and academic inputs,",
+		"Correlating your sleep data with stress levels,",
+		"My assessment of your current state suggests",
+	},
+	Severe:        "your system is in critical overdrive. The combination of high stress and sleep deprivation is unsustainable. Your cognitive performance is likely degrading.",
+	HighRisk:      "you are navigating a high-pressure zone. Managing {{.Deadlines}} deadlines with elevated stress is depleting your reserves faster than you can recover.",
+	AtRisk:        "you are maintaining functionality but showing early signs of friction. Your sleep schedule needs slight optimization to buffer against upcoming deadlines.",
+	Healthy:       "you have achieved an optimal balance between academic rigor and personal recovery. Your resilience metrics are currently peak.",
+	LowSleep:      "Immediate Priority: Disconnect 1 hour before bed to reclaim REM cycles.",
+	HighStress:    "Suggestion: Implement the Pomodoro technique (25/5) to fragment stress accumulation.",
+	ManyDeadlines: "Strategy: Triage your deadlines; ask for extensions on low-priority tasks.",
+	Otherwise:     "Recommendation: Maintain current routine but monitor hydration levels.",
+}
+
+// adviceInput is what the body and action templates can refer to
+type adviceInput struct {
+	Sleep     float64
+	Deadlines int
+	Stress    int
+	Score     float64
+}
+
+// fields lists the body and action templates by their JSON name
+func (t *adviceTemplates) fields() map[string]*string {
+	return map[string]*string{
+		"severe": &t.Severe, "high_risk": &t.HighRisk, "at_risk": &t.AtRisk, "healthy": &t.Healthy,
+		"low_sleep": &t.LowSleep, "high_stress": &t.HighStress, "many_deadlines": &t.ManyDeadlines, "otherwise": &t.Otherwise,
+	}
+}
+
+// IntroLines is the openings one per line, as the /admin form edits them
+func (t adviceTemplates) IntroLines() string {
+	return strings.Join(t.Intros, "\n")
+}
+
+// validate rejects empty advice and templates that don't run on a sample
+// check-in
+func (t adviceTemplates) validate() error {
+	if len(t.Intros) == 0 {
+		return fmt.Errorf("advice: give at least one opening")
+	}
+	for _, intro := range t.Intros {
+		if strings.TrimSpace(intro) == "" {
+			return fmt.Errorf("advice: openings must not be blank")
+		}
+	}
+	for name, text := range t.fields() {
+		if strings.TrimSpace(*text) == "" {
+			return fmt.Errorf("advice: %s must not be empty", name)
+		}
+		if _, err := renderAdvice(*text, adviceInput{Sleep: 6, Deadlines: 3, Stress: 4, Score: 65}); err != nil {
+			return fmt.Errorf("advice: %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// renderAdvice runs one body or action template
+func renderAdvice(text string, in adviceInput) (string, error) {
+	tmpl, err := template.New("advice").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, in); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// adviceKey is the settings row holding advice edited on /admin, as JSON
+const adviceKey = "advice"
+
+// adviceCache holds the advice in force until the next write, like
+// formulaCache
+var adviceCache struct {
+	sync.Mutex
+	generation uint64
+	loaded     bool
+	templates  adviceTemplates
+	saved      bool
+}
+
+// loadAdvice returns the advice in force and whether it was saved on
+// /admin. While the database can't be read it keeps the last known advice.
+func loadAdvice() (adviceTemplates, bool) {
+	generation := state.Generation()
+	adviceCache.Lock()
+	defer adviceCache.Unlock()
+	if adviceCache.loaded && adviceCache.generation == generation {
+		return adviceCache.templates, adviceCache.saved
+	}
+	templates, saved := defaultAdvice, false
+	var value string
+	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, adviceKey).Scan(&value)
+	switch {
+	case err == nil:
+		var t adviceTemplates
+		if json.Unmarshal([]byte(value), &t) == nil && t.validate() == nil {
+			templates, saved = t, true
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		if adviceCache.loaded {
+			return adviceCache.templates, adviceCache.saved
+		}
+		return defaultAdvice, false
+	}
+	adviceCache.templates, adviceCache.saved = templates, saved
+	adviceCache.generation, adviceCache.loaded = generation, true
+	return templates, saved
+}
+
+// saveAdvice stores advice edited on /admin; nil goes back to the
+// defaults. Advice already given stays as it was.
+func saveAdvice(t *adviceTemplates) error {
+	if t == nil {
+		_, err := db.Exec(`DELETE FROM settings WHERE key = ?`, adviceKey)
+		return err
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, adviceKey, string(value), time.Now().UTC())
+	return err
+}
+
+// advisor is everything advice depends on, loaded once so a caller inside
+// a transaction, or writing many rows, doesn't read it again for each
+type advisor struct {
+	scoring   scoringConfig
+	templates adviceTemplates
+}
+
+// currentAdvisor returns the formula and advice in force
+func currentAdvisor() advisor {
+	templates, _ := loadAdvice()
+	return advisor{scoring: currentScoring(), templates: templates}
+}
+
+// advise writes the advice for one check-in
+func (a advisor) advise(sleep float64, deadlines, stress int, score float64) string {
+	// Simple rule-based generation to "simulate" AI
+	t := a.templates
+	selectedIntro := t.Intros[rand.Intn(len(t.Intros))]
+
+	var body string
+	switch a.scoring.level(score) {
+	case levelSevere:
+		body = t.Severe
+	case levelHighRisk:
+		body = t.HighRisk
+	case levelAtRisk:
+		body = t.AtRisk
+	default:
+		body = t.Healthy
+	}
+
+	var action string
+	if sleep < 5 {
+		action = t.LowSleep
+	} else if stress > 3 {
+		action = t.HighStress
+	} else if deadlines > 4 {
+		action = t.ManyDeadlines
+	} else {
+		action = t.Otherwise
+	}
+
+	in := adviceInput{Sleep: sleep, Deadlines: deadlines, Stress: stress, Score: score}
+	if rendered, err := renderAdvice(body, in); err == nil {
+		body = rendered
+	}
+	if rendered, err := renderAdvice(action, in); err == nil {
+		action = rendered
+	}
+	return fmt.Sprintf("%s %s %s", selectedIntro, body, action)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// trendWindow is the period the plain-language direction indicator covers
+const trendWindow = 14 * 24 * time.Hour
+
+// trendThreshold is the projected change over trendWindow (in score points)
+// below which the trend is reported as stable
+const trendThreshold = 5.0
+
+// TrendLine describes a least-squares fit through the score history
+type TrendLine struct {
+	SlopePerDay float64   `json:"slope_per_day"`
+	Intercept   float64   `json:"intercept"`
+	Points      []float64 `json:"points"`
+	Direction   string    `json:"direction"`
+	Summary     string    `json:"summary"`
+	SampleSize  int       `json:"sample_size"`
+}
+
+// linearRegression fits y = slope*x + intercept, reporting false when the
+// input is too small or degenerate to fit
+func linearRegression(xs, ys []float64) (slope, intercept float64, ok bool) {
+	n := float64(len(xs))
+	if len(xs) < 2 || len(xs) != len(ys) {
+		return 0, 0, false
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, 0, false
+	}
+	slope = (n*sumXY - sumX*sumY) / denom
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept, true
+}
+
+// daysSince converts timestamps to fractional days relative to origin
+func daysSince(origin time.Time, points []scorePoint) (xs, ys []float64) {
+	for _, p := range points {
+		xs = append(xs, p.At.Sub(origin).Hours()/24)
+		ys = append(ys, p.Score)
+	}
+	return xs, ys
+}
+
+// buildTrendLine fits the plotted points for the chart overlay and derives
+// the direction indicator from the last two weeks of history
+func buildTrendLine(loc localizer, points, history []scorePoint) *TrendLine {
+	if len(points) < 2 {
+		return nil
+	}
+
+	origin := points[0].At
+	xs, ys := daysSince(origin, points)
+	slope, intercept, ok := linearRegression(xs, ys)
+	if !ok {
+		return nil
+	}
+
+	trend := &TrendLine{
+		SlopePerDay: roundTo(slope, 2),
+		Intercept:   roundTo(intercept, 2),
+		SampleSize:  len(points),
+	}
+	for _, x := range xs {
+		trend.Points = append(trend.Points, roundTo(math.Max(0, math.Min(100, slope*x+intercept)), 1))
+	}
+
+	// Direction uses a fixed two-week window so the wording means the same thing
+	// regardless of the chart granularity
+	cutoff := points[len(points)-1].At.Add(-trendWindow)
+	var recent []scorePoint
+	for _, p := range history {
+		if p.At.After(cutoff) {
+			recent = append(recent, p)
+		}
+	}
+	trend.Direction, trend.Summary = describeTrend(loc, recent)
+	return trend
+}
+
+// describeTrend turns the two-week slope into a direction and a sentence
+func describeTrend(loc localizer, recent []scorePoint) (direction, summary string) {
+	if len(recent) < 2 {
+		return "unknown", loc.T("trend.unknown")
+	}
+	xs, ys := daysSince(recent[0].At, recent)
+	slope, _, ok := linearRegression(xs, ys)
+	if !ok {
+		return "unknown", loc.T("trend.unknown")
+	}
+
+	change := slope * trendWindow.Hours() / 24
+	switch {
+	case change <= -trendThreshold:
+		return "improving", loc.T("trend.improving", "Points", fmt.Sprintf("%.0f", -change))
+	case change >= trendThreshold:
+		return "worsening", loc.T("trend.worsening", "Points", fmt.Sprintf("%.0f", change))
+	}
+	return "stable", loc.T("trend.stable")
+}
+
+// pearson returns the Pearson correlation coefficient of xs and ys, reporting
+// false when either series has no variance
+func pearson(xs, ys []float64) (float64, bool) {
+	n := len(xs)
+	if n < 2 || n != len(ys) {
+		return 0, false
+	}
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var cov, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
+
+// spearman returns the Spearman rank correlation of xs and ys
+func spearman(xs, ys []float64) (float64, bool) {
+	return pearson(ranks(xs), ranks(ys))
+}
+
+// ranks assigns 1-based ranks to values, averaging ties
+func ranks(values []float64) []float64 {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return values[idx[a]] < values[idx[b]] })
+
+	out := make([]float64, len(values))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && values[idx[j+1]] == values[idx[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			out[idx[k]] = rank
+		}
+		i = j + 1
+	}
+	return out
+}
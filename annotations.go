@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const annotationsSchema = `
+	CREATE TABLE IF NOT EXISTS annotations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		date TEXT NOT NULL,
+		label TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// maxAnnotationLabel keeps labels short enough to draw on a chart
+const maxAnnotationLabel = 80
+
+// Annotation is a dated life event ("started internship") shown on charts
+type Annotation struct {
+	ID    int64  `json:"id"`
+	Date  string `json:"date"`
+	Label string `json:"label"`
+}
+
+// ChartAnnotation places an annotation on a chart by label index
+type ChartAnnotation struct {
+	Label string `json:"label"`
+	Date  string `json:"date"`
+	Index int    `json:"index"`
+}
+
+// listAnnotations returns all annotations ordered by date
+func listAnnotations() ([]Annotation, error) {
+	rows, err := db.Query(`SELECT id, date, label FROM annotations ORDER BY date ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	annotations := []Annotation{}
+	for rows.Next() {
+		var a Annotation
+		if err := rows.Scan(&a.ID, &a.Date, &a.Label); err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, a)
+	}
+	return annotations, rows.Err()
+}
+
+// chartAnnotations attaches each annotation to the first plotted point on or
+// after its date; annotations outside the plotted range are dropped
+func chartAnnotations(annotations []Annotation, points []scorePoint) []ChartAnnotation {
+	var out []ChartAnnotation
+	if len(points) == 0 {
+		return out
+	}
+	firstDay := bucketStart(points[0].At, granularityDay)
+	for _, a := range annotations {
+		day, err := parseDay(a.Date)
+		if err != nil || day.Before(firstDay) {
+			continue
+		}
+		for i, p := range points {
+			if !p.At.Before(day) || bucketStart(p.At, granularityDay).Equal(day) {
+				out = append(out, ChartAnnotation{Label: a.Label, Date: a.Date, Index: i})
+				break
+			}
+		}
+	}
+	return out
+}
+
+// handleAnnotations lists (GET) or creates (POST) annotations
+func handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		annotations, err := listAnnotations()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, annotations)
+
+	case "POST":
+		var a Annotation
+		if err := decodeJSON(r, &a); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		a.Label = strings.TrimSpace(a.Label)
+		if a.Label == "" || len(a.Label) > maxAnnotationLabel {
+			http.Error(w, "label is required (max 80 characters)", http.StatusBadRequest)
+			return
+		}
+		if _, err := time.Parse("2006-01-02", a.Date); err != nil {
+			http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`INSERT INTO annotations (date, label) VALUES (?, ?)`, a.Date, a.Label)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		a.ID, _ = res.LastInsertId()
+		writeJSON(w, http.StatusCreated, a)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAnnotation deletes a single annotation
+func handleAnnotation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := db.Exec(`DELETE FROM annotations WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Annotation not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// exportPolicy says how entries are anonymised before any export writes
+// them. It is parsed from the same query parameters on every format:
+//
+//	drop=journal,advice   blank these fields (a dropped id becomes 0)
+//	hash=id,journal       replace them with stable pseudonyms
+//	jitter=6h             shift each timestamp by up to ±6h
+//	coarse=1              day-level timestamps, sleep and study to the
+//	                      half hour, scores to the nearest 5
+//	anonymize=1           shorthand for drop=journal,advice&hash=id&jitter=3h
+type exportPolicy struct {
+	Drop   []string
+	Hash   []string
+	Jitter time.Duration
+	Coarse bool
+}
+
+// anonymizableFields are the identifying fields drop and hash accept
+var anonymizableFields = []string{"id", "journal", "advice"}
+
+// maxExportJitter keeps jittered timestamps within a few days of the truth
+const maxExportJitter = 72 * time.Hour
+
+// anonSalt keys the pseudonyms and jitter. Set EXPORT_ANON_SALT to keep
+// pseudonyms stable across restarts so separate exports can be joined;
+// otherwise they only match within one run of the server.
+var anonSalt = func() []byte {
+	if s := os.Getenv("EXPORT_ANON_SALT"); s != "" {
+		return []byte(s)
+	}
+	salt := make([]byte, 32)
+	rand.Read(salt)
+	return salt
+}()
+
+// parseExportPolicy reads the anonymisation parameters from q
+func parseExportPolicy(q url.Values) (exportPolicy, error) {
+	var p exportPolicy
+	if q.Get("anonymize") == "1" {
+		p = exportPolicy{Drop: []string{"journal", "advice"}, Hash: []string{"id"}, Jitter: 3 * time.Hour}
+	}
+	fields := func(name string) ([]string, error) {
+		var list []string
+		for _, f := range strings.Split(q.Get(name), ",") {
+			f = strings.TrimSpace(f)
+			if f == "" {
+				continue
+			}
+			if !slices.Contains(anonymizableFields, f) {
+				return nil, fmt.Errorf("%s: unknown field %q (use %s)", name, f, strings.Join(anonymizableFields, ", "))
+			}
+			list = append(list, f)
+		}
+		return list, nil
+	}
+	drop, err := fields("drop")
+	if err != nil {
+		return p, err
+	}
+	hash, err := fields("hash")
+	if err != nil {
+		return p, err
+	}
+	p.Drop = append(p.Drop, drop...)
+	p.Hash = append(p.Hash, hash...)
+	if v := q.Get("jitter"); v != "" {
+		if p.Jitter, err = time.ParseDuration(v); err != nil || p.Jitter < 0 || p.Jitter > maxExportJitter {
+			return p, fmt.Errorf("jitter must be a duration between 0 and %s, e.g. 6h", maxExportJitter)
+		}
+	}
+	if q.Get("coarse") == "1" {
+		p.Coarse = true
+	}
+	return p, nil
+}
+
+// anonDigest is the keyed hash behind pseudonyms and jitter offsets
+func anonDigest(parts ...string) []byte {
+	mac := hmac.New(sha256.New, anonSalt)
+	mac.Write([]byte(strings.Join(parts, "\x00")))
+	return mac.Sum(nil)
+}
+
+// apply returns the entry as the policy allows it to leave the server
+func (p exportPolicy) apply(e BurnoutEntry) BurnoutEntry {
+	original := fmt.Sprint(e.ID)
+
+	if p.Jitter > 0 {
+		// The offset is derived from the entry rather than drawn fresh, so
+		// repeated exports cannot be averaged to recover the real time
+		span := uint64(2*p.Jitter/time.Second) + 1
+		offset := time.Duration(binary.BigEndian.Uint64(anonDigest("jitter", original))%span)*time.Second - p.Jitter
+		e.CreatedAt = e.CreatedAt.Add(offset)
+	}
+	if p.Coarse {
+		t := e.CreatedAt.UTC()
+		e.CreatedAt = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		e.Sleep = math.Round(e.Sleep*2) / 2
+		e.StudyHours = math.Round(e.StudyHours*2) / 2
+		e.Score = math.Round(e.Score/5) * 5
+		// The exact level would give away which side of a band edge the
+		// real score sits on
+		e.Level = scoreLevel(e.Score)
+	}
+
+	for _, f := range p.Hash {
+		switch f {
+		case "id":
+			// 48 bits keeps the pseudonym exact in JSON and spreadsheets
+			e.ID = int(binary.BigEndian.Uint64(anonDigest("id", original)) >> 16)
+		case "journal":
+			if e.Journal != "" {
+				e.Journal = hex.EncodeToString(anonDigest("journal", e.Journal))[:16]
+			}
+		case "advice":
+			e.Advice = hex.EncodeToString(anonDigest("advice", e.Advice))[:16]
+		}
+	}
+	for _, f := range p.Drop {
+		switch f {
+		case "id":
+			e.ID = 0
+		case "journal":
+			e.Journal = ""
+		case "advice":
+			e.Advice = ""
+		}
+	}
+	return e
+}
+
+// drops reports whether the policy blanks field
+func (p exportPolicy) drops(field string) bool {
+	return slices.Contains(p.Drop, field)
+}
+
+// parseExportRequest reads the range and anonymisation policy shared by
+// every export endpoint
+func parseExportRequest(r *http.Request) (since, until time.Time, policy exportPolicy, err error) {
+	if since, until, err = parseExportRange(r); err != nil {
+		return
+	}
+	policy, err = parseExportPolicy(r.URL.Query())
+	return
+}
+
+// exportEntries is the shared export pipeline: every format loads its
+// entries here, or through streamExportEntries, so the anonymisation
+// policy cannot be bypassed
+func exportEntries(since, until time.Time, policy exportPolicy) ([]BurnoutEntry, error) {
+	entries, err := queryEntries(since, until)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		entries[i] = policy.apply(entries[i])
+	}
+	return entries, nil
+}
+
+// streamExportEntries is exportEntries for formats that write as they
+// read: each entry in [since, until), oldest first, is passed to emit once
+// the policy has been applied. Cancelling ctx stops the query; an error
+// from emit stops it too and is returned.
+func streamExportEntries(ctx context.Context, since, until time.Time, policy exportPolicy, emit func(BurnoutEntry) error) error {
+	if until.IsZero() {
+		until = time.Now().Add(time.Minute)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT `+entryColumns+` FROM entries
+		WHERE created_at >= ? AND created_at < ?
+		ORDER BY created_at ASC`, since.UTC(), until.UTC())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := emit(policy.apply(e)); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
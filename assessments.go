@@ -0,0 +1,469 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const assessmentRunsSchema = `
+	CREATE TABLE IF NOT EXISTS assessment_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		assessment TEXT NOT NULL,
+		page INTEGER NOT NULL DEFAULT 0,
+		answers TEXT NOT NULL,
+		score REAL,
+		band TEXT,
+		started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME
+	);
+`
+
+// assessmentOption is one answer on an assessment's response scale
+type assessmentOption struct {
+	Label string `json:"label"`
+	Value int    `json:"value"`
+}
+
+// assessmentItem is one question; reversed items are scored from the other
+// end of the scale
+type assessmentItem struct {
+	Text     string `json:"text"`
+	Reversed bool   `json:"reversed,omitempty"`
+}
+
+// assessmentBand names a score range, from Min up to the next band's Min
+type assessmentBand struct {
+	Min   float64 `json:"min"`
+	Label string  `json:"label"`
+}
+
+// assessment is a validated questionnaire that is too long for the check-in
+// form, so it is answered a few questions per page
+type assessment struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Prompt      string             `json:"prompt"`
+	Options     []assessmentOption `json:"options"`
+	Items       []assessmentItem   `json:"items"`
+	PerPage     int                `json:"per_page"`
+	Mean        bool               `json:"mean"` // score is the item mean rather than the sum
+	Max         float64            `json:"max"`
+	Bands       []assessmentBand   `json:"bands"`
+}
+
+// frequencyOptions is the response scale of the PSS
+var frequencyOptions = []assessmentOption{
+	{"Never", 0}, {"Almost never", 1}, {"Sometimes", 2}, {"Fairly often", 3}, {"Very often", 4},
+}
+
+// cbiOptions is the response scale of the CBI, in points out of 100
+var cbiOptions = []assessmentOption{
+	{"Never / almost never", 0}, {"Seldom", 25}, {"Sometimes", 50}, {"Often", 75}, {"Always", 100},
+}
+
+// assessments are the questionnaires offered on /assessments. The Maslach
+// Burnout Inventory is licensed per administration, so its items cannot
+// ship with the app; the Copenhagen Burnout Inventory measures the same
+// exhaustion dimension and is free to use.
+var assessments = []*assessment{
+	{
+		ID:          "pss",
+		Name:        "Perceived Stress Scale (PSS-10)",
+		Description: "How unpredictable, uncontrollable and overloaded your life has felt over the last month.",
+		Prompt:      "In the last month, how often have you…",
+		Options:     frequencyOptions,
+		Items: []assessmentItem{
+			{Text: "been upset because of something that happened unexpectedly?"},
+			{Text: "felt that you were unable to control the important things in your life?"},
+			{Text: "felt nervous and “stressed”?"},
+			{Text: "felt confident about your ability to handle your personal problems?", Reversed: true},
+			{Text: "felt that things were going your way?", Reversed: true},
+			{Text: "found that you could not cope with all the things that you had to do?"},
+			{Text: "been able to control irritations in your life?", Reversed: true},
+			{Text: "felt that you were on top of things?", Reversed: true},
+			{Text: "been angered because of things that were outside of your control?"},
+			{Text: "felt difficulties were piling up so high that you could not overcome them?"},
+		},
+		PerPage: 4,
+		Max:     40,
+		Bands:   []assessmentBand{{0, "Low stress"}, {14, "Moderate stress"}, {27, "High stress"}},
+	},
+	{
+		ID:          "cbi",
+		Name:        "Copenhagen Burnout Inventory (CBI), student version",
+		Description: "Personal and study-related burnout: how worn out you feel, and how much of it you put down to your studies.",
+		Prompt:      "How often…",
+		Options:     cbiOptions,
+		Items: []assessmentItem{
+			{Text: "do you feel tired?"},
+			{Text: "are you physically exhausted?"},
+			{Text: "are you emotionally exhausted?"},
+			{Text: "do you think: “I can’t take it anymore”?"},
+			{Text: "do you feel worn out?"},
+			{Text: "do you feel weak and susceptible to illness?"},
+			{Text: "do you feel worn out at the end of the study day?"},
+			{Text: "are you exhausted in the morning at the thought of another day of studying?"},
+			{Text: "do you feel that every study hour is tiring for you?"},
+			{Text: "do you have enough energy for family and friends during leisure time?", Reversed: true},
+			{Text: "do you find your studies emotionally exhausting?"},
+			{Text: "do your studies frustrate you?"},
+			{Text: "do you feel burnt out because of your studies?"},
+		},
+		PerPage: 5,
+		Mean:    true,
+		Max:     100,
+		Bands:   []assessmentBand{{0, "Low burnout"}, {50, "Moderate burnout"}, {75, "High burnout"}, {100, "Severe burnout"}},
+	},
+}
+
+// findAssessment looks an assessment up by id
+func findAssessment(id string) *assessment {
+	for _, a := range assessments {
+		if a.ID == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// Pages is how many wizard pages the assessment takes
+func (a *assessment) Pages() int {
+	return (len(a.Items) + a.PerPage - 1) / a.PerPage
+}
+
+// pageItems returns the index range of the items on a page
+func (a *assessment) pageItems(page int) (int, int) {
+	start := page * a.PerPage
+	return start, min(start+a.PerPage, len(a.Items))
+}
+
+// validOption reports whether v is on the response scale
+func (a *assessment) validOption(v int) bool {
+	for _, o := range a.Options {
+		if o.Value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// score totals (or averages) the answers, reversing the reversed items
+func (a *assessment) score(answers []int) float64 {
+	lowest, highest := a.Options[0].Value, a.Options[len(a.Options)-1].Value
+	total := 0.0
+	for i, item := range a.Items {
+		v := answers[i]
+		if item.Reversed {
+			v = lowest + highest - v
+		}
+		total += float64(v)
+	}
+	if a.Mean {
+		total /= float64(len(a.Items))
+	}
+	return total
+}
+
+// band returns the label of the band a score falls in
+func (a *assessment) band(score float64) string {
+	label := a.Bands[0].Label
+	for _, b := range a.Bands {
+		if score >= b.Min {
+			label = b.Label
+		}
+	}
+	return label
+}
+
+// assessmentRun is one sitting of an assessment. Answers live on the server
+// between pages, so a reload or a second device picks up where it stopped.
+type assessmentRun struct {
+	ID          int64      `json:"id"`
+	Assessment  string     `json:"assessment"`
+	Page        int        `json:"page"`
+	Answers     []int      `json:"answers"` // -1 while unanswered
+	Score       *float64   `json:"score,omitempty"`
+	Band        string     `json:"band,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// Completed reports whether every page has been submitted
+func (run assessmentRun) Completed() bool {
+	return run.CompletedAt != nil
+}
+
+// Total is the score for display, 0 until the run is complete
+func (run assessmentRun) Total() float64 {
+	if run.Score == nil {
+		return 0
+	}
+	return *run.Score
+}
+
+const assessmentRunColumns = `id, assessment, page, answers, score, band, started_at, completed_at`
+
+func scanAssessmentRun(row interface{ Scan(...any) error }) (assessmentRun, error) {
+	var run assessmentRun
+	var answers string
+	var band sql.NullString
+	err := row.Scan(&run.ID, &run.Assessment, &run.Page, &answers, &run.Score, &band, &run.StartedAt, &run.CompletedAt)
+	if err != nil {
+		return run, err
+	}
+	run.Band = band.String
+	return run, json.Unmarshal([]byte(answers), &run.Answers)
+}
+
+// getAssessmentRun loads one run
+func getAssessmentRun(id int64) (assessmentRun, error) {
+	return scanAssessmentRun(db.QueryRow(`SELECT `+assessmentRunColumns+` FROM assessment_runs WHERE id = ?`, id))
+}
+
+// startAssessmentRun creates an empty run on the first page
+func startAssessmentRun(a *assessment) (int64, error) {
+	answers := make([]int, len(a.Items))
+	for i := range answers {
+		answers[i] = -1
+	}
+	data, _ := json.Marshal(answers)
+	res, err := db.Exec(`INSERT INTO assessment_runs (assessment, answers) VALUES (?, ?)`, a.ID, string(data))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// saveAssessmentRun stores the run's page, answers and, once complete, its
+// score
+func saveAssessmentRun(run assessmentRun) error {
+	data, _ := json.Marshal(run.Answers)
+	_, err := db.Exec(`UPDATE assessment_runs SET page = ?, answers = ?, score = ?, band = NULLIF(?, ''), completed_at = ?
+		WHERE id = ?`, run.Page, string(data), run.Score, run.Band, run.CompletedAt, run.ID)
+	return err
+}
+
+// completedAssessmentRuns lists finished runs, newest first
+func completedAssessmentRuns(limit int) ([]assessmentRun, error) {
+	rows, err := db.Query(`SELECT `+assessmentRunColumns+` FROM assessment_runs
+		WHERE completed_at IS NOT NULL ORDER BY completed_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	runs := []assessmentRun{}
+	for rows.Next() {
+		run, err := scanAssessmentRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// applyAssessmentPage reads the answers for the run's current page from the
+// form (fields "item-<n>"). Every item on the page must be answered, unless
+// the user is going back, in which case whatever was given is kept.
+func applyAssessmentPage(a *assessment, run *assessmentRun, r *http.Request, strict bool) error {
+	start, end := a.pageItems(run.Page)
+	var missing []int
+	for i := start; i < end; i++ {
+		raw := r.PostFormValue(fmt.Sprintf("item-%d", i))
+		if raw == "" {
+			missing = append(missing, i+1)
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || !a.validOption(v) {
+			return fmt.Errorf("question %d has an invalid answer", i+1)
+		}
+		run.Answers[i] = v
+	}
+	if strict && len(missing) == 1 {
+		return fmt.Errorf("please answer question %d", missing[0])
+	}
+	if strict && len(missing) > 1 {
+		return fmt.Errorf("please answer questions %s", joinInts(missing))
+	}
+	return nil
+}
+
+// joinInts formats 1, 2 and 3 as "1, 2 and 3"
+func joinInts(ns []int) string {
+	out := ""
+	for i, n := range ns {
+		switch {
+		case i == 0:
+		case i == len(ns)-1:
+			out += " and "
+		default:
+			out += ", "
+		}
+		out += strconv.Itoa(n)
+	}
+	return out
+}
+
+// wizardQuestion is one question as the wizard page shows it
+type wizardQuestion struct {
+	Number int
+	Field  string
+	Text   string
+	Answer int
+}
+
+// renderAssessmentRun writes the wizard: the whole page, or just the card
+// for HTMX requests
+func renderAssessmentRun(w http.ResponseWriter, r *http.Request, a *assessment, run assessmentRun, status int, message string) {
+	tmpl, err := loadTemplate("assessment.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	start, end := a.pageItems(run.Page)
+	questions := []wizardQuestion{}
+	for i := start; i < end; i++ {
+		questions = append(questions, wizardQuestion{Number: i + 1, Field: fmt.Sprintf("item-%d", i),
+			Text: a.Items[i].Text, Answer: run.Answers[i]})
+	}
+	data := map[string]any{
+		"Assessment": a,
+		"Run":        run,
+		"Questions":  questions,
+		"Number":     run.Page + 1,
+		"Percent":    100 * run.Page / a.Pages(),
+		"Last":       run.Page == a.Pages()-1,
+		"Error":      message,
+	}
+	if run.Completed() {
+		data["Percent"] = 100
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if r.Header.Get("HX-Request") == "true" {
+		tmpl.ExecuteTemplate(w, "wizard", data)
+		return
+	}
+	tmpl.Execute(w, data)
+}
+
+// handleAssessments lists the assessments and recent results
+func handleAssessments(w http.ResponseWriter, r *http.Request) {
+	runs, err := completedAssessmentRuns(20)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	names := map[string]string{}
+	for _, a := range assessments {
+		names[a.ID] = a.Name
+	}
+	tmpl, _, err := localizedTemplate("assessments.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, map[string]any{"Assessments": assessments, "Runs": runs, "Names": names})
+}
+
+// handleAssessmentStart begins a new run and redirects to its first page
+func handleAssessmentStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	a := findAssessment(r.PathValue("name"))
+	if a == nil {
+		http.Error(w, "Unknown assessment", http.StatusNotFound)
+		return
+	}
+	id, err := startAssessmentRun(a)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/assessments/runs/%d", id), http.StatusSeeOther)
+}
+
+// handleAssessmentRun shows the run's current page (GET) or submits it
+// (POST). "next" validates the page before moving on and scores the run
+// after the last one; "back" keeps partial answers and returns a page.
+func handleAssessmentRun(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := getAssessmentRun(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Assessment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a := findAssessment(run.Assessment)
+	if a == nil {
+		http.Error(w, "Unknown assessment", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		renderAssessmentRun(w, r, a, run, http.StatusOK, "")
+
+	case "POST":
+		if run.Completed() {
+			http.Error(w, "This assessment is already complete", http.StatusConflict)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		back := r.PostFormValue("action") == "back"
+		if err := applyAssessmentPage(a, &run, r, !back); err != nil {
+			renderAssessmentRun(w, r, a, run, http.StatusBadRequest, err.Error())
+			return
+		}
+		switch {
+		case back:
+			run.Page = max(run.Page-1, 0)
+		case run.Page < a.Pages()-1:
+			run.Page++
+		default:
+			score := a.score(run.Answers)
+			now := time.Now()
+			run.Score, run.Band, run.CompletedAt = &score, a.band(score), &now
+		}
+		if err := saveAssessmentRun(run); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("HX-Request") == "true" {
+			renderAssessmentRun(w, r, a, run, http.StatusOK, "")
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/assessments/runs/%d", run.ID), http.StatusSeeOther)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAssessmentAPI lists the assessment definitions and completed runs
+func handleAssessmentAPI(w http.ResponseWriter, r *http.Request) {
+	runs, err := completedAssessmentRuns(100)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"assessments": assessments, "runs": runs})
+}
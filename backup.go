@@ -0,0 +1,362 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backups are configured entirely through the environment:
+//
+//	BACKUP_S3_ENDPOINT     e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+//	BACKUP_S3_BUCKET       bucket name (path-style addressing, so MinIO works)
+//	BACKUP_S3_REGION       default us-east-1
+//	BACKUP_S3_ACCESS_KEY   / BACKUP_S3_SECRET_KEY
+//	BACKUP_S3_PREFIX       object key prefix, default "burnout-detector/"
+//	BACKUP_ENCRYPTION_KEY  base64 of 32 random bytes (AES-256-GCM)
+//	BACKUP_RETAIN          how many backups to keep, default 14
+//	BACKUP_INTERVAL        Go duration between backups, default 24h
+//
+// Backups are disabled unless the endpoint, bucket, credentials and
+// encryption key are all set. Nothing is ever uploaded unencrypted.
+//
+// To restore, download a backup, decrypt it with the same key and start
+// the server on the result:
+//
+//	BACKUP_ENCRYPTION_KEY=... burnout-detector decrypt-backup backup-20240601T020000Z.db.enc restored.db
+//	DB_PATH=restored.db burnout-detector
+//
+// Startup only creates missing tables and columns, so an older backup is
+// brought up to date without losing rows.
+
+// s3Settings locates a bucket and the credentials to reach it
+type s3Settings struct {
+	Endpoint  *url.URL
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// loadS3Settings reads the BACKUP_S3_* connection settings for bucket
+func loadS3Settings(bucket string) (s3Settings, error) {
+	endpoint := os.Getenv("BACKUP_S3_ENDPOINT")
+	s := s3Settings{
+		Bucket:    bucket,
+		Region:    envOr("BACKUP_S3_REGION", "us-east-1"),
+		AccessKey: os.Getenv("BACKUP_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("BACKUP_S3_SECRET_KEY"),
+	}
+	if endpoint == "" || bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return s, fmt.Errorf("BACKUP_S3_ENDPOINT, a bucket, BACKUP_S3_ACCESS_KEY and BACKUP_S3_SECRET_KEY are all required")
+	}
+	var err error
+	if s.Endpoint, err = url.Parse(strings.TrimRight(endpoint, "/")); err != nil || s.Endpoint.Host == "" {
+		return s, fmt.Errorf("invalid BACKUP_S3_ENDPOINT %q", endpoint)
+	}
+	return s, nil
+}
+
+// backupConfig holds the parsed BACKUP_* settings
+type backupConfig struct {
+	S3       s3Settings
+	Prefix   string
+	Key      []byte
+	Retain   int
+	Interval time.Duration
+}
+
+// loadBackupConfig reads the environment; ok is false when backups are off
+func loadBackupConfig() (cfg backupConfig, ok bool, err error) {
+	bucket := os.Getenv("BACKUP_S3_BUCKET")
+	if os.Getenv("BACKUP_S3_ENDPOINT") == "" && bucket == "" {
+		return cfg, false, nil
+	}
+	cfg = backupConfig{
+		Prefix:   envOr("BACKUP_S3_PREFIX", "burnout-detector/"),
+		Retain:   14,
+		Interval: 24 * time.Hour,
+	}
+	if cfg.S3, err = loadS3Settings(bucket); err != nil {
+		return cfg, false, fmt.Errorf("backup: %w", err)
+	}
+	if cfg.Key, err = base64.StdEncoding.DecodeString(os.Getenv("BACKUP_ENCRYPTION_KEY")); err != nil || len(cfg.Key) != 32 {
+		return cfg, false, fmt.Errorf("backup: BACKUP_ENCRYPTION_KEY must be base64 of 32 bytes (try: openssl rand -base64 32)")
+	}
+	if v := os.Getenv("BACKUP_RETAIN"); v != "" {
+		if cfg.Retain, err = strconv.Atoi(v); err != nil || cfg.Retain < 1 {
+			return cfg, false, fmt.Errorf("backup: invalid BACKUP_RETAIN %q", v)
+		}
+	}
+	if v := os.Getenv("BACKUP_INTERVAL"); v != "" {
+		if cfg.Interval, err = time.ParseDuration(v); err != nil || cfg.Interval < time.Hour {
+			return cfg, false, fmt.Errorf("backup: BACKUP_INTERVAL must be a duration of at least 1h")
+		}
+	}
+	return cfg, true, nil
+}
+
+// envOr returns the environment variable or a default
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// snapshotDatabase writes a consistent copy of the live database to a
+// temporary file using VACUUM INTO and returns its contents
+func snapshotDatabase() ([]byte, error) {
+	dir, err := os.MkdirTemp("", "burnout-backup")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	if _, err := db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// encryptBackup seals data with AES-256-GCM; the random nonce is prepended
+func encryptBackup(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+// decryptBackup reverses encryptBackup, for restores
+func decryptBackup(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("backup is truncated")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+// s3Client is a minimal S3 client (PUT, DELETE, ListObjectsV2) signing
+// requests with AWS Signature Version 4
+type s3Client struct {
+	s3   s3Settings
+	http *http.Client
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape percent-encodes a key segment the way SigV4 expects
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// canonicalQuery sorts and strictly encodes a query, as SigV4 signs it
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sign adds the x-amz-date, x-amz-content-sha256 and Authorization headers
+// for a request whose URL is already canonical: the path escaped with
+// s3Escape and the query from canonicalQuery
+func (c *s3Client) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + c.s3.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	signingKey := hmacSHA256([]byte("AWS4"+c.s3.SecretKey), day)
+	signingKey = hmacSHA256(signingKey, c.s3.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		c.s3.AccessKey, scope, signature))
+}
+
+// do signs and sends one request. key is the object key ("" for the bucket).
+func (c *s3Client) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	segments := []string{"", s3Escape(c.s3.Bucket)}
+	for _, part := range strings.Split(key, "/") {
+		if part != "" {
+			segments = append(segments, s3Escape(part))
+		}
+	}
+	path := strings.Join(segments, "/")
+	if key == "" {
+		path += "/"
+	}
+
+	req, err := http.NewRequest(method, c.s3.Endpoint.Scheme+"://"+c.s3.Endpoint.Host+c.s3.Endpoint.Path+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawPath = c.s3.Endpoint.Path + path
+	req.URL.RawQuery = canonicalQuery(query)
+	c.sign(req, sha256Hex(body), time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// list returns the keys under prefix (ListObjectsV2, following pagination)
+func (c *s3Client) list(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		resp, err := c.do("GET", "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range result.Contents {
+			keys = append(keys, obj.Key)
+		}
+		if !result.IsTruncated {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// runBackup snapshots, encrypts and uploads the database, then deletes
+// the oldest backups beyond the retention count
+func runBackup(cfg backupConfig) error {
+	snapshot, err := snapshotDatabase()
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	sealed, err := encryptBackup(cfg.Key, snapshot)
+	if err != nil {
+		return fmt.Errorf("encrypt: %w", err)
+	}
+
+	client := &s3Client{s3: cfg.S3, http: &http.Client{Timeout: 5 * time.Minute, Transport: tracedTransport()}}
+	// Timestamped names sort chronologically, which retention relies on
+	key := cfg.Prefix + "backup-" + time.Now().UTC().Format("20060102T150405Z") + ".db.enc"
+	resp, err := client.do("PUT", key, nil, sealed)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	slog.Info("backup uploaded", "key", key, "bytes", len(sealed))
+
+	keys, err := client.list(cfg.Prefix + "backup-")
+	if err != nil {
+		return fmt.Errorf("retention: %w", err)
+	}
+	sort.Strings(keys)
+	for len(keys) > cfg.Retain {
+		resp, err := client.do("DELETE", keys[0], nil, nil)
+		if err != nil {
+			return fmt.Errorf("retention: %w", err)
+		}
+		resp.Body.Close()
+		slog.Info("old backup removed", "key", keys[0])
+		keys = keys[1:]
+	}
+	return nil
+}
+
+// decryptBackupFile turns a downloaded backup back into a SQLite database
+// using the BACKUP_ENCRYPTION_KEY from the environment
+func decryptBackupFile(in, out string) error {
+	key, err := base64.StdEncoding.DecodeString(os.Getenv("BACKUP_ENCRYPTION_KEY"))
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("BACKUP_ENCRYPTION_KEY must be base64 of 32 bytes")
+	}
+	sealed, err := os.ReadFile(in)
+	if err != nil {
+		return err
+	}
+	data, err := decryptBackup(key, sealed)
+	if err != nil {
+		return fmt.Errorf("decrypt %s: %w", in, err)
+	}
+	return os.WriteFile(out, data, 0o600)
+}
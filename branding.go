@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultBrandName       = "Burnout Detector AI"
+	defaultBrandAccent     = "#4F46E5"
+	defaultBrandAccentDark = "#4338CA"
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// brandLogoTypes are the logo formats accepted, by file extension. PDF
+// reports can only embed the raster ones.
+var brandLogoTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".svg":  "image/svg+xml",
+}
+
+// branding lets an institution, e.g. a university wellness office, run the
+// app under its own name, logo, colours and footer
+type branding struct {
+	Name       string
+	Accent     string // #RRGGBB for links, buttons and report headings
+	AccentDark string // hover and pressed states
+	Footer     string
+
+	logo     []byte
+	logoType string
+}
+
+// brand is the running instance's branding, set from the environment at
+// startup
+var brand = branding{Name: defaultBrandName, Accent: defaultBrandAccent, AccentDark: defaultBrandAccentDark}
+
+// loadBranding reads the BRAND_* variables:
+//
+//	BRAND_NAME         shown in page titles, headers and reports
+//	BRAND_LOGO         path to a PNG, JPEG or SVG file
+//	BRAND_ACCENT       accent colour as #RRGGBB
+//	BRAND_ACCENT_DARK  hover colour, by default a darker accent
+//	BRAND_FOOTER       a line of text at the bottom of every page and report
+func loadBranding() (branding, error) {
+	b := branding{
+		Name:       envOr("BRAND_NAME", defaultBrandName),
+		Accent:     envOr("BRAND_ACCENT", defaultBrandAccent),
+		AccentDark: os.Getenv("BRAND_ACCENT_DARK"),
+		Footer:     os.Getenv("BRAND_FOOTER"),
+	}
+	if !colorPattern.MatchString(b.Accent) {
+		return b, fmt.Errorf("BRAND_ACCENT: %q is not a #RRGGBB colour", b.Accent)
+	}
+	switch {
+	case b.AccentDark == "" && b.Accent == defaultBrandAccent:
+		b.AccentDark = defaultBrandAccentDark
+	case b.AccentDark == "":
+		b.AccentDark = shadeColor(b.Accent, 0.85)
+	case !colorPattern.MatchString(b.AccentDark):
+		return b, fmt.Errorf("BRAND_ACCENT_DARK: %q is not a #RRGGBB colour", b.AccentDark)
+	}
+
+	if file := os.Getenv("BRAND_LOGO"); file != "" {
+		contentType, ok := brandLogoTypes[strings.ToLower(filepath.Ext(file))]
+		if !ok {
+			return b, fmt.Errorf("BRAND_LOGO: %s is not a PNG, JPEG or SVG file", file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return b, fmt.Errorf("BRAND_LOGO: %w", err)
+		}
+		b.logo, b.logoType = data, contentType
+	}
+	return b, nil
+}
+
+// shadeColor scales each channel of a #RRGGBB colour by f
+func shadeColor(hex string, f float64) string {
+	v, _ := strconv.ParseUint(hex[1:], 16, 32)
+	r, g, bl := float64(v>>16&0xFF)*f, float64(v>>8&0xFF)*f, float64(v&0xFF)*f
+	return fmt.Sprintf("#%02X%02X%02X", int(r), int(g), int(bl))
+}
+
+// rgb returns the channels of a #RRGGBB colour, for the PDF report
+func rgb(hex string) (int, int, int) {
+	v, _ := strconv.ParseUint(hex[1:], 16, 32)
+	return int(v >> 16 & 0xFF), int(v >> 8 & 0xFF), int(v & 0xFF)
+}
+
+// LogoURL is where the logo is served, empty when none is configured
+func (b branding) LogoURL() string {
+	if b.logo == nil {
+		return ""
+	}
+	return "/brand/logo"
+}
+
+// Style sets the accent colours app.css reads; it is empty for the
+// default colours, which app.css already falls back to
+func (b branding) Style() template.HTML {
+	if b.Accent == defaultBrandAccent && b.AccentDark == defaultBrandAccentDark {
+		return ""
+	}
+	return template.HTML(fmt.Sprintf("<style>:root{--brand-accent:%s;--brand-accent-dark:%s}</style>", b.Accent, b.AccentDark))
+}
+
+// Heading is the name as page headers show it: the two-tone wordmark for
+// the default name, otherwise the logo and the configured name
+func (b branding) Heading() template.HTML {
+	var out strings.Builder
+	if url := b.LogoURL(); url != "" {
+		fmt.Fprintf(&out, `<img src="%s" alt="" class="inline-block h-8 w-auto mr-2 align-middle">`, url)
+	}
+	if b.Name == defaultBrandName {
+		out.WriteString(`Burnout<span class="text-indigo-600">Detector</span>`)
+	} else {
+		out.WriteString(template.HTMLEscapeString(b.Name))
+	}
+	return template.HTML(out.String())
+}
+
+// handleBrandLogo serves the configured logo
+func handleBrandLogo(w http.ResponseWriter, r *http.Request) {
+	if brand.logo == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", brand.logoType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(brand.logo)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+)
+
+const (
+	// cacheMaxEntries bounds the response cache; when full it starts over,
+	// which is cheap since entries are rebuilt from one query each
+	cacheMaxEntries = 512
+	// cacheMaxBody keeps a large payload (a year of raw points) from
+	// crowding out everything else
+	cacheMaxBody = 1 << 20
+)
+
+// cachedResponse is a rendered 200 response for one ETag
+type cachedResponse struct {
+	generation  uint64
+	contentType string
+	body        []byte
+}
+
+// responseCache holds the output of the conditional endpoints keyed by
+// their ETag. The tag already covers the URL, language, day and write
+// generation, so an entry is only ever wrong once the data has moved on,
+// and then nothing can ask for its tag again; entries from an older
+// generation are dropped as soon as a newer one is stored.
+type responseCache struct {
+	mu         sync.Mutex
+	generation uint64
+	entries    map[string]cachedResponse
+}
+
+var responses = &responseCache{entries: map[string]cachedResponse{}}
+
+func (c *responseCache) get(etag string) (cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[etag]
+	return e, ok
+}
+
+func (c *responseCache) put(etag string, e cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.generation < c.generation {
+		return // rendered before a write that has since landed
+	}
+	if e.generation > c.generation || len(c.entries) >= cacheMaxEntries {
+		c.entries = map[string]cachedResponse{}
+		c.generation = e.generation
+	}
+	c.entries[etag] = e
+}
+
+// serveCached answers from the cache, or runs next and keeps a successful
+// response for the next request with the same ETag
+func serveCached(w http.ResponseWriter, r *http.Request, etag string, generation uint64, next http.Handler) {
+	if e, ok := responses.get(etag); ok {
+		metrics.count(metrics.cache, "hit")
+		w.Header().Set("Content-Type", e.contentType)
+		w.Write(e.body)
+		return
+	}
+	metrics.count(metrics.cache, "miss")
+	rec := &bodyRecorder{statusRecorder: statusRecorder{ResponseWriter: w}}
+	next.ServeHTTP(rec, r)
+	if (rec.code == 0 || rec.code == http.StatusOK) && !rec.tooBig {
+		responses.put(etag, cachedResponse{generation: generation, contentType: w.Header().Get("Content-Type"), body: rec.body.Bytes()})
+	}
+}
+
+// bodyRecorder keeps a copy of what a handler wrote, up to cacheMaxBody
+type bodyRecorder struct {
+	statusRecorder
+	body   bytes.Buffer
+	tooBig bool
+}
+
+func (b *bodyRecorder) Write(p []byte) (int, error) {
+	if !b.tooBig {
+		if b.body.Len()+len(p) > cacheMaxBody {
+			b.tooBig = true
+			b.body = bytes.Buffer{}
+		} else {
+			b.body.Write(p)
+		}
+	}
+	return b.statusRecorder.Write(p)
+}
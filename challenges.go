@@ -0,0 +1,249 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+)
+
+const challengesSchema = `
+	CREATE TABLE IF NOT EXISTS challenge_enrollments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		challenge TEXT NOT NULL,
+		started_on TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'active',
+		finished_at DATETIME
+	);
+	CREATE TABLE IF NOT EXISTS challenge_days (
+		enrollment_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		passed BOOLEAN NOT NULL,
+		PRIMARY KEY (enrollment_id, day)
+	);
+`
+
+// Enrollment statuses
+const (
+	challengeActive    = "active"
+	challengeCompleted = "completed"
+	challengeFailed    = "failed"
+	challengeAbandoned = "abandoned"
+)
+
+// Challenge is a predefined multi-day challenge. A day counts once a check-in
+// on that day satisfies Check; the latest check-in of the day wins.
+type Challenge struct {
+	Key         string                  `json:"key"`
+	Title       string                  `json:"title"`
+	Description string                  `json:"description"`
+	Days        int                     `json:"days"`
+	Check       func(BurnoutEntry) bool `json:"-"`
+}
+
+// challenges is the built-in challenge catalog
+var challenges = []Challenge{
+	{
+		Key:         "sleep-7",
+		Title:       "7-Day Sleep Challenge",
+		Description: "Log at least 7 hours of sleep every day for a week.",
+		Days:        7,
+		Check:       func(e BurnoutEntry) bool { return e.Sleep >= 7 },
+	},
+	{
+		Key:         "deadline-triage",
+		Title:       "Deadline Triage Week",
+		Description: "Keep your open deadlines at 3 or fewer and stress at 3 or below for 7 days.",
+		Days:        7,
+		Check:       func(e BurnoutEntry) bool { return e.Deadlines <= 3 && e.Stress <= 3 },
+	},
+	{
+		Key:         "move-5",
+		Title:       "Move 5 Days",
+		Description: "Exercise on 5 consecutive days.",
+		Days:        5,
+		Check:       func(e BurnoutEntry) bool { return e.Exercise },
+	},
+}
+
+// findChallenge looks up a catalog entry by key
+func findChallenge(key string) (Challenge, bool) {
+	for _, c := range challenges {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Challenge{}, false
+}
+
+// Enrollment is one attempt at a challenge
+type Enrollment struct {
+	ID         int64     `json:"id"`
+	Challenge  Challenge `json:"challenge"`
+	StartedOn  string    `json:"started_on"`
+	Status     string    `json:"status"`
+	DaysPassed int       `json:"days_passed"`
+	DaysLogged int       `json:"days_logged"`
+	DayNumber  int       `json:"day_number"`
+}
+
+// window returns the [start, end) days covered by the enrollment
+func (e Enrollment) window() (time.Time, time.Time) {
+	start, _ := parseDay(e.StartedOn)
+	return start, start.AddDate(0, 0, e.Challenge.Days)
+}
+
+// loadEnrollments returns enrollments, optionally only active ones, with
+// their day counts filled in
+func loadEnrollments(activeOnly bool) ([]Enrollment, error) {
+	query := `
+		SELECT e.id, e.challenge, e.started_on, e.status,
+			COALESCE(SUM(CASE WHEN d.passed THEN 1 ELSE 0 END), 0), COUNT(d.day)
+		FROM challenge_enrollments e
+		LEFT JOIN challenge_days d ON d.enrollment_id = e.id`
+	if activeOnly {
+		query += ` WHERE e.status = 'active'`
+	}
+	rows, err := db.Query(query + ` GROUP BY e.id ORDER BY e.id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []Enrollment{}
+	for rows.Next() {
+		var e Enrollment
+		var key string
+		if err := rows.Scan(&e.ID, &key, &e.StartedOn, &e.Status, &e.DaysPassed, &e.DaysLogged); err != nil {
+			return nil, err
+		}
+		c, ok := findChallenge(key)
+		if !ok {
+			continue // challenge removed from the catalog
+		}
+		e.Challenge = c
+		start, _ := e.window()
+		e.DayNumber = int(time.Now().UTC().Sub(start).Hours()/24) + 1
+		enrollments = append(enrollments, e)
+	}
+	return enrollments, rows.Err()
+}
+
+// settleEnrollments marks active enrollments completed once every day has
+// passed, or failed once the window has closed without that
+func settleEnrollments() error {
+	enrollments, err := loadEnrollments(true)
+	if err != nil {
+		return err
+	}
+	today := bucketStart(time.Now(), granularityDay)
+	for _, e := range enrollments {
+		_, end := e.window()
+		status := ""
+		switch {
+		case e.DaysPassed >= e.Challenge.Days:
+			status = challengeCompleted
+		case !today.Before(end):
+			status = challengeFailed
+		}
+		if status != "" {
+			if _, err := db.Exec(`UPDATE challenge_enrollments SET status = ?, finished_at = ? WHERE id = ?`,
+				status, time.Now().UTC(), e.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// trackChallenges records entry against every active enrollment whose window
+// covers it. It is called after each check-in is saved.
+func trackChallenges(entry BurnoutEntry) error {
+	enrollments, err := loadEnrollments(true)
+	if err != nil {
+		return err
+	}
+	day := bucketStart(entry.CreatedAt, granularityDay)
+	for _, e := range enrollments {
+		start, end := e.window()
+		if day.Before(start) || !day.Before(end) {
+			continue
+		}
+		_, err := db.Exec(`
+			INSERT INTO challenge_days (enrollment_id, day, passed) VALUES (?, ?, ?)
+			ON CONFLICT(enrollment_id, day) DO UPDATE SET passed = excluded.passed`,
+			e.ID, day.Format("2006-01-02"), e.Challenge.Check(entry))
+		if err != nil {
+			return err
+		}
+	}
+	return settleEnrollments()
+}
+
+// ChallengeOverview is the payload of GET /api/challenges
+type ChallengeOverview struct {
+	Catalog     []Challenge  `json:"catalog"`
+	Enrollments []Enrollment `json:"enrollments"`
+}
+
+// handleChallenges lists the catalog together with all enrollments
+func handleChallenges(w http.ResponseWriter, r *http.Request) {
+	if err := settleEnrollments(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	enrollments, err := loadEnrollments(false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, ChallengeOverview{Catalog: challenges, Enrollments: enrollments})
+}
+
+// handleChallengeEnroll starts a challenge today (POST) or abandons the
+// active attempt at it (DELETE)
+func handleChallengeEnroll(w http.ResponseWriter, r *http.Request) {
+	c, ok := findChallenge(r.PathValue("key"))
+	if !ok {
+		http.Error(w, "Challenge not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case "POST":
+		var existing int64
+		err := db.QueryRow(`SELECT id FROM challenge_enrollments WHERE challenge = ? AND status = 'active'`, c.Key).Scan(&existing)
+		if err == nil {
+			http.Error(w, "Already enrolled in this challenge", http.StatusConflict)
+			return
+		}
+		if err != sql.ErrNoRows {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		startedOn := dayKey(time.Now())
+		res, err := db.Exec(`INSERT INTO challenge_enrollments (challenge, started_on) VALUES (?, ?)`, c.Key, startedOn)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		id, _ := res.LastInsertId()
+		writeJSON(w, http.StatusCreated, Enrollment{ID: id, Challenge: c, StartedOn: startedOn, Status: challengeActive, DayNumber: 1})
+
+	case "DELETE":
+		res, err := db.Exec(`UPDATE challenge_enrollments SET status = ?, finished_at = ? WHERE challenge = ? AND status = 'active'`,
+			challengeAbandoned, time.Now().UTC(), c.Key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Not enrolled in this challenge", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	defaultChartWidth  = 800
+	defaultChartHeight = 300
+	defaultChartDays   = 30
+	// chart image margins leave room for the axis labels
+	chartMarginLeft   = 36
+	chartMarginRight  = 12
+	chartMarginTop    = 28
+	chartMarginBottom = 24
+)
+
+// scoreBand is a shaded horizontal band on the score axis
+type scoreBand struct {
+	From, To float64
+	Fill     color.RGBA
+}
+
+// scoreBandFills are the pale level colours behind line charts, healthy
+// to severe
+var scoreBandFills = [4]color.RGBA{
+	{220, 252, 231, 255},
+	{254, 249, 195, 255},
+	{255, 237, 213, 255},
+	{254, 226, 226, 255},
+}
+
+// bandsWithFills shades the configured level thresholds, one fill per level
+func bandsWithFills(fills [4]color.RGBA) []scoreBand {
+	bands := make([]scoreBand, 0, len(fills))
+	for i, b := range currentScoring().bounds() {
+		bands = append(bands, scoreBand{b[0], b[1], fills[i]})
+	}
+	return bands
+}
+
+// scoreBands are the pale bands behind line charts
+func scoreBands() []scoreBand { return bandsWithFills(scoreBandFills) }
+
+// scoreTicks label the score axis at the level thresholds
+func scoreTicks() []float64 {
+	s := currentScoring()
+	return []float64{0, s.HealthyMax, s.AtRiskMax, s.HighRiskMax, 100}
+}
+
+var (
+	chartLineColor  = color.RGBA{79, 70, 229, 255}
+	chartGridColor  = color.RGBA{209, 213, 219, 255}
+	chartTextColor  = color.RGBA{75, 85, 99, 255}
+	chartBackground = color.RGBA{255, 255, 255, 255}
+)
+
+// chartImage holds the geometry shared by the PNG and SVG renderers
+type chartImage struct {
+	Width, Height int
+	Title         string
+	Labels        []string
+	Scores        []float64
+}
+
+func (c chartImage) plotWidth() int  { return c.Width - chartMarginLeft - chartMarginRight }
+func (c chartImage) plotHeight() int { return c.Height - chartMarginTop - chartMarginBottom }
+
+// x returns the horizontal pixel position of point i
+func (c chartImage) x(i int) float64 {
+	if len(c.Scores) < 2 {
+		return float64(chartMarginLeft + c.plotWidth()/2)
+	}
+	return float64(chartMarginLeft) + float64(i)*float64(c.plotWidth())/float64(len(c.Scores)-1)
+}
+
+// y returns the vertical pixel position of a 0-100 score
+func (c chartImage) y(score float64) float64 {
+	score = max(0, min(100, score))
+	return float64(chartMarginTop) + (100-score)/100*float64(c.plotHeight())
+}
+
+// parseChartSize reads the optional width/height query parameters
+func parseChartSize(r *http.Request) (int, int, error) {
+	size := func(name string, def, lo, hi int) (int, error) {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < lo || n > hi {
+			return 0, fmt.Errorf("invalid %s %q (use %d-%d)", name, v, lo, hi)
+		}
+		return n, nil
+	}
+	w, err := size("width", defaultChartWidth, 200, 2000)
+	if err != nil {
+		return 0, 0, err
+	}
+	h, err := size("height", defaultChartHeight, 120, 1200)
+	return w, h, err
+}
+
+// buildChartImage loads the score trend for the request's range
+func buildChartImage(r *http.Request) (chartImage, error) {
+	gran := granularityDay
+	if r.URL.Query().Get("granularity") != "" {
+		g, err := parseGranularity(r)
+		if err != nil {
+			return chartImage{}, err
+		}
+		gran = g
+	}
+	days, err := parseRangeDays(r, defaultChartDays)
+	if err != nil {
+		return chartImage{}, err
+	}
+	w, h, err := parseChartSize(r)
+	if err != nil {
+		return chartImage{}, err
+	}
+	points, _, err := loadChartPoints(gran, days)
+	if err != nil {
+		return chartImage{}, err
+	}
+
+	c := chartImage{Width: w, Height: h, Title: fmt.Sprintf("Burnout score · last %d days", days)}
+	if gran == granularityRaw {
+		c.Title = "Burnout score · latest check-ins"
+	}
+	loc := requestLocalizer(r)
+	for _, p := range points {
+		c.Labels = append(c.Labels, chartLabel(loc, p.At, gran))
+		c.Scores = append(c.Scores, roundTo(p.Score, 1))
+	}
+	return c, nil
+}
+
+// handleChartImage renders the score trend as /api/chart.png or /api/chart.svg
+func handleChartImage(w http.ResponseWriter, r *http.Request) {
+	c, err := buildChartImage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if strings.HasSuffix(r.URL.Path, ".svg") {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte(c.SVG()))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.PNG()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
+
+// SVG renders the chart as a standalone SVG document
+func (c chartImage) SVG() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Inter, Arial, sans-serif">`,
+		c.Width, c.Height, c.Width, c.Height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(chartBackground))
+	for _, band := range scoreBands() {
+		fmt.Fprintf(&b, `<rect x="%d" y="%.1f" width="%d" height="%.1f" fill="%s"/>`,
+			chartMarginLeft, c.y(band.To), c.plotWidth(), c.y(band.From)-c.y(band.To), hexColor(band.Fill))
+	}
+	for _, tick := range scoreTicks() {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s" stroke-width="1"/>`,
+			chartMarginLeft, c.y(tick), c.Width-chartMarginRight, c.y(tick), hexColor(chartGridColor))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" font-size="10" text-anchor="end" fill="%s">%.0f</text>`,
+			chartMarginLeft-6, c.y(tick)+3, hexColor(chartTextColor), tick)
+	}
+	fmt.Fprintf(&b, `<text x="%d" y="18" font-size="13" font-weight="600" fill="%s">%s</text>`,
+		chartMarginLeft, hexColor(chartTextColor), html.EscapeString(c.Title))
+
+	if len(c.Scores) == 0 {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-size="12" text-anchor="middle" fill="%s">No check-ins in this range</text>`,
+			c.Width/2, c.Height/2, hexColor(chartTextColor))
+	} else {
+		var path []string
+		for i, s := range c.Scores {
+			path = append(path, fmt.Sprintf("%.1f,%.1f", c.x(i), c.y(s)))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2.5" stroke-linejoin="round"/>`,
+			strings.Join(path, " "), hexColor(chartLineColor))
+		for i, s := range c.Scores {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s: %.1f</title></circle>`,
+				c.x(i), c.y(s), hexColor(chartLineColor), html.EscapeString(c.Labels[i]), s)
+		}
+		for _, i := range c.labelIndexes() {
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="10" text-anchor="middle" fill="%s">%s</text>`,
+				c.x(i), c.Height-8, hexColor(chartTextColor), html.EscapeString(c.Labels[i]))
+		}
+	}
+	b.WriteString(`</svg>`)
+	return b.String()
+}
+
+// labelIndexes picks which x-axis labels fit without overlapping
+func (c chartImage) labelIndexes() []int {
+	n := len(c.Labels)
+	if n == 0 {
+		return nil
+	}
+	maxLabels := max(1, c.plotWidth()/70)
+	step := max(1, (n+maxLabels-1)/maxLabels)
+	var out []int
+	for i := 0; i < n; i += step {
+		out = append(out, i)
+	}
+	if out[len(out)-1] != n-1 && n > 1 && float64(n-1-out[len(out)-1]) > float64(step)/2 {
+		out = append(out, n-1)
+	}
+	return out
+}
+
+// PNG rasterises the chart. Text uses the built-in 7x13 bitmap font, so no
+// font files or headless browser are needed.
+func (c chartImage) PNG() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, c.Width, c.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+
+	for _, band := range scoreBands() {
+		rect := image.Rect(chartMarginLeft, int(c.y(band.To)), c.Width-chartMarginRight, int(c.y(band.From)))
+		draw.Draw(img, rect, image.NewUniform(band.Fill), image.Point{}, draw.Src)
+	}
+	for _, tick := range scoreTicks() {
+		y := int(c.y(tick))
+		for x := chartMarginLeft; x < c.Width-chartMarginRight; x++ {
+			img.Set(x, y, chartGridColor)
+		}
+		label := strconv.Itoa(int(tick))
+		drawText(img, chartMarginLeft-6-7*len(label), y+4, label)
+	}
+	drawText(img, chartMarginLeft, 18, c.Title)
+
+	if len(c.Scores) == 0 {
+		msg := "No check-ins in this range"
+		drawText(img, (c.Width-7*len(msg))/2, c.Height/2, msg)
+		return img
+	}
+	for i := 1; i < len(c.Scores); i++ {
+		drawLine(img, c.x(i-1), c.y(c.Scores[i-1]), c.x(i), c.y(c.Scores[i]), chartLineColor)
+	}
+	for i, s := range c.Scores {
+		fillCircle(img, c.x(i), c.y(s), 3, chartLineColor)
+	}
+	for _, i := range c.labelIndexes() {
+		width := 7 * len(c.Labels[i])
+		x := min(max(int(c.x(i))-width/2, 0), c.Width-width)
+		drawText(img, x, c.Height-8, c.Labels[i])
+	}
+	return img
+}
+
+// drawText writes ASCII text with its baseline at y
+func drawText(img draw.Image, x, y int, text string) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(chartTextColor),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(strings.Map(func(r rune) rune {
+		if r > 126 {
+			return '-'
+		}
+		return r
+	}, text))
+}
+
+// drawLine draws a 2px line by stamping small discs along the segment
+func drawLine(img draw.Image, x0, y0, x1, y1 float64, col color.Color) {
+	steps := int(math.Max(math.Abs(x1-x0), math.Abs(y1-y0)))
+	for s := 0; s <= steps; s++ {
+		t := 0.0
+		if steps > 0 {
+			t = float64(s) / float64(steps)
+		}
+		fillCircle(img, x0+(x1-x0)*t, y0+(y1-y0)*t, 1.2, col)
+	}
+}
+
+// fillCircle fills a disc of radius r centred on (cx, cy)
+func fillCircle(img draw.Image, cx, cy, r float64, col color.Color) {
+	for y := int(cy - r); y <= int(cy+r); y++ {
+		for x := int(cx - r); x <= int(cx+r); x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			if dx*dx+dy*dy <= r*r {
+				img.Set(x, y, col)
+			}
+		}
+	}
+}
+
+// hexColor formats a colour for SVG attributes
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Chart granularities accepted by /history-chart
+const (
+	granularityRaw  = "raw"
+	granularityDay  = "day"
+	granularityWeek = "week"
+)
+
+// defaultAggregateDays is how far back aggregated charts look when no range is given
+const defaultAggregateDays = 90
+
+// rawChartPoints is how many individual entries the raw chart shows
+const rawChartPoints = 10
+
+// movingAverageWindow is the trailing window used for the smoothed dataset
+const movingAverageWindow = 7 * 24 * time.Hour
+
+// scorePoint is a single timestamped score used by the chart builders
+type scorePoint struct {
+	At    time.Time
+	Score float64
+	ID    int // the entry, for raw points; zero for aggregates
+}
+
+// parseGranularity validates the granularity query parameter
+func parseGranularity(r *http.Request) (string, error) {
+	g := r.URL.Query().Get("granularity")
+	switch g {
+	case "", granularityRaw:
+		return granularityRaw, nil
+	case granularityDay, granularityWeek:
+		return g, nil
+	}
+	return "", fmt.Errorf("invalid granularity %q (use raw, day or week)", g)
+}
+
+// parseRangeDays reads the optional "days" query parameter
+func parseRangeDays(r *http.Request, def int) (int, error) {
+	v := r.URL.Query().Get("days")
+	if v == "" {
+		return def, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days <= 0 || days > 3660 {
+		return 0, fmt.Errorf("invalid days %q", v)
+	}
+	return days, nil
+}
+
+// queryScorePoints loads every score recorded since the given time, oldest first
+func queryScorePoints(since time.Time) ([]scorePoint, error) {
+	rows, err := stmts.scoresSince.Query(since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []scorePoint
+	for rows.Next() {
+		var p scorePoint
+		if err := rows.Scan(&p.At, &p.Score); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// bucketStart truncates t to the start of its day or ISO week (Monday) in
+// the user's zone, whatever zone t is in
+func bucketStart(t time.Time, granularity string) time.Time {
+	t = t.In(loadZone())
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if granularity != granularityWeek {
+		return day
+	}
+	offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+	return day.AddDate(0, 0, -offset)
+}
+
+// aggregatePoints averages points per day or per week, oldest bucket first
+func aggregatePoints(points []scorePoint, granularity string) []scorePoint {
+	var out []scorePoint
+	var sum float64
+	var n int
+	for i, p := range points {
+		start := bucketStart(p.At, granularity)
+		sum += p.Score
+		n++
+		last := i == len(points)-1
+		if last || !bucketStart(points[i+1].At, granularity).Equal(start) {
+			out = append(out, scorePoint{At: start, Score: sum / float64(n)})
+			sum, n = 0, 0
+		}
+	}
+	return out
+}
+
+// chartLabel formats a bucket timestamp for the x-axis. Day and week
+// buckets are calendar days; raw points are instants shown in the user's
+// zone.
+func chartLabel(loc localizer, t time.Time, granularity string) string {
+	switch granularity {
+	case granularityDay:
+		return loc.Day(t)
+	case granularityWeek:
+		return loc.T("format.week", "Day", loc.Day(t))
+	}
+	return loc.Time(t)
+}
+
+// loadChartPoints returns the points to plot plus the wider history (same
+// granularity) that trailing calculations such as the moving average need
+func loadChartPoints(granularity string, days int) (points, history []scorePoint, err error) {
+	if granularity == granularityRaw {
+		rows, err := stmts.recentScores.Query(rawChartPoints)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var p scorePoint
+			if err := rows.Scan(&p.At, &p.Score, &p.ID); err != nil {
+				continue
+			}
+			points = append(points, p)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+		if len(points) == 0 {
+			return nil, nil, nil
+		}
+		since := points[0].At.Add(-movingAverageWindow)
+		if t := points[len(points)-1].At.Add(-trendWindow); t.Before(since) {
+			since = t
+		}
+		history, err = queryScorePoints(since)
+		return points, history, err
+	}
+
+	start := bucketStart(time.Now().AddDate(0, 0, -days), granularity)
+	since := start.Add(-movingAverageWindow)
+	if t := time.Now().UTC().Add(-trendWindow); t.Before(since) {
+		since = t
+	}
+	raw, err := queryScorePoints(since)
+	if err != nil {
+		return nil, nil, err
+	}
+	history = aggregatePoints(raw, granularity)
+	for _, p := range history {
+		if !p.At.Before(start) {
+			points = append(points, p)
+		}
+	}
+	return points, history, nil
+}
+
+// movingAverageAt averages the history points in the window ending at t
+func movingAverageAt(history []scorePoint, t time.Time, window time.Duration) float64 {
+	var sum float64
+	var n int
+	for _, p := range history {
+		if p.At.After(t) || !p.At.After(t.Add(-window)) {
+			continue
+		}
+		sum += p.Score
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// roundTo rounds v to the given number of decimal places
+func roundTo(v float64, places int) float64 {
+	pow := math.Pow(10, float64(places))
+	return math.Round(v*pow) / pow
+}
+
+// loadChartBuckets returns entries grouped for plotting: one bucket per entry
+// for the raw view, otherwise one per day or week within the range
+func loadChartBuckets(granularity string, days int) ([]entryBucket, error) {
+	if granularity == granularityRaw {
+		entries, err := queryRecentEntries(rawChartPoints)
+		if err != nil {
+			return nil, err
+		}
+		buckets := make([]entryBucket, 0, len(entries))
+		for _, e := range entries {
+			buckets = append(buckets, entryBucket{Start: e.CreatedAt, Entries: []BurnoutEntry{e}})
+		}
+		return buckets, nil
+	}
+
+	start := bucketStart(time.Now().AddDate(0, 0, -days), granularity)
+	entries, err := queryEntries(start, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	return bucketEntries(entries, granularity), nil
+}
+
+// seriesChart builds one aligned dataset per field over the buckets
+func seriesChart(loc localizer, buckets []entryBucket, granularity string, series []chartSeries) ChartData {
+	chart := ChartData{Labels: []string{}, Data: []float64{}}
+	for _, s := range series {
+		chart.Datasets = append(chart.Datasets, ChartDataset{Label: s.Label, Data: []float64{}})
+	}
+	for _, b := range buckets {
+		chart.Labels = append(chart.Labels, chartLabel(loc, b.Start, granularity))
+		for i, s := range series {
+			chart.Datasets[i].Data = append(chart.Datasets[i].Data, roundTo(averageOf(b.Entries, s.Field), 1))
+		}
+	}
+	if len(chart.Datasets) > 0 {
+		chart.Data = chart.Datasets[0].Data
+	}
+	return chart
+}
+
+// chartSeries names an entry field to plot
+type chartSeries struct {
+	Label string
+	Field func(BurnoutEntry) float64
+}
+
+// factorSeries are the score drivers overlaid by /api/charts/factors
+var factorSeries = []chartSeries{
+	{Label: "Burnout Score", Field: entryScore},
+	{Label: "Sleep (Hrs)", Field: entrySleep},
+	{Label: "Stress (1-5)", Field: entryStress},
+	{Label: "Study (Hrs)", Field: entryStudyHours},
+}
+
+// handleFactorChart returns aligned score, sleep, stress and study series
+func handleFactorChart(w http.ResponseWriter, r *http.Request) {
+	granularity, err := parseGranularity(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := parseRangeDays(r, defaultAggregateDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := loadChartBuckets(granularity, days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(seriesChart(requestLocalizer(r), buckets, granularity, factorSeries)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// moodSeries are the datasets plotted over time by /api/charts/mood
+var moodSeries = []chartSeries{
+	{Label: "Mood (1-5)", Field: entryMood},
+	{Label: "Stress (1-5)", Field: entryStress},
+	{Label: "Burnout Score", Field: entryScore},
+}
+
+// MoodPoint is one check-in on the mood-vs-stress scatter plot
+type MoodPoint struct {
+	Date   string  `json:"date"`
+	Stress int     `json:"x"`
+	Mood   int     `json:"y"`
+	Score  float64 `json:"score"`
+}
+
+// MoodChartData pairs the mood timeline with the raw scatter points
+type MoodChartData struct {
+	Series  ChartData   `json:"series"`
+	Scatter []MoodPoint `json:"scatter"`
+}
+
+// handleMoodChart relates recorded mood to stress and score over time
+func handleMoodChart(w http.ResponseWriter, r *http.Request) {
+	granularity, err := parseGranularity(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := parseRangeDays(r, defaultAggregateDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	buckets, err := loadChartBuckets(granularity, days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	loc := requestLocalizer(r)
+	chart := MoodChartData{
+		Series:  seriesChart(loc, buckets, granularity, moodSeries),
+		Scatter: []MoodPoint{},
+	}
+	for _, b := range buckets {
+		for _, e := range b.Entries {
+			chart.Scatter = append(chart.Scatter, MoodPoint{
+				Date:   loc.Day(e.CreatedAt.In(loc.zone())) + " " + loc.Time(e.CreatedAt),
+				Stress: e.Stress,
+				Mood:   e.Mood,
+				Score:  roundTo(e.Score, 1),
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(chart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// heatmapDays is how many days the calendar heatmap covers
+const heatmapDays = 365
+
+// Heatmap buckets, matching the level thresholds used by /calculate
+const (
+	heatmapEmpty = iota
+	heatmapHealthy
+	heatmapAtRisk
+	heatmapHighRisk
+	heatmapSevere
+)
+
+// HeatmapDay is one cell of the calendar heatmap
+type HeatmapDay struct {
+	Date   string   `json:"date"`
+	Score  *float64 `json:"score"`
+	Bucket int      `json:"bucket"`
+	Count  int      `json:"count"`
+}
+
+// scoreBucket maps a score onto the heatmap colour scale
+func scoreBucket(score float64) int {
+	switch currentScoring().level(score) {
+	case levelHealthy:
+		return heatmapHealthy
+	case levelAtRisk:
+		return heatmapAtRisk
+	case levelHighRisk:
+		return heatmapHighRisk
+	}
+	return heatmapSevere
+}
+
+// handleHeatmap returns one cell per day for the last year, oldest first
+func handleHeatmap(w http.ResponseWriter, r *http.Request) {
+	today := bucketStart(time.Now(), granularityDay)
+	start := today.AddDate(0, 0, -(heatmapDays - 1))
+
+	entries, err := queryEntries(start, time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	byDay := map[string][]BurnoutEntry{}
+	for _, b := range bucketEntries(entries, granularityDay) {
+		byDay[b.Start.Format("2006-01-02")] = b.Entries
+	}
+
+	cells := make([]HeatmapDay, 0, heatmapDays)
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		cell := HeatmapDay{Date: key, Bucket: heatmapEmpty}
+		if dayEntries := byDay[key]; len(dayEntries) > 0 {
+			avg := roundTo(averageOf(dayEntries, entryScore), 1)
+			cell.Score = &avg
+			cell.Bucket = scoreBucket(avg)
+			cell.Count = len(dayEntries)
+		}
+		cells = append(cells, cell)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cells); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// defaultHistogramBins splits the 0-100 score range into 10-point bins
+const defaultHistogramBins = 10
+
+// histogram counts scores into equal-width bins over 0-100
+func histogram(entries []BurnoutEntry, bins int) []float64 {
+	counts := make([]float64, bins)
+	width := 100.0 / float64(bins)
+	for _, e := range entries {
+		i := int(e.Score / width)
+		if i >= bins {
+			i = bins - 1 // a score of exactly 100 belongs in the last bin
+		}
+		if i < 0 {
+			i = 0
+		}
+		counts[i]++
+	}
+	return counts
+}
+
+// handleDistribution returns a binned score histogram for the window, with
+// the preceding window of equal length alongside to show baseline shifts
+func handleDistribution(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultInsightDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bins := defaultHistogramBins
+	if v := r.URL.Query().Get("bins"); v != "" {
+		bins, err = strconv.Atoi(v)
+		if err != nil || bins < 2 || bins > 50 {
+			http.Error(w, fmt.Sprintf("invalid bins %q (2-50)", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	start := now.AddDate(0, 0, -days)
+	current, err := queryEntries(start, time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	previous, err := queryEntries(start.AddDate(0, 0, -days), start)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	chart := ChartData{Labels: []string{}}
+	width := 100.0 / float64(bins)
+	for i := 0; i < bins; i++ {
+		chart.Labels = append(chart.Labels, fmt.Sprintf("%.0f-%.0f", float64(i)*width, float64(i+1)*width))
+	}
+	chart.Data = histogram(current, bins)
+	chart.Datasets = []ChartDataset{
+		{Label: fmt.Sprintf("Last %d days", days), Data: chart.Data},
+		{Label: fmt.Sprintf("Previous %d days", days), Data: histogram(previous, bins)},
+	}
+	writeJSON(w, http.StatusOK, chart)
+}
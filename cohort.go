@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// minCohortSize is the fewest opted-in check-ins a group average is built
+// from, so no single person's data can be read back out of it
+const minCohortSize = 5
+
+// cohortWindow is the period the group average covers
+const cohortWindow = 7 * 24 * time.Hour
+
+// CohortComparison compares one opted-in check-in with the anonymous group
+type CohortComparison struct {
+	Available   bool           `json:"available"`
+	Reason      string         `json:"reason,omitempty"`
+	GroupSize   int            `json:"group_size"`
+	Group       CohortAverages `json:"group"`
+	Differences []string       `json:"differences"`
+}
+
+// CohortAverages are the only group figures exposed. Min/max and counts of
+// individual check-ins are deliberately left out.
+type CohortAverages struct {
+	AvgScore  float64 `json:"avg_score"`
+	AvgSleep  float64 `json:"avg_sleep"`
+	AvgStudy  float64 `json:"avg_study_hours"`
+	AvgStress float64 `json:"avg_stress"`
+}
+
+// buildCohortComparison compares entry with the other opted-in check-ins of
+// the past week. Entries are not tied to accounts, so the comparison is made
+// per check-in rather than per person.
+func buildCohortComparison(entry BurnoutEntry) (CohortComparison, error) {
+	c := CohortComparison{Differences: []string{}}
+	if !entry.ShareWithCohort {
+		c.Reason = "Opt in to anonymous group comparison to see how you compare."
+		return c, nil
+	}
+
+	since := entry.CreatedAt.Add(-cohortWindow)
+	entries, err := queryEntries(since, entry.CreatedAt.Add(time.Second))
+	if err != nil {
+		return c, err
+	}
+	var group []BurnoutEntry
+	for _, e := range entries {
+		if e.ShareWithCohort && e.ID != entry.ID {
+			group = append(group, e)
+		}
+	}
+
+	c.GroupSize = len(group)
+	if len(group) < minCohortSize {
+		c.Reason = fmt.Sprintf("Not enough participants this week yet (minimum %d).", minCohortSize)
+		c.GroupSize = 0
+		return c, nil
+	}
+
+	c.Available = true
+	stats := summarize(group)
+	c.Group = CohortAverages{
+		AvgScore:  stats.AvgScore,
+		AvgSleep:  stats.AvgSleep,
+		AvgStudy:  stats.AvgStudy,
+		AvgStress: stats.AvgStress,
+	}
+	c.Differences = append(c.Differences,
+		compareToGroup("Your sleep", entry.Sleep, c.Group.AvgSleep, "h", 0.5),
+		compareToGroup("Your study time", entry.StudyHours, c.Group.AvgStudy, "h", 0.5),
+		compareToGroup("Your stress", float64(entry.Stress), c.Group.AvgStress, " points", 0.5),
+		compareToGroup("Your burnout score", entry.Score, c.Group.AvgScore, " points", 5),
+	)
+	return c, nil
+}
+
+// compareToGroup phrases the gap between a value and the group average
+func compareToGroup(subject string, value, avg float64, unit string, tolerance float64) string {
+	diff := value - avg
+	if math.Abs(diff) < tolerance {
+		return fmt.Sprintf("%s is about the same as the group average.", subject)
+	}
+	direction := "above"
+	if diff < 0 {
+		direction = "below"
+	}
+	return fmt.Sprintf("%s is %.1f%s %s the group average.", subject, math.Abs(diff), unit, direction)
+}
+
+// handleCohortComparison compares a check-in (default: the latest) with the
+// anonymous average of opted-in check-ins
+func handleCohortComparison(w http.ResponseWriter, r *http.Request) {
+	entry, ok := entryFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	comparison, err := buildCohortComparison(entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(comparison); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
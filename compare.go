@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// compareFactor is one row of the week-over-week comparison
+type compareFactor struct {
+	Key  string
+	Name string
+	Unit string
+	// Format is the printf verb values are shown with
+	Format string
+	Field  func(BurnoutEntry) float64
+	// LowerIsBetter is true when a fall is an improvement, as for the
+	// score and everything that raises it
+	LowerIsBetter bool
+}
+
+// compareFactors are compared in this order; exercise is the share of
+// check-ins with exercise, as a percentage
+var compareFactors = []compareFactor{
+	{"score", "Burnout score", "", "%.0f", entryScore, true},
+	{"sleep", "Sleep", "h", "%.1f", entrySleep, false},
+	{"study_hours", "Study time", "h", "%.1f", entryStudyHours, true},
+	{"deadlines", "Deadlines", "", "%.1f", entryDeadlines, true},
+	{"stress", "Stress", "/5", "%.1f", entryStress, true},
+	{"mood", "Mood", "/5", "%.1f", entryMood, false},
+	{"exercise", "Exercise", "%", "%.0f", func(e BurnoutEntry) float64 { return 100 * entryExercise(e) }, false},
+}
+
+// FactorChange compares one factor's average across the two weeks. The
+// averages are nil for a week without check-ins.
+type FactorChange struct {
+	Factor   string   `json:"factor"`
+	Name     string   `json:"name"`
+	Unit     string   `json:"unit,omitempty"`
+	ThisWeek *float64 `json:"this_week"`
+	LastWeek *float64 `json:"last_week"`
+	Delta    *float64 `json:"delta"`
+	// Trend is "up", "down" or "flat"; Verdict says whether that is
+	// "better", "worse" or "same" for wellbeing
+	Trend   string `json:"trend,omitempty"`
+	Verdict string `json:"verdict,omitempty"`
+
+	format string
+}
+
+// Show formats an average or delta for the page; "–" when missing
+func (c FactorChange) Show(v *float64) string {
+	if v == nil {
+		return "–"
+	}
+	return fmt.Sprintf(c.format, *v) + c.Unit
+}
+
+// ShowDelta formats the delta with its sign
+func (c FactorChange) ShowDelta() string {
+	if c.Delta == nil {
+		return "–"
+	}
+	return fmt.Sprintf("%+"+c.format[1:], *c.Delta) + c.Unit
+}
+
+// WeekComparison is the payload of /api/compare and the data behind
+// templates/compare.html
+type WeekComparison struct {
+	WeekStart     string         `json:"week_start"`
+	PrevWeekStart string         `json:"previous_week_start"`
+	ThisCheckIns  int            `json:"this_week_check_ins"`
+	LastCheckIns  int            `json:"last_week_check_ins"`
+	Factors       []FactorChange `json:"factors"`
+
+	// NextWeekStart pages forward on /compare; empty for the current week
+	NextWeekStart string `json:"-"`
+}
+
+// compareWeeks compares the Monday-based week containing day with the
+// week before it, using the same week boundaries as the weekly summary
+func compareWeeks(day time.Time) (WeekComparison, error) {
+	start := bucketStart(day, granularityWeek)
+	prevStart := start.AddDate(0, 0, -7)
+	current, err := queryEntries(start, start.AddDate(0, 0, 7))
+	if err != nil {
+		return WeekComparison{}, err
+	}
+	previous, err := queryEntries(prevStart, start)
+	if err != nil {
+		return WeekComparison{}, err
+	}
+
+	c := WeekComparison{
+		WeekStart:     start.Format("2006-01-02"),
+		PrevWeekStart: prevStart.Format("2006-01-02"),
+		ThisCheckIns:  len(current),
+		LastCheckIns:  len(previous),
+	}
+	if next := start.AddDate(0, 0, 7); !next.After(time.Now().UTC()) {
+		c.NextWeekStart = next.Format("2006-01-02")
+	}
+	average := func(entries []BurnoutEntry, f compareFactor) *float64 {
+		if len(entries) == 0 {
+			return nil
+		}
+		v := roundTo(averageOf(entries, f.Field), 1)
+		return &v
+	}
+	for _, f := range compareFactors {
+		change := FactorChange{Factor: f.Key, Name: f.Name, Unit: f.Unit, format: f.Format,
+			ThisWeek: average(current, f), LastWeek: average(previous, f)}
+		if change.ThisWeek != nil && change.LastWeek != nil {
+			delta := roundTo(*change.ThisWeek-*change.LastWeek, 1)
+			change.Delta = &delta
+			switch {
+			case delta > 0:
+				change.Trend = "up"
+			case delta < 0:
+				change.Trend = "down"
+			default:
+				change.Trend, change.Verdict = "flat", "same"
+			}
+			if change.Verdict == "" {
+				change.Verdict = "worse"
+				if (delta < 0) == f.LowerIsBetter {
+					change.Verdict = "better"
+				}
+			}
+		}
+		c.Factors = append(c.Factors, change)
+	}
+	return c, nil
+}
+
+// handleCompare renders /compare, or returns JSON at /api/compare. Both
+// take ?week=YYYY-MM-DD, any day of the week to compare with the one
+// before; the default is this week.
+func handleCompare(w http.ResponseWriter, r *http.Request) {
+	day, err := parseDateParam(r, "week", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c, err := compareWeeks(day)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Path == "/api/compare" {
+		writeJSON(w, http.StatusOK, c)
+		return
+	}
+	tmpl, err := loadTemplate("compare.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, c)
+}
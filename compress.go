@@ -0,0 +1,185 @@
+package main
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compressibleTypes are the content types worth compressing: pages, HTMX
+// fragments, JSON and the text exports. Images, spreadsheets and Parquet
+// are compressed already.
+var compressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/fhir+json",
+	"application/x-ndjson",
+	"application/javascript",
+	"application/manifest+json",
+	"application/xml",
+	"application/atom+xml",
+	"image/svg+xml",
+}
+
+// minCompressSize skips responses that announce a smaller Content-Length,
+// where the gzip header would outweigh the saving
+const minCompressSize = 512
+
+var gzipWriters = sync.Pool{New: func() any {
+	w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+	return w
+}}
+
+var flateWriters = sync.Pool{New: func() any {
+	w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+	return w
+}}
+
+// compress gzips (or deflates) text responses for clients that accept it.
+// It is the outermost handler so everything written on the way out, such
+// as accessLog's request ID on errors, goes through the same stream.
+func compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == "HEAD" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding picks gzip, then deflate, from an Accept-Encoding
+// header, honouring q=0 as a refusal
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, _ = strconv.ParseFloat(v, 64)
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+	for _, enc := range []string{"gzip", "deflate"} {
+		if accepted[enc] {
+			return enc
+		}
+	}
+	return ""
+}
+
+// compressWriter decides on the first write whether the response is worth
+// compressing, from the headers the handler set by then
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	decided     bool
+	wroteHeader bool
+	enc         interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.wroteHeader {
+		c.ResponseWriter.WriteHeader(code)
+		return
+	}
+	c.wroteHeader = true
+	c.decide(code)
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		// net/http would sniff the type after this point, too late to
+		// decide on compression, so do it here
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.enc != nil {
+		return c.enc.Write(b)
+	}
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *compressWriter) decide(code int) {
+	if c.decided {
+		return
+	}
+	c.decided = true
+	h := c.Header()
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent ||
+		h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || !compressible(h.Get("Content-Type")) {
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < minCompressSize {
+		return
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", c.encoding)
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag) // the compressed bytes differ from the original
+	}
+	if c.encoding == "gzip" {
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(c.ResponseWriter)
+		c.enc = gz
+	} else {
+		fl := flateWriters.Get().(*flate.Writer)
+		fl.Reset(c.ResponseWriter)
+		c.enc = fl
+	}
+}
+
+// Flush pushes out what has been compressed so far, which keeps the
+// streaming exports streaming
+func (c *compressWriter) Flush() {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.enc != nil {
+		c.enc.Flush()
+	}
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close finishes the compressed stream and returns the encoder to its pool
+func (c *compressWriter) Close() {
+	if c.enc == nil {
+		return
+	}
+	c.enc.Close()
+	switch enc := c.enc.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(enc)
+	case *flate.Writer:
+		flateWriters.Put(enc)
+	}
+	c.enc = nil
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (c *compressWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+func compressible(contentType string) bool {
+	for _, t := range compressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
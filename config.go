@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// serverConfig is where the server listens and what it reads from disk
+type serverConfig struct {
+	// ConfigFile is an optional TOML file filling in whatever the
+	// environment and flags leave unset (see configfile.go)
+	ConfigFile string
+
+	Port         string
+	DBPath       string
+	TemplatesDir string
+	// BaseURL is the public origin, e.g. https://wellness.example.edu, used
+	// for absolute links; empty means the host each request came in on
+	BaseURL string
+
+	// HTTPS, see tls.go: either a certificate and key, or domains to get
+	// certificates for automatically
+	TLSCert     string
+	TLSKey      string
+	TLSDomains  string
+	TLSEmail    string
+	TLSCacheDir string
+	// HTTPPort, when serving HTTPS, is a plain HTTP listener that only
+	// redirects to HTTPS (and answers ACME challenges)
+	HTTPPort string
+
+	// Socket, when set, is a Unix domain socket to listen on instead of
+	// Port, created with SocketPerm (octal, e.g. 0660). A socket passed by
+	// systemd takes precedence over both; see listen.go.
+	Socket     string
+	SocketPerm string
+	SocketMode os.FileMode
+
+	// LogLevel is debug, info, warn or error; LogFormat is text or json
+	LogLevel  string
+	LogFormat string
+
+	// Connection and handler timeouts, see timeouts.go
+	ReadTimeout    time.Duration
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	RequestTimeout time.Duration
+}
+
+// server is the running instance's configuration, set at startup
+var server serverConfig
+
+// registerServerFlags declares the command-line flags. Each falls back to
+// its environment variable, then to the config file, then to a default:
+//
+//	-config     CONFIG_FILE    TOML config file
+//	-port       PORT           listen port (8081)
+//	-socket     UNIX_SOCKET    Unix domain socket to listen on instead
+//	-db         DB_PATH        SQLite database file (./burnout.db)
+//	-templates  TEMPLATES_DIR  directory holding the page templates (templates)
+//	-base-url   BASE_URL       public origin for absolute links
+//	-tls-cert   TLS_CERT_FILE  certificate for HTTPS, with -tls-key
+//	-tls-key    TLS_KEY_FILE   its private key
+//	-tls-domains TLS_DOMAINS   comma-separated domains for Let's Encrypt
+//	-tls-email  TLS_EMAIL      contact address for Let's Encrypt
+//	-tls-cache  TLS_CACHE_DIR  where automatic certificates are kept (certs)
+//	-http-port  HTTP_PORT      plain HTTP port redirecting to HTTPS
+//	-log-level  LOG_LEVEL      debug, info, warn or error (info)
+//	-log-format LOG_FORMAT     text or json (text)
+func registerServerFlags(c *serverConfig) {
+	flag.StringVar(&c.ConfigFile, "config", os.Getenv("CONFIG_FILE"), "TOML config file (env CONFIG_FILE)")
+	flag.StringVar(&c.Port, "port", "", "listen port (env PORT, default 8081)")
+	flag.StringVar(&c.Socket, "socket", "", "Unix domain socket to listen on instead of the port (env UNIX_SOCKET; mode from UNIX_SOCKET_MODE, default 0660)")
+	flag.StringVar(&c.DBPath, "db", "", "SQLite database file (env DB_PATH, default ./burnout.db)")
+	flag.StringVar(&c.TemplatesDir, "templates", "", "directory holding the page templates (env TEMPLATES_DIR, default templates)")
+	flag.StringVar(&c.BaseURL, "base-url", "", "public origin for absolute links, e.g. https://wellness.example.edu (env BASE_URL)")
+	flag.StringVar(&c.TLSCert, "tls-cert", "", "certificate file for HTTPS, used with -tls-key (env TLS_CERT_FILE)")
+	flag.StringVar(&c.TLSKey, "tls-key", "", "private key file for HTTPS (env TLS_KEY_FILE)")
+	flag.StringVar(&c.TLSDomains, "tls-domains", "", "comma-separated domains to get Let's Encrypt certificates for (env TLS_DOMAINS)")
+	flag.StringVar(&c.TLSEmail, "tls-email", "", "contact address for Let's Encrypt (env TLS_EMAIL)")
+	flag.StringVar(&c.TLSCacheDir, "tls-cache", "", "directory for automatic certificates (env TLS_CACHE_DIR, default certs)")
+	flag.StringVar(&c.HTTPPort, "http-port", "", "with HTTPS, a plain HTTP port that redirects to it, e.g. 80 (env HTTP_PORT)")
+	flag.StringVar(&c.LogLevel, "log-level", "", "debug, info, warn or error (env LOG_LEVEL, default info)")
+	flag.StringVar(&c.LogFormat, "log-format", "", "text or json (env LOG_FORMAT, default text)")
+}
+
+// resolve applies the config file, fills whatever the flags left unset and
+// validates the result, so a typo stops the server at startup instead of on
+// the first request
+func (c *serverConfig) resolve() error {
+	if c.ConfigFile != "" {
+		if err := loadConfigFile(c.ConfigFile); err != nil {
+			return err
+		}
+	}
+	for _, f := range []struct {
+		v        *string
+		env, def string
+	}{
+		{&c.Port, "PORT", "8081"},
+		{&c.Socket, "UNIX_SOCKET", ""},
+		{&c.SocketPerm, "UNIX_SOCKET_MODE", "0660"},
+		{&c.DBPath, "DB_PATH", "./burnout.db"},
+		{&c.TemplatesDir, "TEMPLATES_DIR", "templates"},
+		{&c.BaseURL, "BASE_URL", ""},
+		{&c.TLSCert, "TLS_CERT_FILE", ""},
+		{&c.TLSKey, "TLS_KEY_FILE", ""},
+		{&c.TLSDomains, "TLS_DOMAINS", ""},
+		{&c.TLSEmail, "TLS_EMAIL", ""},
+		{&c.TLSCacheDir, "TLS_CACHE_DIR", "certs"},
+		{&c.HTTPPort, "HTTP_PORT", ""},
+		{&c.LogLevel, "LOG_LEVEL", "info"},
+		{&c.LogFormat, "LOG_FORMAT", "text"},
+	} {
+		if *f.v == "" {
+			*f.v = envOr(f.env, f.def)
+		}
+	}
+
+	c.LogLevel = strings.ToLower(c.LogLevel)
+	if err := c.validateLogging(); err != nil {
+		return err
+	}
+	if err := c.resolveTimeouts(); err != nil {
+		return err
+	}
+	if !validPort(c.Port) {
+		return fmt.Errorf("config: port must be 1-65535, got %q", c.Port)
+	}
+	mode, err := strconv.ParseUint(c.SocketPerm, 8, 32)
+	if err != nil || mode > 0o777 {
+		return fmt.Errorf("config: unix socket mode must be octal permissions such as 0660, got %q", c.SocketPerm)
+	}
+	c.SocketMode = os.FileMode(mode)
+	if c.DBPath == "" {
+		return fmt.Errorf("config: database path is empty")
+	}
+	if info, err := os.Stat(filepath.Dir(c.DBPath)); err != nil || !info.IsDir() {
+		return fmt.Errorf("config: directory for database %s does not exist", c.DBPath)
+	}
+	if info, err := os.Stat(c.TemplatesDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("config: templates directory %q does not exist", c.TemplatesDir)
+	}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("config: base URL must look like https://host[/path], got %q", c.BaseURL)
+		}
+		c.BaseURL = strings.TrimSuffix(c.BaseURL, "/")
+	}
+	return c.validateTLS()
+}
+
+// validPort reports whether p is a TCP port number
+func validPort(p string) bool {
+	n, err := strconv.Atoi(p)
+	return err == nil && n >= 1 && n <= 65535
+}
+
+// addr is the listen address for http.Server
+func (c serverConfig) addr() string { return ":" + c.Port }
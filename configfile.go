@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configKey is one setting a config file may hold. Most stand in for an
+// environment variable, so the existing loaders read them unchanged;
+// the scoring ones set a formula weight directly.
+type configKey struct {
+	Env      string
+	Weight   *float64
+	Required bool // must be present whenever its section is
+}
+
+// configSchema lists every section and key a config file may use. Keys are
+// the lower-case form of the environment variable without its prefix.
+var configSchema = map[string]map[string]configKey{
+	"server": {
+		"port":            {Env: "PORT"},
+		"socket":          {Env: "UNIX_SOCKET"},
+		"socket_mode":     {Env: "UNIX_SOCKET_MODE"},
+		"db_path":         {Env: "DB_PATH"},
+		"templates_dir":   {Env: "TEMPLATES_DIR"},
+		"base_url":        {Env: "BASE_URL"},
+		"tls_cert":        {Env: "TLS_CERT_FILE"},
+		"tls_key":         {Env: "TLS_KEY_FILE"},
+		"tls_domains":     {Env: "TLS_DOMAINS"},
+		"tls_email":       {Env: "TLS_EMAIL"},
+		"tls_cache_dir":   {Env: "TLS_CACHE_DIR"},
+		"http_port":       {Env: "HTTP_PORT"},
+		"read_timeout":    {Env: "HTTP_READ_TIMEOUT"},
+		"write_timeout":   {Env: "HTTP_WRITE_TIMEOUT"},
+		"idle_timeout":    {Env: "HTTP_IDLE_TIMEOUT"},
+		"request_timeout": {Env: "REQUEST_TIMEOUT"},
+	},
+	"database": {
+		"max_open_conns":    {Env: "DB_MAX_OPEN_CONNS"},
+		"max_idle_conns":    {Env: "DB_MAX_IDLE_CONNS"},
+		"conn_max_lifetime": {Env: "DB_CONN_MAX_LIFETIME"},
+		"busy_retries":      {Env: "DB_BUSY_RETRIES"},
+		"state_backend":     {Env: "STATE_BACKEND"},
+	},
+	"features": {
+		"flags": {Env: "FEATURE_FLAGS"},
+	},
+	"queue": {
+		"workers": {Env: "QUEUE_WORKERS"},
+		"poll":    {Env: "QUEUE_POLL"},
+	},
+	"log": {
+		"level":  {Env: "LOG_LEVEL"},
+		"format": {Env: "LOG_FORMAT"},
+	},
+	"admin": {
+		"token": {Env: "ADMIN_TOKEN", Required: true},
+	},
+	"metrics": {
+		"token": {Env: "METRICS_TOKEN", Required: true},
+	},
+	"errors": {
+		"sentry_dsn":  {Env: "SENTRY_DSN", Required: true},
+		"environment": {Env: "SENTRY_ENVIRONMENT"},
+		"release":     {Env: "SENTRY_RELEASE"},
+	},
+	"tracing": {
+		"endpoint":        {Env: "OTEL_EXPORTER_OTLP_ENDPOINT"},
+		"traces_endpoint": {Env: "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"},
+		"headers":         {Env: "OTEL_EXPORTER_OTLP_HEADERS"},
+		"service_name":    {Env: "OTEL_SERVICE_NAME"},
+		"sample_ratio":    {Env: "OTEL_TRACES_SAMPLER_ARG"},
+	},
+	"brand": {
+		"name":        {Env: "BRAND_NAME"},
+		"logo":        {Env: "BRAND_LOGO"},
+		"accent":      {Env: "BRAND_ACCENT"},
+		"accent_dark": {Env: "BRAND_ACCENT_DARK"},
+		"footer":      {Env: "BRAND_FOOTER"},
+	},
+	"resources": {
+		"file":   {Env: "RESOURCES_FILE"},
+		"region": {Env: "RESOURCES_REGION"},
+	},
+	"kiosk": {
+		"locations": {Env: "KIOSK_LOCATIONS", Required: true},
+		"codes":     {Env: "KIOSK_CODES"},
+		"secret":    {Env: "KIOSK_SECRET"},
+		"pin":       {Env: "KIOSK_PIN"},
+	},
+	"backup": {
+		"s3_endpoint":    {Env: "BACKUP_S3_ENDPOINT"},
+		"s3_bucket":      {Env: "BACKUP_S3_BUCKET", Required: true},
+		"s3_region":      {Env: "BACKUP_S3_REGION"},
+		"s3_access_key":  {Env: "BACKUP_S3_ACCESS_KEY"},
+		"s3_secret_key":  {Env: "BACKUP_S3_SECRET_KEY"},
+		"s3_prefix":      {Env: "BACKUP_S3_PREFIX"},
+		"encryption_key": {Env: "BACKUP_ENCRYPTION_KEY", Required: true},
+		"retain":         {Env: "BACKUP_RETAIN"},
+		"interval":       {Env: "BACKUP_INTERVAL"},
+	},
+	"export": {
+		"destination":    {Env: "EXPORT_DESTINATION", Required: true},
+		"format":         {Env: "EXPORT_FORMAT"},
+		"interval":       {Env: "EXPORT_INTERVAL"},
+		"webhook_secret": {Env: "EXPORT_WEBHOOK_SECRET"},
+		"anonymize":      {Env: "EXPORT_ANONYMIZE"},
+		"anon_salt":      {Env: "EXPORT_ANON_SALT"},
+	},
+	"scoring": {
+		"deadline_weight":      {Weight: &configuredScoring.DeadlineWeight},
+		"stress_weight":        {Weight: &configuredScoring.StressWeight},
+		"sleep_target":         {Weight: &configuredScoring.SleepTarget},
+		"sleep_weight":         {Weight: &configuredScoring.SleepWeight},
+		"study_weight":         {Weight: &configuredScoring.StudyWeight},
+		"exercise_recovery":    {Weight: &configuredScoring.ExerciseRecovery},
+		"habit_recovery_bonus": {Weight: &configuredScoring.HabitRecoveryBonus},
+	},
+	"thresholds": {
+		"healthy_max":   {Weight: &configuredScoring.HealthyMax},
+		"at_risk_max":   {Weight: &configuredScoring.AtRiskMax},
+		"high_risk_max": {Weight: &configuredScoring.HighRiskMax},
+	},
+}
+
+// loadConfigFile applies a TOML config file. Environment variables and
+// flags win over the file, so a value from it is only used when the
+// matching variable is unset. The file uses a TOML subset: [section]
+// headers, key = value lines with quoted strings or numbers, and #
+// comments. Any unknown section or key is an error, with a suggestion when
+// it looks like a typo.
+func loadConfigFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	defer f.Close()
+
+	seen := map[string]map[string]bool{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		where := fmt.Sprintf("%s:%d", path, n)
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("%s: section header %q is missing its closing ]", where, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := configSchema[section]; !ok {
+				return fmt.Errorf("%s: unknown section [%s]%s", where, section, suggest(section, sortedKeys(configSchema)))
+			}
+			if seen[section] != nil {
+				return fmt.Errorf("%s: section [%s] appears twice", where, section)
+			}
+			seen[section] = map[string]bool{}
+			continue
+		}
+
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s: expected key = value, got %q", where, line)
+		}
+		name = strings.TrimSpace(name)
+		if section == "" {
+			return fmt.Errorf("%s: key %q must be inside a [section]", where, name)
+		}
+		keys := configSchema[section]
+		key, ok := keys[name]
+		if !ok {
+			return fmt.Errorf("%s: unknown key %q in [%s]%s", where, name, section, suggest(name, sortedKeys(keys)))
+		}
+		if seen[section][name] {
+			return fmt.Errorf("%s: %s.%s is set twice", where, section, name)
+		}
+		seen[section][name] = true
+
+		value, quoted, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s: %s.%s: %v", where, section, name, err)
+		}
+		if key.Weight != nil {
+			v, err := strconv.ParseFloat(value, 64)
+			if quoted || err != nil {
+				return fmt.Errorf("%s: %s.%s must be a number", where, section, name)
+			}
+			*key.Weight = v
+			continue
+		}
+		if os.Getenv(key.Env) == "" {
+			os.Setenv(key.Env, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	for _, section := range sortedKeys(seen) {
+		for _, name := range sortedKeys(configSchema[section]) {
+			key := configSchema[section][name]
+			if key.Required && !seen[section][name] && os.Getenv(key.Env) == "" {
+				return fmt.Errorf("%s: [%s] is missing %s", path, section, name)
+			}
+		}
+	}
+	return configuredScoring.validate()
+}
+
+// stripComment drops a # comment that is not inside a quoted string
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// parseConfigValue reads a quoted string, a number or a boolean, returning
+// it as text and whether it was quoted
+func parseConfigValue(raw string) (string, bool, error) {
+	if strings.HasPrefix(raw, `"`) {
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", false, fmt.Errorf("bad string %s", raw)
+		}
+		return s, true, nil
+	}
+	if raw == "true" || raw == "false" {
+		return raw, false, nil
+	}
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(raw, "_", ""), 64); err != nil {
+		return "", false, fmt.Errorf("value %s must be a quoted string, a number or true/false", raw)
+	}
+	return strings.ReplaceAll(raw, "_", ""), false, nil
+}
+
+// suggest names the closest known word when name looks like a typo of it,
+// otherwise lists them all
+func suggest(name string, known []string) string {
+	best, bestDist := "", 3
+	for _, k := range known {
+		if d := editDistance(name, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf(" (did you mean %q?)", best)
+	}
+	return " (known: " + strings.Join(known, ", ") + ")"
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// sortedKeys returns a map's keys in order, for stable messages
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
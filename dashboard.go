@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"time"
+)
+
+// Dashboard is the summary panel at the top of the index page, built from
+// the same data as /api/stats and /api/summary/weekly
+type Dashboard struct {
+	Stats Stats
+	Week  WeeklySummary
+	// LastLevel is the catalog key for the level of the latest score
+	LastScore float64
+	LastLevel string
+	// WeekDelta is this week's average score minus last week's; nil until
+	// both weeks have check-ins
+	WeekDelta *float64
+	// CheckedInToday is true once there is a check-in for the local date
+	CheckedInToday bool
+	// Reminders are today's reminder times still ahead; they only apply
+	// while there is no check-in today
+	Reminders    []string
+	HasReminders bool
+}
+
+// buildDashboard gathers the panel's numbers as of now, in the user's zone
+func buildDashboard(now time.Time) (Dashboard, error) {
+	var d Dashboard
+	var err error
+	if d.Stats, err = loadStats(); err != nil {
+		return d, err
+	}
+	if d.Stats.LastScore != nil {
+		d.LastScore = *d.Stats.LastScore
+		d.LastLevel = "level." + levelCode(d.LastScore)
+	}
+	if d.Week, err = buildWeeklySummary(now); err != nil {
+		return d, err
+	}
+	if delta, ok := d.Week.Deltas["avg_score"]; ok {
+		d.WeekDelta = &delta
+	}
+
+	recent, err := queryRecentEntries(1)
+	if err != nil {
+		return d, err
+	}
+	if len(recent) == 1 {
+		d.CheckedInToday = recent[0].CreatedAt.In(now.Location()).Format("2006-01-02") == now.Format("2006-01-02")
+	}
+
+	settings, err := loadSettings()
+	if err != nil {
+		return d, err
+	}
+	d.HasReminders = len(settings.ReminderTimes) > 0
+	d.Reminders = []string{}
+	if !d.CheckedInToday {
+		clock := now.Format("15:04")
+		for _, t := range settings.ReminderTimes {
+			if t > clock {
+				d.Reminders = append(d.Reminders, t)
+			}
+		}
+	}
+	return d, nil
+}
+
+// Trend describes the weekly change for the template: "up", "down" or
+// "flat" (within half a point), or "" when there is nothing to compare
+func (d Dashboard) Trend() string {
+	switch {
+	case d.WeekDelta == nil:
+		return ""
+	case *d.WeekDelta >= 0.5:
+		return "up"
+	case *d.WeekDelta <= -0.5:
+		return "down"
+	}
+	return "flat"
+}
+
+// AbsDelta is the size of the weekly change, for display
+func (d Dashboard) AbsDelta() float64 {
+	if d.WeekDelta == nil {
+		return 0
+	}
+	return math.Abs(*d.WeekDelta)
+}
+
+// handleDashboard returns the dashboard panel as an HTML fragment. The
+// index page reloads it after every new check-in.
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	d, err := buildDashboard(time.Now().In(loadZone()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl, _, err := localizedTemplate("index.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.ExecuteTemplate(w, "dashboard", d)
+}
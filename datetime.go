@@ -0,0 +1,148 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // IANA zones even on container images without them
+)
+
+// Date and time layouts come from the catalogs, so each language orders
+// day, month and year its own way:
+//
+//	format.date        Jan 2, 2006
+//	format.date_long   January 2, 2006
+//	format.day         Jan 02 (chart and report labels)
+//	format.datetime    Jan 2, 2006 15:04
+//	format.time        15:04
+//
+// Month and weekday names are comma-separated lists in format.months,
+// format.months_short, format.weekdays and format.weekdays_short, Sunday
+// first.
+
+// nameTokens are the layout elements replaced by translated names,
+// longest first so "January" is not read as "Jan"
+var nameTokens = []string{"January", "Monday", "Jan", "Mon"}
+
+// zoneCache holds loadZone's answer until the next write. Every entry a
+// chart or report buckets needs the zone, and it can only change when the
+// settings are saved.
+var zoneCache struct {
+	sync.Mutex
+	generation uint64
+	zone       *time.Location
+}
+
+// loadZone returns the time zone from settings; "auto" is the server's.
+// Days, weeks and months are all cut in this zone.
+func loadZone() *time.Location {
+	generation := state.Generation()
+	zoneCache.Lock()
+	defer zoneCache.Unlock()
+	if zoneCache.zone != nil && zoneCache.generation == generation {
+		return zoneCache.zone
+	}
+	zone := time.Local
+	if s, err := loadSettings(); err == nil && s.Timezone != "auto" {
+		if z, err := time.LoadLocation(s.Timezone); err == nil {
+			zone = z
+		}
+	}
+	zoneCache.generation, zoneCache.zone = generation, zone
+	return zone
+}
+
+// parseDay reads a YYYY-MM-DD calendar day as its midnight in the user's
+// zone, so it lines up with bucketStart
+func parseDay(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", s, loadZone())
+}
+
+// dayKey is the YYYY-MM-DD calendar day t falls on in the user's zone
+func dayKey(t time.Time) string {
+	return t.In(loadZone()).Format("2006-01-02")
+}
+
+// zone is where instants are shown; the zero localizer uses the server's
+func (l localizer) zone() *time.Location {
+	if l.Zone == nil {
+		return time.Local
+	}
+	return l.Zone
+}
+
+// Format renders t with a Go layout, translating month and weekday names.
+// It does not change t's zone, so it also suits calendar days such as
+// chart buckets.
+func (l localizer) Format(t time.Time, layout string) string {
+	var out strings.Builder
+	for layout != "" {
+		i, token := len(layout), ""
+		for _, name := range nameTokens {
+			if j := strings.Index(layout, name); j >= 0 && (j < i || j == i && len(name) > len(token)) {
+				i, token = j, name
+			}
+		}
+		out.WriteString(t.Format(layout[:i]))
+		if token == "" {
+			break
+		}
+		out.WriteString(l.name(t, token))
+		layout = layout[i+len(token):]
+	}
+	return out.String()
+}
+
+// name translates one month or weekday token, falling back to Go's
+// English names when the catalog has no list
+func (l localizer) name(t time.Time, token string) string {
+	id, index := "format.months", int(t.Month())-1
+	switch token {
+	case "Jan":
+		id = "format.months_short"
+	case "Monday":
+		id, index = "format.weekdays", int(t.Weekday())
+	case "Mon":
+		id, index = "format.weekdays_short", int(t.Weekday())
+	}
+	if names := strings.Split(l.T(id), ","); len(names) > index && id != l.T(id) {
+		return strings.TrimSpace(names[index])
+	}
+	return t.Format(token)
+}
+
+// Date shows an instant's date in the user's zone
+func (l localizer) Date(t time.Time) string {
+	return l.Format(t.In(l.zone()), l.T("format.date"))
+}
+
+// DateLong is Date with the month spelled out, for reports
+func (l localizer) DateLong(t time.Time) string {
+	return l.Format(t.In(l.zone()), l.T("format.date_long"))
+}
+
+// DateTime shows an instant's date and time in the user's zone
+func (l localizer) DateTime(t time.Time) string {
+	return l.Format(t.In(l.zone()), l.T("format.datetime"))
+}
+
+// Time shows an instant's time of day in the user's zone
+func (l localizer) Time(t time.Time) string {
+	return l.Format(t.In(l.zone()), l.T("format.time"))
+}
+
+// Day labels a calendar day, e.g. a chart bucket, without zone conversion
+func (l localizer) Day(t time.Time) string { return l.Format(t, l.T("format.day")) }
+
+// Weekday is the short name of t's day of the week
+func (l localizer) Weekday(t time.Time) string { return l.name(t, "Mon") }
+
+// ZoneName is shown next to times, e.g. in reports: the IANA name, or the
+// abbreviation when following the server's zone
+func (l localizer) ZoneName() string {
+	if zone := l.zone(); zone != time.Local {
+		return zone.String()
+	}
+	abbr, _ := time.Now().Zone()
+	return abbr
+}
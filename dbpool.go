@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dbPoolConfig holds the DB_* connection pool settings
+type dbPoolConfig struct {
+	// MaxOpen may be 1. Code holding a connection, in a transaction or
+	// while reading rows, must not query db before letting go of it, or
+	// it waits on itself; TestSingleConnection checks the handlers.
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+	// BusyRetries is how many more times a statement that found the
+	// database locked is tried before the user sees errDatabaseBusy. Each
+	// attempt already waits out the driver's own 5s busy timeout, so this
+	// covers bursts and long writes such as a backup, not a stuck lock.
+	BusyRetries int
+}
+
+// dbPool is the running instance's pool configuration, set at startup
+var dbPool = dbPoolConfig{MaxOpen: 8, MaxIdle: 4, MaxLifetime: 30 * time.Minute, BusyRetries: 5}
+
+// errDatabaseBusy replaces SQLite's "database is locked" once the retries
+// run out; handlers pass it straight to http.Error, so it is worded for
+// the person who submitted the form
+var errDatabaseBusy = errors.New("the database is busy right now, please try again in a moment")
+
+// loadDBPoolConfig reads the environment over the defaults in dbPool
+func loadDBPoolConfig() (dbPoolConfig, error) {
+	cfg := dbPool
+	for _, f := range []struct {
+		env string
+		v   *int
+		min int
+	}{
+		{"DB_MAX_OPEN_CONNS", &cfg.MaxOpen, 1},
+		{"DB_MAX_IDLE_CONNS", &cfg.MaxIdle, 0},
+		{"DB_BUSY_RETRIES", &cfg.BusyRetries, 0},
+	} {
+		if v := os.Getenv(f.env); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < f.min {
+				return cfg, fmt.Errorf("database: %s must be a whole number of at least %d, got %q", f.env, f.min, v)
+			}
+			*f.v = n
+		}
+	}
+	if v := os.Getenv("DB_CONN_MAX_LIFETIME"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("database: DB_CONN_MAX_LIFETIME must be a duration such as 30m (0 keeps connections forever), got %q", v)
+		}
+		cfg.MaxLifetime = d
+	}
+	if cfg.MaxIdle > cfg.MaxOpen {
+		cfg.MaxIdle = cfg.MaxOpen
+	}
+	return cfg, nil
+}
+
+// apply sizes db's connection pool
+func (c dbPoolConfig) apply(db *sql.DB) {
+	db.SetMaxOpenConns(c.MaxOpen)
+	db.SetMaxIdleConns(c.MaxIdle)
+	db.SetConnMaxLifetime(c.MaxLifetime)
+}
+
+// isBusy reports whether err is SQLite saying another connection holds
+// the lock
+func isBusy(err error) bool {
+	var serr sqlite3.Error
+	return errors.As(err, &serr) && (serr.Code == sqlite3.ErrBusy || serr.Code == sqlite3.ErrLocked)
+}
+
+// busyBackoff is the wait before retry n (from 0): exponential from 10ms,
+// capped at 500ms, with full jitter so writers that collided don't
+// collide again
+func busyBackoff(n int) time.Duration {
+	d := min(10*time.Millisecond<<n, 500*time.Millisecond)
+	return time.Duration(rand.Int64N(int64(d)) + 1)
+}
+
+// retryBusy runs one statement, timing every attempt for /metrics, and
+// retries it with backoff while the database is locked. Inside a traced
+// request or job the statement, retries included, is a span; its
+// arguments are left out, as they hold people's answers.
+func retryBusy[T any](ctx context.Context, query string, run func() (T, error)) (v T, err error) {
+	op := queryOperation(query)
+	_, span := startChildSpan(ctx, "db "+op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemNameSQLite, semconv.DBOperationName(op), semconv.DBQueryText(query)))
+	defer func() { endSpan(span, err) }()
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			span.SetAttributes(attribute.Int("db.busy_retries", attempt))
+		}
+		start := time.Now()
+		v, err := run()
+		observeQuery(query, start, err)
+		if !isBusy(err) {
+			return v, err
+		}
+		if attempt >= dbPool.BusyRetries {
+			slog.Warn("database busy, giving up", "attempts", attempt+1, "err", err)
+			return v, errDatabaseBusy
+		}
+		select {
+		case <-ctx.Done():
+			return v, err
+		case <-time.After(busyBackoff(attempt)):
+		}
+	}
+}
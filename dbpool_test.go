@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestDatabase points db at a fresh database in a temporary directory,
+// migrated and filled with the demo data, with a pool of size maxOpen
+func useTestDatabase(t *testing.T, maxOpen int) {
+	t.Helper()
+	conn, err := sql.Open(timedDriverName, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbPoolConfig{MaxOpen: maxOpen, MaxIdle: maxOpen}.apply(conn)
+	previousDB, previousState := db, state
+	db, state = conn, newMemoryState()
+	t.Cleanup(func() {
+		// A handler stuck on the connection would block closing it
+		if !t.Failed() {
+			closeStatements()
+			conn.Close()
+		}
+		db, state = previousDB, previousState
+	})
+	if err := runMigrations(); err != nil {
+		t.Fatal(err)
+	}
+	if err := prepareStatements(); err != nil {
+		t.Fatal(err)
+	}
+	if err := seedDemoData(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := templates.load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveWithin runs one request and fails if it does not finish in time,
+// which with a single connection means something waited on a second one
+func serveWithin(t *testing.T, h http.HandlerFunc, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h(w, r)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s %s did not finish: a query waited for a second connection", r.Method, r.URL)
+	}
+	return w
+}
+
+// TestSingleConnection serves the pages and API calls that read and write
+// the entries with DB_MAX_OPEN_CONNS=1. A handler that queries db while a
+// transaction or an open result set holds the connection hangs here.
+func TestSingleConnection(t *testing.T) {
+	queued := `{"entries":[{"client_id":"7d444840-9dc0-11d1-b245-5ffdce74fad2","sleep":6,"study_hours":5,"mood":3,"stress":4},
+		{"client_id":"7d444840-9dc0-11d1-b245-5ffdce74fad3","mood":2,"stress":5,"exercise":true}]}`
+	cases := []struct {
+		method, target string
+		handler        http.HandlerFunc
+		path           map[string]string
+		body           string
+	}{
+		{"GET", "/", handleIndex, nil, ""},
+		{"POST", "/calculate", handleCalculate, nil, "sleep=6&study=5&deadlines=2&mood=3&stress=4"},
+		{"POST", "/api/sync", handleSync, nil, queued},
+		{"GET", "/history-chart?granularity=day", handleChartData, nil, ""},
+		{"GET", "/api/stats", handleStats, nil, ""},
+		{"GET", "/api/summary/weekly", handleWeeklySummary, nil, ""},
+		{"GET", "/api/compare", handleCompare, nil, ""},
+		{"GET", "/api/reports/monthly", handleMonthlyReport, nil, ""},
+		{"GET", "/api/charts/factors", handleFactorChart, nil, ""},
+		{"GET", "/api/charts/mood", handleMoodChart, nil, ""},
+		{"GET", "/api/charts/heatmap", handleHeatmap, nil, ""},
+		{"GET", "/api/charts/risk", handleRiskChart, nil, ""},
+		{"GET", "/api/charts/distribution", handleDistribution, nil, ""},
+		{"GET", "/api/charts/focus", handleFocusChart, nil, ""},
+		{"GET", "/api/chart.svg", handleChartImage, nil, ""},
+		{"GET", "/api/export.csv", handleExportCSV, nil, ""},
+		{"GET", "/api/export.xlsx", handleExportXLSX, nil, ""},
+		{"GET", "/api/export.md", handleExportMarkdown, nil, ""},
+		{"GET", "/api/export.ndjson", handleExportNDJSON, nil, ""},
+		{"GET", "/api/export.parquet", handleExportParquet, nil, ""},
+		{"GET", "/api/report.pdf", handlePDFReport, nil, ""},
+		{"GET", "/api/insights/correlations", handleCorrelations, nil, ""},
+		{"GET", "/api/insights/weekday", handleWeekdayPatterns, nil, ""},
+		{"GET", "/api/insights/cohort", handleCohortComparison, nil, ""},
+		{"GET", "/api/insights/best-worst", handleBestWorstDays, nil, ""},
+		{"GET", "/api/insights/periods", handlePeriodInsights, nil, ""},
+		{"GET", "/api/insights/recovery", handleRecovery, nil, ""},
+		{"GET", "/api/insights/feed", handleInsightFeed, nil, ""},
+		{"GET", "/api/goals", handleGoals, nil, ""},
+		{"GET", "/api/challenges", handleChallenges, nil, ""},
+		{"GET", "/api/habits", handleHabits, nil, ""},
+		{"GET", "/api/deadlines", handleDeadlines, nil, ""},
+		{"GET", "/api/sleep/summary", handleSleepSummary, nil, ""},
+		{"GET", "/api/study-sessions", handleStudySessions, nil, ""},
+		{"GET", "/timeline", handleTimelinePage, nil, ""},
+		{"GET", "/api/timeline", handleTimeline, nil, ""},
+		{"GET", "/history", handleHistoryPage, nil, ""},
+		{"GET", "/api/entries", handleEntries, nil, ""},
+		{"GET", "/api/checkin/defaults", handleCheckinDefaults, nil, ""},
+		{"GET", "/api/entries/1", handleEntry, map[string]string{"id": "1"}, ""},
+		{"PUT", "/api/entries/1", handleEntry, map[string]string{"id": "1"},
+			`{"sleep":7,"study_hours":4,"deadlines":1,"mood":4,"stress":2}`},
+		{"GET", "/entries/1", handleEntryDetail, map[string]string{"id": "1"}, ""},
+		{"GET", "/entries/1/edit", handleEntryEditForm, map[string]string{"id": "1"}, ""},
+		{"GET", "/entries/1/print", handleEntryPrint, map[string]string{"id": "1"}, ""},
+		{"GET", "/report/weekly", handleWeeklyReport, nil, ""},
+		{"GET", "/dashboard", handleDashboard, nil, ""},
+		{"GET", "/settings", handleSettingsPage, nil, ""},
+		{"PUT", "/api/settings", handleSettings, nil, `{"timezone":"Asia/Jakarta"}`},
+		{"GET", "/api/onboarding", handleOnboardingState, nil, ""},
+		{"GET", "/metrics", handleMetrics, nil, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.target, func(t *testing.T) {
+			useTestDatabase(t, 1)
+			r := httptest.NewRequest(c.method, c.target, strings.NewReader(c.body))
+			switch {
+			case strings.HasPrefix(c.body, "{"):
+				r.Header.Set("Content-Type", "application/json")
+			case c.body != "":
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			for k, v := range c.path {
+				r.SetPathValue(k, v)
+			}
+			if w := serveWithin(t, c.handler, r); w.Code >= 500 {
+				t.Errorf("status %d: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
+
+// TestSingleConnectionTransactions runs the jobs and helpers that write in
+// a transaction with DB_MAX_OPEN_CONNS=1
+func TestSingleConnectionTransactions(t *testing.T) {
+	useTestDatabase(t, 1)
+	within := func(name string, run func() error) {
+		t.Helper()
+		done := make(chan error, 1)
+		go func() { done <- run() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s did not finish: a query waited for a second connection", name)
+		}
+	}
+
+	within("import", func() error {
+		at := time.Now().Add(-90 * 24 * time.Hour)
+		var job importJob
+		for i := range 3 {
+			score := burnoutScore(7, 4, 1, 2, 0)
+			job.Rows = append(job.Rows, ImportRow{Line: i + 2, CreatedAt: at.Add(time.Duration(i) * time.Hour),
+				Sleep: 7, StudyHours: 4, Deadlines: 1, Mood: 3, Stress: 2, Score: score, Level: scoreLevel(score)})
+		}
+		payload, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		_, err = runImportJob(payload)
+		return err
+	})
+	within("delete and restore", func() error {
+		snap, err := deleteEntries(`id <= ?`, 5)
+		if err != nil {
+			return err
+		}
+		return restoreRows(snap)
+	})
+	within("settings", func() error {
+		s, err := loadSettings()
+		if err != nil {
+			return err
+		}
+		return saveSettings(s)
+	})
+	within("demo reset", resetDemo)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const deadlinesSchema = `
+	CREATE TABLE IF NOT EXISTS deadlines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		due_date TEXT NOT NULL,
+		weight REAL NOT NULL DEFAULT 1,
+		done BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// deadlineHorizonDays matches the form's "Deadlines (This Week)" input
+const deadlineHorizonDays = 7
+
+// defaultRescheduleDays is how far the reset plan pushes a deadline
+const defaultRescheduleDays = 2
+
+// Deadline is a tracked assignment or exam. Weight lets a big exam count as
+// more than one deadline when the check-in input is computed.
+type Deadline struct {
+	ID      int64   `json:"id"`
+	Title   string  `json:"title"`
+	DueDate string  `json:"due_date"`
+	Weight  float64 `json:"weight"`
+	Done    bool    `json:"done"`
+}
+
+// validate normalises and checks a deadline submitted by a client
+func (d *Deadline) validate() error {
+	d.Title = strings.TrimSpace(d.Title)
+	if d.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if _, err := time.Parse("2006-01-02", d.DueDate); err != nil {
+		return fmt.Errorf("due_date must be YYYY-MM-DD")
+	}
+	if d.Weight == 0 {
+		d.Weight = 1
+	}
+	if d.Weight < 0 || d.Weight > 5 {
+		return fmt.Errorf("weight must be between 0 and 5")
+	}
+	return nil
+}
+
+const deadlineColumns = `id, title, due_date, weight, done`
+
+func scanDeadline(row interface{ Scan(...any) error }) (Deadline, error) {
+	var d Deadline
+	err := row.Scan(&d.ID, &d.Title, &d.DueDate, &d.Weight, &d.Done)
+	return d, err
+}
+
+// listDeadlines returns deadlines by due date, optionally only open ones
+func listDeadlines(openOnly bool) ([]Deadline, error) {
+	query := `SELECT ` + deadlineColumns + ` FROM deadlines`
+	if openOnly {
+		query += ` WHERE done = 0`
+	}
+	rows, err := db.Query(query + ` ORDER BY due_date ASC, id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deadlines := []Deadline{}
+	for rows.Next() {
+		d, err := scanDeadline(rows)
+		if err != nil {
+			return nil, err
+		}
+		deadlines = append(deadlines, d)
+	}
+	return deadlines, rows.Err()
+}
+
+// upcomingDeadlineLoad is the weighted count of open deadlines that are
+// overdue or due within the next deadlineHorizonDays, used as the check-in's
+// "deadlines" input when the form leaves it blank
+func upcomingDeadlineLoad(now time.Time) (int, error) {
+	horizon := dayKey(now.AddDate(0, 0, deadlineHorizonDays))
+	var load float64
+	err := db.QueryRow(`SELECT COALESCE(SUM(weight), 0) FROM deadlines WHERE done = 0 AND due_date <= ?`, horizon).Scan(&load)
+	return int(math.Round(load)), err
+}
+
+// handleDeadlines lists (GET) or creates (POST) deadlines
+func handleDeadlines(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		deadlines, err := listDeadlines(r.URL.Query().Get("all") != "1")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, deadlines)
+
+	case "POST":
+		var d Deadline
+		if err := decodeJSON(r, &d); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := d.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`INSERT INTO deadlines (title, due_date, weight, done) VALUES (?, ?, ?, ?)`,
+			d.Title, d.DueDate, d.Weight, d.Done)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		d.ID, _ = res.LastInsertId()
+		writeJSON(w, http.StatusCreated, d)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDeadline replaces (PUT) or deletes (DELETE) a deadline
+func handleDeadline(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		var d Deadline
+		if err := decodeJSON(r, &d); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := d.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`UPDATE deadlines SET title = ?, due_date = ?, weight = ?, done = ? WHERE id = ?`,
+			d.Title, d.DueDate, d.Weight, d.Done, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Deadline not found", http.StatusNotFound)
+			return
+		}
+		d.ID = id
+		writeJSON(w, http.StatusOK, d)
+
+	case "DELETE":
+		res, err := db.Exec(`DELETE FROM deadlines WHERE id = ?`, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Deadline not found", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleRescheduleOne implements the reset plan's "reschedule 1 deadline"
+// action: the lightest of the soonest open deadlines is pushed back by
+// defaultRescheduleDays. HTMX requests get a small confirmation fragment.
+func handleRescheduleOne(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	d, err := scanDeadline(db.QueryRow(`SELECT ` + deadlineColumns + ` FROM deadlines
+		WHERE done = 0 ORDER BY due_date ASC, weight ASC, id ASC LIMIT 1`))
+	if err == sql.ErrNoRows {
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<span class="text-gray-500">No open deadlines to move — add them in the deadline tracker.</span>`))
+			return
+		}
+		http.Error(w, "No open deadlines", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	due, _ := time.Parse("2006-01-02", d.DueDate)
+	d.DueDate = due.AddDate(0, 0, defaultRescheduleDays).Format("2006-01-02")
+	if _, err := db.Exec(`UPDATE deadlines SET due_date = ? WHERE id = ?`, d.DueDate, d.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<span class="text-green-700 font-semibold">✅ Moved "%s" to %s</span>`, html.EscapeString(d.Title), d.DueDate)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// demoMode is set by -demo: the server runs on a throwaway database
+// filled with sample check-ins, and pages show a banner saying so
+var demoMode bool
+
+// demoResetInterval is how often a demo goes back to the sample data, so
+// one visitor's changes do not stay around for the next
+const demoResetInterval = time.Hour
+
+// demoDays is how much sample history a demo starts with
+const demoDays = 60
+
+// demoJournal are notes scattered over the sample check-ins
+var demoJournal = []string{
+	"Lab report took longer than expected.",
+	"Good study group session, felt productive.",
+	"Couldn't sleep before the midterm.",
+	"Went for a run after class — helped a lot.",
+	"Too many group chats, hard to focus.",
+	"Finished the essay draft early for once.",
+}
+
+// openDemoDatabase creates an empty database in a temporary directory. It
+// is the demo's only store, so nothing a visitor does reaches burnout.db;
+// cleanup removes it when main returns.
+func openDemoDatabase() (path string, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "burnout-demo")
+	if err != nil {
+		return "", nil, err
+	}
+	return filepath.Join(dir, "demo.db"), func() { os.RemoveAll(dir) }, nil
+}
+
+// resetDemo empties every table and loads the sample data again
+func resetDemo() error {
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if _, err := db.Exec(fmt.Sprintf(`DELETE FROM %q`, table)); err != nil {
+			return err
+		}
+	}
+	return seedDemoData(time.Now())
+}
+
+// seedDemoData writes a plausible semester: an evening check-in on most
+// days, with stress building towards a midterm three weeks ago and
+// easing after it, plus a few deadlines and habits. The same seed gives
+// the same history on every reset.
+func seedDemoData(now time.Time) error {
+	rng := rand.New(rand.NewSource(42))
+
+	// Check-ins are scored before the transaction opens, as scoring reads
+	// the formula through the pool
+	var checkins [][]any
+	for d := demoDays; d >= 1; d-- {
+		if rng.Intn(7) == 0 {
+			continue // a missed day now and then
+		}
+		// Pressure peaks 21 days ago and falls off either side
+		pressure := math.Exp(-math.Pow(float64(d-21)/7, 2))
+		sleep := math.Round((7.5-2.5*pressure+rng.Float64()-0.5)*2) / 2
+		study := math.Round((4+5*pressure+rng.Float64()*2-1)*2) / 2
+		deadlines := int(math.Round(1 + 4*pressure + rng.Float64()))
+		stress := min(5, max(1, int(math.Round(2+2.5*pressure+rng.Float64()-0.5))))
+		mood := min(5, max(1, 6-stress+rng.Intn(2)-rng.Intn(2)))
+		exercise := rng.Intn(3) == 0
+		journal := ""
+		if rng.Intn(4) == 0 {
+			journal = demoJournal[rng.Intn(len(demoJournal))]
+		}
+		recovery := 0.0
+		if exercise {
+			recovery = currentScoring().ExerciseRecovery
+		}
+		score := burnoutScore(sleep, study, deadlines, stress, recovery)
+		at := time.Date(now.Year(), now.Month(), now.Day(), 21, rng.Intn(60), 0, 0, time.Local).AddDate(0, 0, -d)
+		checkins = append(checkins, []any{at.UTC().Format("2006-01-02 15:04:05"), sleep, study, deadlines, mood, stress, exercise,
+			score, scoreLevel(score), generateAIAdvice(sleep, deadlines, stress, score), rng.Intn(2) == 0, journal})
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, args := range checkins {
+		if _, err := tx.Exec(`
+			INSERT INTO entries (created_at, sleep, study_hours, deadlines, mood, stress, exercise, score, level, advice, share_with_cohort, journal)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...); err != nil {
+			return err
+		}
+	}
+
+	for i, title := range []string{"Statistics problem set", "History essay", "Chemistry lab report", "Group presentation"} {
+		due := now.AddDate(0, 0, 2+3*i).Format("2006-01-02")
+		if _, err := tx.Exec(`INSERT INTO deadlines (title, due_date, weight, done) VALUES (?, ?, ?, 0)`, title, due, 1+float64(i%2)); err != nil {
+			return err
+		}
+	}
+	for _, h := range []struct {
+		name     string
+		recovery bool
+	}{{"Evening walk", true}, {"No screens after 11pm", true}, {"Review lecture notes", false}} {
+		res, err := tx.Exec(`INSERT INTO habits (name, recovery) VALUES (?, ?)`, h.name, h.recovery)
+		if err != nil {
+			return err
+		}
+		id, _ := res.LastInsertId()
+		for d := 1; d <= 14; d++ {
+			if _, err := tx.Exec(`INSERT INTO habit_logs (habit_id, day, done) VALUES (?, ?, ?)`,
+				id, now.AddDate(0, 0, -d).Format("2006-01-02"), rng.Intn(3) > 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	for key, value := range map[string]string{
+		"onboarding.completed":          "1",
+		"onboarding.step":               onboardingSteps[len(onboardingSteps)-1],
+		"onboarding.baseline_sleep":     "7",
+		"onboarding.baseline_study":     "5",
+		"onboarding.baseline_stressors": "exams,assignments",
+	} {
+		if _, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)`, key, value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
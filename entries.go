@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// entryColumns is the column list scanned by scanEntry
+const entryColumns = `id, created_at, sleep, study_hours, deadlines, mood, stress, exercise, score, level, advice, share_with_cohort, journal`
+
+// scanEntry reads one row selected with entryColumns
+func scanEntry(rows *sql.Rows) (BurnoutEntry, error) {
+	var e BurnoutEntry
+	err := rows.Scan(&e.ID, &e.CreatedAt, &e.Sleep, &e.StudyHours, &e.Deadlines,
+		&e.Mood, &e.Stress, &e.Exercise, &e.Score, &e.Level, &e.Advice, &e.ShareWithCohort, &e.Journal)
+	return e, err
+}
+
+// queryEntries loads all entries created in [since, until), oldest first.
+// A zero until means "up to now".
+func queryEntries(since, until time.Time) ([]BurnoutEntry, error) {
+	if until.IsZero() {
+		until = time.Now().Add(time.Minute)
+	}
+	rows, err := db.Query(`SELECT `+entryColumns+` FROM entries
+		WHERE created_at >= ? AND created_at < ?
+		ORDER BY created_at ASC`, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []BurnoutEntry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// queryRecentEntries loads the newest n entries, oldest first
+func queryRecentEntries(n int) ([]BurnoutEntry, error) {
+	rows, err := db.Query(`SELECT `+entryColumns+` FROM (
+			SELECT * FROM entries ORDER BY created_at DESC, id DESC LIMIT ?
+		) ORDER BY created_at ASC, id ASC`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []BurnoutEntry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// entryBucket groups the entries that fall in one day or week
+type entryBucket struct {
+	Start   time.Time
+	Entries []BurnoutEntry
+}
+
+// bucketEntries groups time-ordered entries by day or week
+func bucketEntries(entries []BurnoutEntry, granularity string) []entryBucket {
+	var buckets []entryBucket
+	for _, e := range entries {
+		start := bucketStart(e.CreatedAt, granularity)
+		if n := len(buckets); n > 0 && buckets[n-1].Start.Equal(start) {
+			buckets[n-1].Entries = append(buckets[n-1].Entries, e)
+			continue
+		}
+		buckets = append(buckets, entryBucket{Start: start, Entries: []BurnoutEntry{e}})
+	}
+	return buckets
+}
+
+// averageOf returns the mean of field over entries (0 when empty)
+func averageOf(entries []BurnoutEntry, field func(BurnoutEntry) float64) float64 {
+	if len(entries) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, e := range entries {
+		sum += field(e)
+	}
+	return sum / float64(len(entries))
+}
+
+// Field accessors shared by the analytics endpoints
+func entryScore(e BurnoutEntry) float64      { return e.Score }
+func entrySleep(e BurnoutEntry) float64      { return e.Sleep }
+func entryStudyHours(e BurnoutEntry) float64 { return e.StudyHours }
+func entryDeadlines(e BurnoutEntry) float64  { return float64(e.Deadlines) }
+func entryMood(e BurnoutEntry) float64       { return float64(e.Mood) }
+func entryStress(e BurnoutEntry) float64     { return float64(e.Stress) }
+func entryExercise(e BurnoutEntry) float64 {
+	if e.Exercise {
+		return 1
+	}
+	return 0
+}
+
+// getEntry loads a single entry by ID, returning sql.ErrNoRows if missing
+func getEntry(id int64) (BurnoutEntry, error) {
+	rows, err := stmts.getEntry.Query(id)
+	if err != nil {
+		return BurnoutEntry{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return BurnoutEntry{}, err
+		}
+		return BurnoutEntry{}, sql.ErrNoRows
+	}
+	return scanEntry(rows)
+}
+
+// entryFromQuery loads the entry named by ?entry, or the latest one when the
+// parameter is absent. On failure it writes the error response and returns false.
+func entryFromQuery(w http.ResponseWriter, r *http.Request) (BurnoutEntry, bool) {
+	v := r.URL.Query().Get("entry")
+	if v == "" {
+		recent, err := queryRecentEntries(1)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return BurnoutEntry{}, false
+		}
+		if len(recent) == 0 {
+			http.Error(w, "No entries yet", http.StatusNotFound)
+			return BurnoutEntry{}, false
+		}
+		return recent[0], true
+	}
+
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid entry %q", v), http.StatusBadRequest)
+		return BurnoutEntry{}, false
+	}
+	entry, err := getEntry(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return BurnoutEntry{}, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return BurnoutEntry{}, false
+	}
+	return entry, true
+}
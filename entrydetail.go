@@ -0,0 +1,131 @@
+package main
+
+import (
+	"database/sql"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// entryDetailPage is the data behind templates/entry.html
+type entryDetailPage struct {
+	Entry       BurnoutEntry
+	CreatedAt   string
+	Gauge       template.HTML
+	Breakdown   []scoreContribution
+	Unexplained float64
+	// WhatIf is nil when the what-if feature flag is off for the visitor
+	WhatIf *whatIf
+}
+
+// whatIf is the entry re-scored with changed inputs. Recovery habits done
+// that day still count, so only the changed inputs move the score.
+type whatIf struct {
+	EntryID    int
+	Sleep      float64
+	StudyHours float64
+	Deadlines  int
+	Stress     int
+	Exercise   bool
+	Score      float64
+	Level      string
+	Delta      float64 // Score minus the stored score
+}
+
+// AbsDelta is the size of the change, for display next to an arrow
+func (w whatIf) AbsDelta() float64 { return math.Abs(w.Delta) }
+
+// scoreWhatIf scores changed inputs on the entry's day
+func scoreWhatIf(e BurnoutEntry, w whatIf) whatIf {
+	w.EntryID = e.ID
+	w.Score = burnoutScore(w.Sleep, w.StudyHours, w.Deadlines, w.Stress, recoveryCredit(w.Exercise, e.CreatedAt))
+	w.Level = scoreLevel(w.Score)
+	w.Delta = w.Score - e.Score
+	return w
+}
+
+// loadEntryForPath reads the entry named by the {id} path value, writing
+// the error response itself when there is none
+func loadEntryForPath(w http.ResponseWriter, r *http.Request) (BurnoutEntry, bool) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return BurnoutEntry{}, false
+	}
+	entry, err := getEntry(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return entry, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return entry, false
+	}
+	return entry, true
+}
+
+// handleEntryDetail renders /entries/{id}: the inputs, how they add up to
+// the score, the advice given and a what-if panel to try other inputs
+func handleEntryDetail(w http.ResponseWriter, r *http.Request) {
+	entry, ok := loadEntryForPath(w, r)
+	if !ok {
+		return
+	}
+	tmpl, loc, err := localizedTemplate("entry.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page := entryDetailPage{
+		Entry:     entry,
+		CreatedAt: loc.DateTime(entry.CreatedAt),
+		Gauge:     scoreGauge{Score: entry.Score, Label: loc.T("result.score")}.SVG(),
+		Breakdown: scoreBreakdown(entry),
+	}
+	if features.enabled(r, "what-if") {
+		wi := scoreWhatIf(entry, whatIf{Sleep: entry.Sleep, StudyHours: entry.StudyHours,
+			Deadlines: entry.Deadlines, Stress: entry.Stress, Exercise: entry.Exercise})
+		page.WhatIf = &wi
+	}
+	page.Unexplained = breakdownRemainder(entry, page.Breakdown)
+	tmpl.Execute(w, page)
+}
+
+// handleEntryWhatIf re-scores an entry with the posted inputs and returns
+// the what-if result fragment. Nothing is saved.
+func handleEntryWhatIf(w http.ResponseWriter, r *http.Request) {
+	if !features.enabled(r, "what-if") {
+		http.NotFound(w, r)
+		return
+	}
+	entry, ok := loadEntryForPath(w, r)
+	if !ok {
+		return
+	}
+	in := whatIf{Exercise: r.FormValue("exercise") == "on"}
+	var err error
+	if in.Sleep, err = strconv.ParseFloat(r.FormValue("sleep"), 64); err != nil || in.Sleep < 0 || in.Sleep > 24 {
+		http.Error(w, "sleep must be 0-24 hours", http.StatusBadRequest)
+		return
+	}
+	if in.StudyHours, err = strconv.ParseFloat(r.FormValue("study"), 64); err != nil || in.StudyHours < 0 || in.StudyHours > 24 {
+		http.Error(w, "study must be 0-24 hours", http.StatusBadRequest)
+		return
+	}
+	if in.Deadlines, err = strconv.Atoi(r.FormValue("deadlines")); err != nil || in.Deadlines < 0 || in.Deadlines > 100 {
+		http.Error(w, "deadlines must be 0-100", http.StatusBadRequest)
+		return
+	}
+	if in.Stress, err = strconv.Atoi(r.FormValue("stress")); err != nil || in.Stress < 1 || in.Stress > 5 {
+		http.Error(w, "stress must be 1-5", http.StatusBadRequest)
+		return
+	}
+	tmpl, _, err := localizedTemplate("entry.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.ExecuteTemplate(w, "what-if", scoreWhatIf(entry, in))
+}
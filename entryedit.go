@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// entryInput holds the user-entered fields of a check-in. Score, level and
+// advice are always derived from them, never edited directly.
+type entryInput struct {
+	Sleep      float64 `json:"sleep"`
+	StudyHours float64 `json:"study_hours"`
+	Deadlines  int     `json:"deadlines"`
+	Mood       int     `json:"mood"`
+	Stress     int     `json:"stress"`
+	Exercise   bool    `json:"exercise"`
+	Journal    string  `json:"journal"`
+}
+
+func (in *entryInput) validate() error {
+	in.Journal = strings.TrimSpace(in.Journal)
+	switch {
+	case in.Sleep < 0 || in.Sleep > 24:
+		return fmt.Errorf("sleep must be between 0 and 24 hours")
+	case in.StudyHours < 0 || in.StudyHours > 24:
+		return fmt.Errorf("study hours must be between 0 and 24")
+	case in.Deadlines < 0 || in.Deadlines > 100:
+		return fmt.Errorf("deadlines must be between 0 and 100")
+	case in.Mood < 1 || in.Mood > 5:
+		return fmt.Errorf("mood must be between 1 and 5")
+	case in.Stress < 1 || in.Stress > 5:
+		return fmt.Errorf("stress must be between 1 and 5")
+	case len([]rune(in.Journal)) > maxJournalLength:
+		return fmt.Errorf("the journal note is limited to %d characters", maxJournalLength)
+	}
+	return nil
+}
+
+// inputOf returns the editable fields of a stored entry
+func inputOf(e BurnoutEntry) entryInput {
+	return entryInput{Sleep: e.Sleep, StudyHours: e.StudyHours, Deadlines: e.Deadlines,
+		Mood: e.Mood, Stress: e.Stress, Exercise: e.Exercise, Journal: e.Journal}
+}
+
+// parseEntryInput reads a JSON body, or the same form fields as the
+// check-in form when the request comes from an HTML form
+func parseEntryInput(r *http.Request) (entryInput, error) {
+	var in entryInput
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return in, decodeJSON(r, &in)
+	}
+	if err := r.ParseForm(); err != nil {
+		return in, err
+	}
+	var err error
+	number := func(name string) float64 {
+		v, perr := strconv.ParseFloat(strings.TrimSpace(r.PostFormValue(name)), 64)
+		if perr != nil && err == nil {
+			err = fmt.Errorf("%s must be a number", name)
+		}
+		return v
+	}
+	in.Sleep = number("sleep")
+	in.StudyHours = number("study")
+	in.Deadlines = int(number("deadlines"))
+	in.Mood = int(number("mood"))
+	in.Stress = int(number("stress"))
+	in.Exercise = r.PostFormValue("exercise") == "on"
+	in.Journal = r.PostFormValue("journal")
+	return in, err
+}
+
+// updateEntry stores corrected inputs and recomputes the score, level and
+// advice the same way a new check-in would on the entry's own day
+func updateEntry(e BurnoutEntry, in entryInput) (BurnoutEntry, error) {
+	score := burnoutScore(in.Sleep, in.StudyHours, in.Deadlines, in.Stress, recoveryCredit(in.Exercise, e.CreatedAt))
+	level := scoreLevel(score)
+	advice := generateAIAdvice(in.Sleep, in.Deadlines, in.Stress, score)
+	_, err := db.Exec(`UPDATE entries SET sleep = ?, study_hours = ?, deadlines = ?, mood = ?, stress = ?,
+		exercise = ?, score = ?, level = ?, advice = ?, journal = ? WHERE id = ?`,
+		in.Sleep, in.StudyHours, in.Deadlines, in.Mood, in.Stress, in.Exercise, score, level, advice, in.Journal, e.ID)
+	if err != nil {
+		return e, err
+	}
+	return getEntry(int64(e.ID))
+}
+
+// renderEntryFragment writes one of the history.html fragments
+func renderEntryFragment(w http.ResponseWriter, r *http.Request, name string, data any) {
+	tmpl, _, err := localizedTemplate("history.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.ExecuteTemplate(w, name, data)
+}
+
+// handleEntry reads (GET), corrects (PUT) or deletes (DELETE) a single
+// entry. HTMX requests get the entry's history row back instead of JSON, a
+// failed validation re-renders the edit form with the message, and a
+// deletion leaves an undo toast in the row's place.
+func handleEntry(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := getEntry(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	htmx := r.Header.Get("HX-Request") == "true"
+
+	switch r.Method {
+	case "GET":
+		if htmx {
+			renderEntryFragment(w, r, "row", entry)
+			return
+		}
+		writeJSON(w, http.StatusOK, newEntryRecord(entry))
+
+	case "PUT":
+		in, err := parseEntryInput(r)
+		if err == nil {
+			err = in.validate()
+		}
+		if err != nil {
+			if htmx {
+				renderEntryFragment(w, r, "edit", map[string]any{"Entry": entry, "Input": in, "Error": err.Error()})
+				return
+			}
+			writeBodyError(w, err)
+			return
+		}
+		updated, err := updateEntry(entry, in)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if htmx {
+			w.Header().Set("HX-Trigger", "entryUpdated")
+			renderEntryFragment(w, r, "row", updated)
+			return
+		}
+		writeJSON(w, http.StatusOK, newEntryRecord(updated))
+
+	case "DELETE":
+		snap, err := deleteEntries(`id = ?`, entry.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondDeleted(w, r, snap)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleEntryEditForm returns the edit form for an entry, pre-filled with
+// its stored inputs, as a fragment that replaces its history row
+func handleEntryEditForm(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entry, err := getEntry(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Entry not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderEntryFragment(w, r, "edit", map[string]any{"Entry": entry, "Input": inputOf(entry)})
+}
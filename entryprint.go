@@ -0,0 +1,82 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// entryPrintPage is the data behind templates/entry_print.html
+type entryPrintPage struct {
+	Entry     BurnoutEntry
+	CreatedAt string
+	Gauge     template.HTML
+	Breakdown []scoreContribution
+	// Unexplained is the part of the stored score the inputs alone do not
+	// give: recovery habits and the 0-100 cap
+	Unexplained float64
+	// Week is every check-in in the seven days up to the entry, for context
+	Week        []entryPrintRow
+	WeekChart   template.HTML
+	GeneratedAt string
+}
+
+// entryPrintRow is one check-in of the week table
+type entryPrintRow struct {
+	When    string
+	Entry   BurnoutEntry
+	Current bool
+}
+
+// buildEntryPrintPage gathers one entry with its breakdown and the week
+// leading up to it
+func buildEntryPrintPage(e BurnoutEntry, loc localizer) (entryPrintPage, error) {
+	page := entryPrintPage{
+		Entry:       e,
+		CreatedAt:   loc.DateTime(e.CreatedAt) + " " + loc.ZoneName(),
+		Gauge:       scoreGauge{Score: e.Score, Label: "Score"}.SVG(),
+		Breakdown:   scoreBreakdown(e),
+		GeneratedAt: loc.DateLong(time.Now()),
+	}
+	page.Unexplained = breakdownRemainder(e, page.Breakdown)
+
+	local := e.CreatedAt.In(loc.zone())
+	end := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc.zone()).AddDate(0, 0, 1)
+	entries, err := queryEntries(end.AddDate(0, 0, -7), end)
+	if err != nil {
+		return page, err
+	}
+	chart := chartImage{Width: 680, Height: 200, Title: "Check-ins this week"}
+	for _, w := range entries {
+		weekday := loc.Weekday(w.CreatedAt.In(loc.zone()))
+		page.Week = append(page.Week, entryPrintRow{When: weekday + " " + loc.DateTime(w.CreatedAt), Entry: w, Current: w.ID == e.ID})
+		chart.Labels = append(chart.Labels, weekday)
+		chart.Scores = append(chart.Scores, roundTo(w.Score, 1))
+	}
+	if len(entries) > 1 {
+		// The SVG is generated by our own renderer with escaped labels
+		page.WeekChart = template.HTML(chart.SVG())
+	}
+	return page, nil
+}
+
+// handleEntryPrint renders /entries/{id}/print, one check-in laid out for
+// paper: the inputs, how they add up to the score, the advice and the
+// week around it, e.g. to bring to a counseling appointment
+func handleEntryPrint(w http.ResponseWriter, r *http.Request) {
+	entry, ok := loadEntryForPath(w, r)
+	if !ok {
+		return
+	}
+	page, err := buildEntryPrintPage(entry, requestLocalizer(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl, err := loadTemplate("entry_print.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, page)
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errorReportBuffer reports may wait to be sent; beyond it new ones are
+// dropped, so a burst of failures can't pile up in memory
+const errorReportBuffer = 100
+
+// errorReportBody is how much of a plain-text 5xx body, usually the
+// error http.Error was given, goes into the report
+const errorReportBody = 1024
+
+// errorReportHeaders are the only request headers a report carries.
+// Cookies, tokens, form values, query strings and the URL itself are left
+// out: they can hold share tokens, kiosk codes or people's answers.
+var errorReportHeaders = []string{"User-Agent", "Accept-Language", "Content-Type", "HX-Request", "HX-Target"}
+
+// errorReportConfig is where reports go: a Sentry DSN, which GlitchTip and
+// other Sentry-compatible services accept too
+type errorReportConfig struct {
+	Endpoint    string // the project's envelope URL
+	Key         string // the DSN's public key
+	DSN         string
+	Environment string
+	Release     string
+}
+
+// loadErrorReportConfig reads SENTRY_DSN, and SENTRY_ENVIRONMENT and
+// SENTRY_RELEASE to label the reports; ok is false when no DSN is set
+func loadErrorReportConfig() (errorReportConfig, bool, error) {
+	cfg := errorReportConfig{
+		DSN:         os.Getenv("SENTRY_DSN"),
+		Environment: envOr("SENTRY_ENVIRONMENT", "production"),
+		Release:     os.Getenv("SENTRY_RELEASE"),
+	}
+	if cfg.DSN == "" {
+		return cfg, false, nil
+	}
+	// https://<key>@<host>[/<path>]/<project>
+	u, err := url.Parse(cfg.DSN)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User == nil || u.User.Username() == "" {
+		return cfg, false, fmt.Errorf("errors: SENTRY_DSN must look like https://key@host/project, got %q", cfg.DSN)
+	}
+	prefix, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+	if project == "" {
+		return cfg, false, fmt.Errorf("errors: SENTRY_DSN has no project ID, got %q", cfg.DSN)
+	}
+	cfg.Key = u.User.Username()
+	cfg.Endpoint = u.Scheme + "://" + u.Host + prefix + "/api/" + project + "/envelope/"
+	return cfg, true, nil
+}
+
+// errorEvent is a Sentry event, with only the fields reports fill in
+type errorEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	Transaction string            `json:"transaction,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Exception   *errorExceptions  `json:"exception,omitempty"`
+	Request     *errorRequest     `json:"request,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+type errorExceptions struct {
+	Values []errorException `json:"values"`
+}
+
+type errorException struct {
+	Type       string           `json:"type"`
+	Value      string           `json:"value"`
+	Stacktrace *errorStacktrace `json:"stacktrace,omitempty"`
+}
+
+type errorStacktrace struct {
+	Frames []errorFrame `json:"frames"`
+}
+
+type errorFrame struct {
+	Function string `json:"function"`
+	Filename string `json:"filename"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type errorRequest struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// errorReporter sends events in the background, so a failing request
+// isn't slowed down further by reporting it
+type errorReporter struct {
+	mu      sync.Mutex
+	cfg     errorReportConfig
+	enabled bool
+	events  chan *errorEvent
+	done    chan struct{}
+	client  *http.Client
+	host    string
+}
+
+var errorReports = &errorReporter{}
+
+// Start turns reporting on
+func (e *errorReporter) Start(cfg errorReportConfig) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cfg, e.enabled = cfg, true
+	e.events = make(chan *errorEvent, errorReportBuffer)
+	e.done = make(chan struct{})
+	e.client = &http.Client{Timeout: 10 * time.Second}
+	e.host, _ = os.Hostname()
+	go e.loop()
+	slog.Info("error reporting on", "endpoint", cfg.Endpoint, "environment", cfg.Environment)
+}
+
+// Stop sends the reports still waiting and turns reporting off
+func (e *errorReporter) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.enabled {
+		e.mu.Unlock()
+		return nil
+	}
+	e.enabled = false
+	e.mu.Unlock()
+	close(e.events)
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *errorReporter) on() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enabled
+}
+
+// send queues ev, filling in what every event carries
+func (e *errorReporter) send(ev *errorEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.enabled {
+		return
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	ev.EventID = hex.EncodeToString(b)
+	ev.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	ev.Platform, ev.Logger = "go", "burnout-detector"
+	ev.ServerName, ev.Environment, ev.Release = e.host, e.cfg.Environment, e.cfg.Release
+	select {
+	case e.events <- ev:
+	default:
+		slog.Warn("error reporting: queue full, report dropped", "message", ev.Message)
+	}
+}
+
+func (e *errorReporter) loop() {
+	defer close(e.done)
+	for ev := range e.events {
+		e.post(ev)
+	}
+}
+
+// post sends one event as a Sentry envelope. A report that can't be sent
+// is only logged; the error it describes was logged already.
+func (e *errorReporter) post(ev *errorEvent) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		slog.Warn("error reporting: encoding failed", "err", err)
+		return
+	}
+	var body bytes.Buffer
+	header, _ := json.Marshal(map[string]string{"event_id": ev.EventID, "dsn": e.cfg.DSN, "sent_at": time.Now().UTC().Format(time.RFC3339)})
+	body.Write(header)
+	fmt.Fprintf(&body, "\n{\"type\":\"event\",\"length\":%d}\n", len(payload))
+	body.Write(payload)
+	body.WriteByte('\n')
+
+	req, err := http.NewRequest("POST", e.cfg.Endpoint, &body)
+	if err != nil {
+		slog.Warn("error reporting failed", "err", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=burnout-detector/1.0, sentry_key="+e.cfg.Key)
+	resp, err := e.client.Do(req)
+	if err != nil {
+		slog.Warn("error reporting failed", "err", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Warn("error reporting: report refused", "status", resp.Status)
+	}
+}
+
+// errorCapture lets recoverPanics tell reportErrors that it already sent
+// the request's panic, so the 500 that follows isn't reported again
+type errorCapture struct{ reported bool }
+
+type errorCaptureKey struct{}
+
+// reportErrors reports every 5xx response, with the request's route,
+// method, ID and a few harmless headers. Maintenance-mode refusals, which
+// carry a Retry-After, are expected and left out.
+func reportErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !errorReports.on() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		capture := &errorCapture{}
+		r = r.WithContext(context.WithValue(r.Context(), errorCaptureKey{}, capture))
+		rec := &errorBodyRecorder{statusRecorder: statusRecorder{ResponseWriter: w}}
+		next.ServeHTTP(rec, r)
+		if rec.code < 500 || capture.reported || w.Header().Get("Retry-After") != "" {
+			return
+		}
+		message := fmt.Sprintf("%d %s", rec.code, http.StatusText(rec.code))
+		if body := strings.TrimSpace(rec.body.String()); body != "" {
+			message += ": " + body
+		}
+		ev := requestEvent(r)
+		ev.Message = message
+		errorReports.send(ev)
+	})
+}
+
+// errorBodyRecorder keeps the start of a 5xx plain-text body, which is
+// the error message handlers pass to http.Error
+type errorBodyRecorder struct {
+	statusRecorder
+	body bytes.Buffer
+}
+
+func (e *errorBodyRecorder) Write(b []byte) (int, error) {
+	n, err := e.statusRecorder.Write(b)
+	if e.code >= 500 && strings.HasPrefix(e.Header().Get("Content-Type"), "text/plain") {
+		if room := errorReportBody - e.body.Len(); room > 0 {
+			e.body.Write(b[:min(len(b), room)])
+		}
+	}
+	return n, err
+}
+
+// reportPanic sends a recovered panic from a request with the stack it
+// panicked on; it must be called from the deferred function that
+// recovered
+func reportPanic(r *http.Request, v any) {
+	if !errorReports.on() {
+		return
+	}
+	if capture, ok := r.Context().Value(errorCaptureKey{}).(*errorCapture); ok {
+		capture.reported = true
+	}
+	ev := requestEvent(r)
+	ev.Exception = panicException(v)
+	errorReports.send(ev)
+}
+
+// reportJobPanic sends a recovered panic from a background job
+func reportJobPanic(job string, v any) {
+	if !errorReports.on() {
+		return
+	}
+	errorReports.send(&errorEvent{
+		Level:       "fatal",
+		Transaction: "job " + job,
+		Exception:   panicException(v),
+		Tags:        map[string]string{"job": job},
+	})
+}
+
+// requestEvent is an error event describing r without anything personal:
+// the route stands in for the URL
+func requestEvent(r *http.Request) *errorEvent {
+	route := routeOf(r)
+	headers := map[string]string{}
+	for _, h := range errorReportHeaders {
+		if v := r.Header.Get(h); v != "" {
+			headers[h] = v
+		}
+	}
+	return &errorEvent{
+		Level:       "error",
+		Transaction: r.Method + " " + route,
+		Request:     &errorRequest{Method: r.Method, URL: route, Headers: headers},
+		Tags:        map[string]string{"request_id": requestID(r), "route": route},
+	}
+}
+
+// panicException describes a panic value with the current goroutine's
+// stack, oldest call first as Sentry expects
+func panicException(v any) *errorExceptions {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var list []errorFrame
+	for {
+		f, more := frames.Next()
+		list = append(list, errorFrame{
+			Function: f.Function,
+			Filename: f.File,
+			Lineno:   f.Line,
+			InApp:    strings.HasPrefix(f.Function, "main."),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	typ := "panic"
+	if err, ok := v.(error); ok {
+		typ = fmt.Sprintf("%T", err)
+	}
+	return &errorExceptions{Values: []errorException{{Type: typ, Value: fmt.Sprint(v), Stacktrace: &errorStacktrace{Frames: list}}}}
+}
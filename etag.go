@@ -0,0 +1,96 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dataGeneration counts committed writes to the database. The timed driver
+// bumps it (see sqltiming.go), so every insert, edit, delete, import or
+// settings change is covered without each handler having to remember to.
+var dataGeneration atomic.Uint64
+
+// dataChanged marks everything derived from the database as stale
+func dataChanged() { dataGeneration.Add(1) }
+
+// dataETag identifies one version of a read-only response: the data as of
+// a write generation, for this URL, language and day. The day is in the
+// key because "last 30 days" moves at midnight without any write.
+func dataETag(r *http.Request, generation uint64) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%d\x00%s\x00%s\x00%s",
+		startedAt.UnixNano(), generation,
+		time.Now().In(loadZone()).Format("2006-01-02"),
+		negotiateLanguage(r), r.URL.RequestURI())
+	return `"` + hex.EncodeToString(h.Sum(nil))[:20] + `"`
+}
+
+// etagMatches applies If-None-Match's weak comparison, so a tag that
+// compress marked W/ still matches
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setETag marks a response as the version etag of the data, to be
+// revalidated before each use
+func setETag(h http.Header, etag string) {
+	h.Set("ETag", etag)
+	h.Set("Cache-Control", "private, no-cache")
+}
+
+// etagWriter adds the ETag once the handler answers 200. An error or a
+// redirect goes out untagged, so a client never holds on to it as the
+// data's current version.
+type etagWriter struct {
+	statusRecorder
+	etag string
+}
+
+func (e *etagWriter) WriteHeader(code int) {
+	if e.code == 0 && code == http.StatusOK {
+		setETag(e.Header(), e.etag)
+	}
+	e.statusRecorder.WriteHeader(code)
+}
+
+func (e *etagWriter) Write(b []byte) (int, error) {
+	if e.code == 0 {
+		e.WriteHeader(http.StatusOK)
+	}
+	return e.statusRecorder.Write(b)
+}
+
+// conditional adds an ETag to a GET endpoint whose output only depends on
+// the stored data, and answers 304 Not Modified when the client already
+// has it. HTMX and the dashboard poll the chart and stats endpoints, and
+// most polls find nothing new; a client without the tag is served from
+// the response cache when another one asked first (see cache.go).
+func conditional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		generation := state.Generation()
+		etag := dataETag(r, generation)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			// The client's copy came from a 200 with this tag; a 304
+			// repeats the tag, as RFC 9110 asks
+			setETag(w.Header(), etag)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		serveCached(&etagWriter{statusRecorder: statusRecorder{ResponseWriter: w}, etag: etag}, r, etag, generation, next)
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestConditionalTagsOnlyOK checks that only a 200 carries the ETag, and
+// that sending it back gets a 304
+func TestConditionalTagsOnlyOK(t *testing.T) {
+	useTestDatabase(t, 2)
+	status := http.StatusOK
+	h := conditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			http.Error(w, "broken", status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	serve := func(url, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", url, nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	status = http.StatusInternalServerError
+	if w := serve("/failing", ""); w.Header().Get("ETag") != "" || w.Header().Get("Cache-Control") != "" {
+		t.Errorf("500 was tagged: ETag %q, Cache-Control %q", w.Header().Get("ETag"), w.Header().Get("Cache-Control"))
+	}
+
+	status = http.StatusOK
+	w := serve("/working", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Cache-Control") != "private, no-cache" {
+		t.Fatalf("200 not tagged: status %d, ETag %q, Cache-Control %q", w.Code, etag, w.Header().Get("Cache-Control"))
+	}
+	if w := serve("/working", ""); w.Header().Get("ETag") != etag || w.Body.String() != "ok" {
+		t.Errorf("cached 200: ETag %q, body %q; want %q, \"ok\"", w.Header().Get("ETag"), w.Body, etag)
+	}
+	if w := serve("/working", etag); w.Code != http.StatusNotModified || w.Header().Get("ETag") != etag {
+		t.Errorf("revalidation: status %d, ETag %q; want 304 with %q", w.Code, w.Header().Get("ETag"), etag)
+	}
+}
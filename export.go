@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportColumns is the header row shared by the entry exports
+var exportColumns = []string{
+	"id", "created_at", "sleep", "study_hours", "deadlines", "mood", "stress",
+	"exercise", "score", "level", "advice", "share_with_cohort", "journal",
+}
+
+// parseExportRange reads ?from and ?to (YYYY-MM-DD, both inclusive).
+// Missing bounds leave the range open on that side.
+func parseExportRange(r *http.Request) (since, until time.Time, err error) {
+	since, err = parseDateParam(r, "from", time.Time{})
+	if err != nil {
+		return
+	}
+	until, err = parseDateParam(r, "to", time.Time{})
+	if err != nil {
+		return
+	}
+	if !until.IsZero() {
+		until = until.AddDate(0, 0, 1)
+		if !since.IsZero() && !until.After(since) {
+			err = fmt.Errorf("to must not be before from")
+		}
+	}
+	return
+}
+
+// exportRecord flattens an entry into the exportColumns order
+func exportRecord(e BurnoutEntry) []string {
+	return []string{
+		strconv.Itoa(e.ID),
+		e.CreatedAt.UTC().Format(time.RFC3339),
+		strconv.FormatFloat(e.Sleep, 'f', -1, 64),
+		strconv.FormatFloat(e.StudyHours, 'f', -1, 64),
+		strconv.Itoa(e.Deadlines),
+		strconv.Itoa(e.Mood),
+		strconv.Itoa(e.Stress),
+		strconv.FormatBool(e.Exercise),
+		strconv.FormatFloat(e.Score, 'f', -1, 64),
+		spreadsheetSafe(e.Level),
+		spreadsheetSafe(e.Advice),
+		strconv.FormatBool(e.ShareWithCohort),
+		spreadsheetSafe(e.Journal),
+	}
+}
+
+// spreadsheetSafe stops free text such as journal notes from being run as a
+// formula when the CSV is opened in a spreadsheet
+func spreadsheetSafe(field string) string {
+	if field != "" && strings.ContainsRune("=+-@\t\r", rune(field[0])) {
+		return "'" + field
+	}
+	return field
+}
+
+// handleExportCSV streams entries in the requested range as UTF-8 CSV.
+// ?excel=1 prepends a byte order mark so Excel detects the encoding.
+func handleExportCSV(w http.ResponseWriter, r *http.Request) {
+	since, until, policy, err := parseExportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := exportEntries(since, until, policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="burnout-entries-%s.csv"`, time.Now().Format("2006-01-02")))
+	if r.URL.Query().Get("excel") == "1" {
+		w.Write([]byte("\ufeff"))
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(exportColumns)
+	for _, e := range entries {
+		cw.Write(exportRecord(e))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		requestLog(r).Error("export failed", "format", "csv", "err", err)
+	}
+}
+
+// handleExportXLSX builds an Excel workbook with the entries, weekly
+// aggregates and a ready-made chart of the weekly averages
+func handleExportXLSX(w http.ResponseWriter, r *http.Request) {
+	since, until, policy, err := parseExportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := exportEntries(since, until, policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	header := make([]any, len(exportColumns))
+	for i, c := range exportColumns {
+		header[i] = c
+	}
+	entryRows := [][]any{header}
+	for _, e := range entries {
+		entryRows = append(entryRows, []any{
+			e.ID, e.CreatedAt.UTC().Format("2006-01-02 15:04:05"), e.Sleep, e.StudyHours, e.Deadlines,
+			e.Mood, e.Stress, e.Exercise, e.Score, e.Level, e.Advice, e.ShareWithCohort, e.Journal,
+		})
+	}
+
+	weekRows := [][]any{{"week_start", "checkins", "avg_score", "avg_sleep", "avg_study_hours", "avg_stress", "avg_mood", "exercise_days"}}
+	for _, b := range bucketEntries(entries, granularityWeek) {
+		exerciseDays := 0
+		for _, day := range bucketEntries(b.Entries, granularityDay) {
+			for _, e := range day.Entries {
+				if e.Exercise {
+					exerciseDays++
+					break
+				}
+			}
+		}
+		weekRows = append(weekRows, []any{
+			b.Start.Format("2006-01-02"), len(b.Entries),
+			roundTo(averageOf(b.Entries, entryScore), 1),
+			roundTo(averageOf(b.Entries, entrySleep), 1),
+			roundTo(averageOf(b.Entries, entryStudyHours), 1),
+			roundTo(averageOf(b.Entries, entryStress), 1),
+			roundTo(averageOf(b.Entries, entryMood), 1),
+			exerciseDays,
+		})
+	}
+
+	last := max(len(weekRows), 2)
+	sheets := []xlsxSheet{
+		{Name: "Entries", Rows: entryRows},
+		{Name: "Weekly", Rows: weekRows},
+		{Name: "Chart", Chart: &xlsxChart{
+			Title:      "Weekly average burnout score",
+			DataSheet:  "Weekly",
+			Categories: fmt.Sprintf("$A$2:$A$%d", last),
+			Series:     []string{fmt.Sprintf("$C$2:$C$%d", last)},
+			SeriesName: []string{"$C$1"},
+		}},
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="burnout-entries-%s.xlsx"`, time.Now().Format("2006-01-02")))
+	if err := writeXLSX(w, sheets); err != nil {
+		requestLog(r).Error("export failed", "format", "xlsx", "err", err)
+	}
+}
+
+// entryRecord is the JSON shape of an exported entry, using the same field
+// names as the CSV header
+type entryRecord struct {
+	ID              int       `json:"id"`
+	CreatedAt       time.Time `json:"created_at"`
+	Sleep           float64   `json:"sleep"`
+	StudyHours      float64   `json:"study_hours"`
+	Deadlines       int       `json:"deadlines"`
+	Mood            int       `json:"mood"`
+	Stress          int       `json:"stress"`
+	Exercise        bool      `json:"exercise"`
+	Score           float64   `json:"score"`
+	Level           string    `json:"level"`
+	Advice          string    `json:"advice"`
+	ShareWithCohort bool      `json:"share_with_cohort"`
+	Journal         string    `json:"journal"`
+}
+
+func newEntryRecord(e BurnoutEntry) entryRecord {
+	return entryRecord{
+		ID: e.ID, CreatedAt: e.CreatedAt.UTC(), Sleep: e.Sleep, StudyHours: e.StudyHours,
+		Deadlines: e.Deadlines, Mood: e.Mood, Stress: e.Stress, Exercise: e.Exercise,
+		Score: e.Score, Level: e.Level, Advice: e.Advice, ShareWithCohort: e.ShareWithCohort, Journal: e.Journal,
+	}
+}
+
+// ndjsonFlushEvery is how many lines are written between flushes
+const ndjsonFlushEvery = 100
+
+// handleExportNDJSON streams entries in ?from/?to as JSON Lines. Rows are
+// encoded straight from the cursor, so memory stays flat however many there
+// are; a slow client simply blocks the writes, and a disconnect cancels the query.
+func handleExportNDJSON(w http.ResponseWriter, r *http.Request) {
+	since, until, policy, err := parseExportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="burnout-entries-%s.ndjson"`, time.Now().Format("2006-01-02")))
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	n := 0
+	errClientGone := errors.New("client went away")
+	err = streamExportEntries(r.Context(), since, until, policy, func(e BurnoutEntry) error {
+		if err := enc.Encode(newEntryRecord(e)); err != nil {
+			return errClientGone
+		}
+		if n++; flusher != nil && n%ndjsonFlushEvery == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	switch {
+	case err == nil, errors.Is(err, errClientGone), r.Context().Err() != nil:
+	case n == 0:
+		// Nothing has been sent yet, so the failure can still be a status
+		w.Header().Del("Content-Disposition")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		requestLog(r).Error("export failed", "format", "ndjson", "err", err)
+	}
+}
+
+// handleExportParquet returns the research dataset as Parquet: check-ins the
+// user opted in to sharing, with the identifying and free-text fields (id,
+// exact time, advice, journal) left out and timestamps reduced to the day
+func handleExportParquet(w http.ResponseWriter, r *http.Request) {
+	since, until, policy, err := parseExportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := exportEntries(since, until, policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	columns := []parquetColumn{
+		{Name: "date", Type: parquetInt32, Converted: parquetDate},
+		{Name: "sleep", Type: parquetDouble, Converted: parquetNoConversion},
+		{Name: "study_hours", Type: parquetDouble, Converted: parquetNoConversion},
+		{Name: "deadlines", Type: parquetInt32, Converted: parquetNoConversion},
+		{Name: "mood", Type: parquetInt32, Converted: parquetNoConversion},
+		{Name: "stress", Type: parquetInt32, Converted: parquetNoConversion},
+		{Name: "exercise", Type: parquetBoolean, Converted: parquetNoConversion},
+		{Name: "score", Type: parquetDouble, Converted: parquetNoConversion},
+		{Name: "level", Type: parquetByteArray, Converted: parquetUTF8},
+	}
+	for _, e := range entries {
+		if !e.ShareWithCohort {
+			continue
+		}
+		day := int32(e.CreatedAt.UTC().Unix() / 86400)
+		row := []any{day, e.Sleep, e.StudyHours, int32(e.Deadlines), int32(e.Mood), int32(e.Stress), e.Exercise, e.Score, levelCode(e.Score)}
+		for i := range columns {
+			columns[i].Values = append(columns[i].Values, row[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="burnout-research-%s.parquet"`, time.Now().Format("2006-01-02")))
+	if err := writeParquet(w, columns); err != nil {
+		requestLog(r).Error("export failed", "format", "parquet", "err", err)
+	}
+}
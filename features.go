@@ -0,0 +1,263 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const featureFlagsSchema = `
+	CREATE TABLE IF NOT EXISTS feature_flags (
+		name TEXT PRIMARY KEY,
+		rule TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// featureFlag is an experimental feature that can be rolled out gradually
+type featureFlag struct {
+	Name        string
+	Description string
+	// Default is the rule when neither FEATURE_FLAGS nor /admin sets one
+	Default string
+}
+
+// featureFlags are the features behind a flag. Both are on by default,
+// as they were before flags existed.
+var featureFlags = []featureFlag{
+	{"what-if", "What-if panel on the entry page", "on"},
+	{"insight-feed", "Generated observations on the dashboard", "on"},
+}
+
+// personalCohort is the cohort of everyone not on a kiosk
+const personalCohort = "personal"
+
+// subjectCookie holds the random ID a percentage rollout is decided on,
+// so a browser keeps seeing the same side of it
+const subjectCookie = "subject"
+
+// flagRule decides who sees a feature. Written as terms joined by "+":
+//
+//	on         everyone
+//	off        no one
+//	25%        a sticky quarter of browsers
+//	library    everyone in a cohort: a kiosk location code, or "personal"
+//
+// so "10%+library" is a tenth of browsers plus everyone at the library
+// kiosk. Empty means off.
+type flagRule struct {
+	Percent int
+	Cohorts []string
+}
+
+func parseFlagRule(s string) (flagRule, error) {
+	var rule flagRule
+	for _, term := range strings.Split(s, "+") {
+		term = strings.ToLower(strings.TrimSpace(term))
+		switch {
+		case term == "" || term == "off":
+		case term == "on":
+			rule.Percent = 100
+		case strings.HasSuffix(term, "%"):
+			n, err := strconv.Atoi(strings.TrimSuffix(term, "%"))
+			if err != nil || n < 0 || n > 100 {
+				return rule, fmt.Errorf("%q is not a percentage from 0%% to 100%%", term)
+			}
+			rule.Percent = max(rule.Percent, n)
+		default:
+			if _, ok := kiosk.location(term); !ok && term != personalCohort {
+				return rule, fmt.Errorf("%q is not a cohort: use a kiosk location or %q", term, personalCohort)
+			}
+			rule.Cohorts = append(rule.Cohorts, term)
+		}
+	}
+	return rule, nil
+}
+
+// features holds the rules in force. Those set on /admin live in the
+// database and override FEATURE_FLAGS; they are re-read whenever the
+// shared state reports a write, so every instance follows a change.
+type featureSet struct {
+	mu         sync.Mutex
+	configured map[string]string // from FEATURE_FLAGS
+	generation uint64
+	loaded     bool
+	rules      map[string]flagRule
+	overrides  map[string]string // from the database
+}
+
+var features = &featureSet{configured: map[string]string{}}
+
+// loadFeatureFlags reads FEATURE_FLAGS, comma-separated name=rule pairs
+// such as "what-if=25%,insight-feed=library+clinic"
+func loadFeatureFlags() (map[string]string, error) {
+	configured := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("FEATURE_FLAGS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, rule, _ := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if featureFlagNamed(name) == nil {
+			return nil, fmt.Errorf("features: unknown flag %q in FEATURE_FLAGS", name)
+		}
+		if _, err := parseFlagRule(rule); err != nil {
+			return nil, fmt.Errorf("features: %s: %v", name, err)
+		}
+		configured[name] = strings.TrimSpace(rule)
+	}
+	return configured, nil
+}
+
+func featureFlagNamed(name string) *featureFlag {
+	for i := range featureFlags {
+		if featureFlags[i].Name == name {
+			return &featureFlags[i]
+		}
+	}
+	return nil
+}
+
+// current returns the rules, reloading the overrides after a write
+func (f *featureSet) current() (map[string]flagRule, map[string]string) {
+	generation := state.Generation()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.loaded && generation == f.generation {
+		return f.rules, f.overrides
+	}
+	overrides := map[string]string{}
+	if rows, err := db.Query(`SELECT name, rule FROM feature_flags`); err == nil {
+		for rows.Next() {
+			var name, rule string
+			if rows.Scan(&name, &rule) == nil {
+				overrides[name] = rule
+			}
+		}
+		rows.Close()
+	} else if f.loaded {
+		return f.rules, f.overrides // keep the last good rules
+	}
+	rules := map[string]flagRule{}
+	for _, flag := range featureFlags {
+		text := flag.Default
+		if v, ok := f.configured[flag.Name]; ok {
+			text = v
+		}
+		if v, ok := overrides[flag.Name]; ok {
+			text = v
+		}
+		rules[flag.Name], _ = parseFlagRule(text) // checked when set
+	}
+	f.rules, f.overrides, f.generation, f.loaded = rules, overrides, generation, true
+	return rules, overrides
+}
+
+// enabled reports whether the feature is on for the browser making r
+func (f *featureSet) enabled(r *http.Request, name string) bool {
+	rules, _ := f.current()
+	rule := rules[name]
+	if slices.Contains(rule.Cohorts, cohortOf(r)) {
+		return true
+	}
+	if rule.Percent >= 100 {
+		return true
+	}
+	if rule.Percent <= 0 {
+		return false
+	}
+	c, err := r.Cookie(subjectCookie)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256([]byte(name + ":" + c.Value))
+	return int(binary.BigEndian.Uint16(sum[:])%100) < rule.Percent
+}
+
+// cohortOf is the kiosk location r comes from, or personalCohort
+func cohortOf(r *http.Request) string {
+	if c, err := r.Cookie(kioskCookie); err == nil {
+		if loc, ok := kiosk.location(c.Value); ok {
+			return loc.Code
+		}
+	}
+	return personalCohort
+}
+
+// assignSubject gives a browser its random subject ID on its first
+// request, and makes it visible to that request's handlers as well
+func assignSubject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie(subjectCookie); err != nil {
+			b := make([]byte, 12)
+			rand.Read(b)
+			c := &http.Cookie{Name: subjectCookie, Value: hex.EncodeToString(b), Path: "/",
+				MaxAge: 365 * 24 * 60 * 60, HttpOnly: true, SameSite: http.SameSiteLaxMode, Secure: r.TLS != nil}
+			http.SetCookie(w, c)
+			r.AddCookie(c)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FlagStatus is a flag as /admin shows it
+type FlagStatus struct {
+	Name        string
+	Description string
+	// Rule is the one in force; Override is set when it comes from /admin
+	Rule     string
+	Override bool
+}
+
+// flagStatuses lists every flag with the rule in force
+func flagStatuses() []FlagStatus {
+	_, overrides := features.current()
+	var list []FlagStatus
+	for _, flag := range featureFlags {
+		s := FlagStatus{Name: flag.Name, Description: flag.Description, Rule: flag.Default}
+		if v, ok := features.configured[flag.Name]; ok {
+			s.Rule = v
+		}
+		if v, ok := overrides[flag.Name]; ok {
+			s.Rule, s.Override = v, true
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// handleAdminSetFlag sets a flag's rule from /admin; an empty rule goes
+// back to FEATURE_FLAGS or the built-in default
+func handleAdminSetFlag(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if featureFlagNamed(name) == nil {
+		http.Error(w, "No such feature flag", http.StatusNotFound)
+		return
+	}
+	rule := strings.TrimSpace(r.FormValue("rule"))
+	var err error
+	if rule == "" {
+		_, err = db.Exec(`DELETE FROM feature_flags WHERE name = ?`, name)
+	} else {
+		if _, perr := parseFlagRule(rule); perr != nil {
+			http.Error(w, perr.Error(), http.StatusBadRequest)
+			return
+		}
+		_, err = db.Exec(`INSERT INTO feature_flags (name, rule, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET rule = excluded.rule, updated_at = excluded.updated_at`, name, rule, time.Now().UTC())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin?notice="+name+"+updated", http.StatusSeeOther)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const insightFeedSchema = `
+	CREATE TABLE IF NOT EXISTS insight_feed (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key TEXT NOT NULL UNIQUE,
+		text TEXT NOT NULL,
+		tone TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+const (
+	// feedLookbackWeeks is how far back weekly observations look
+	feedLookbackWeeks = 8
+	// minExerciseSamples is the fewest check-ins needed on each side of the
+	// exercise comparison
+	minExerciseSamples = 3
+	// exerciseGapThreshold is the score gap worth mentioning
+	exerciseGapThreshold = 5
+	defaultFeedLimit     = 20
+)
+
+// checkinStreakMilestones are the streak lengths that earn a feed item
+var checkinStreakMilestones = []int{7, 14, 30, 60, 100}
+
+// FeedInsight is one machine-generated observation. Key identifies the
+// observation and the period it covers, so regenerating never duplicates it.
+type FeedInsight struct {
+	ID        int64     `json:"id"`
+	Key       string    `json:"key"`
+	Text      string    `json:"text"`
+	Tone      string    `json:"tone"` // "positive", "neutral" or "warning"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// insightGenerator inspects recent entries and returns any observations
+type insightGenerator func(entries []BurnoutEntry, now time.Time) []FeedInsight
+
+var insightGenerators = []insightGenerator{
+	sleepDeclineInsight,
+	exerciseGapInsight,
+	weekdayInsight,
+	streakMilestoneInsight,
+}
+
+// weekKey labels the ISO week containing t, e.g. "2026-W42"
+func weekKey(t time.Time) string {
+	year, week := t.In(loadZone()).ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// ordinal formats n as 1st, 2nd, 3rd, 4th...
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// sleepDeclineInsight reports consecutive weeks of falling average sleep
+func sleepDeclineInsight(entries []BurnoutEntry, now time.Time) []FeedInsight {
+	weeks := bucketEntries(entries, granularityWeek)
+	declining := 0
+	for i := len(weeks) - 1; i > 0; i-- {
+		if !weeks[i].Start.Equal(weeks[i-1].Start.AddDate(0, 0, 7)) {
+			break
+		}
+		if averageOf(weeks[i].Entries, entrySleep) >= averageOf(weeks[i-1].Entries, entrySleep) {
+			break
+		}
+		declining++
+	}
+	if declining < 2 {
+		return nil
+	}
+	latest := weeks[len(weeks)-1]
+	from := weeks[len(weeks)-1-declining]
+	return []FeedInsight{{
+		Key: "sleep-decline:" + weekKey(latest.Start),
+		Text: fmt.Sprintf("%s week of declining sleep — averaging %.1fh, down from %.1fh.",
+			ordinal(declining), averageOf(latest.Entries, entrySleep), averageOf(from.Entries, entrySleep)),
+		Tone: "warning",
+	}}
+}
+
+// exerciseGapInsight compares scores on days with and without exercise
+func exerciseGapInsight(entries []BurnoutEntry, now time.Time) []FeedInsight {
+	var active, rest []BurnoutEntry
+	for _, e := range entries {
+		if e.CreatedAt.Before(now.AddDate(0, 0, -defaultInsightDays)) {
+			continue
+		}
+		if e.Exercise {
+			active = append(active, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	if len(active) < minExerciseSamples || len(rest) < minExerciseSamples {
+		return nil
+	}
+	gap := averageOf(active, entryScore) - averageOf(rest, entryScore)
+	if math.Abs(gap) < exerciseGapThreshold {
+		return nil
+	}
+	tone := "positive"
+	if gap > 0 {
+		tone = "neutral"
+	}
+	return []FeedInsight{{
+		Key:  "exercise-gap:" + weekKey(now),
+		Text: fmt.Sprintf("Exercise days average %.0f points %s than rest days.", math.Abs(gap), lowerOrHigher(gap)),
+		Tone: tone,
+	}}
+}
+
+// weekdayInsight surfaces a standout weekday once per week
+func weekdayInsight(entries []BurnoutEntry, now time.Time) []FeedInsight {
+	var recent []BurnoutEntry
+	for _, e := range entries {
+		if !e.CreatedAt.Before(now.AddDate(0, 0, -defaultWeekdayDays)) {
+			recent = append(recent, e)
+		}
+	}
+	// Feed text is stored once, so it is written in the default language
+	report := buildWeekdayReport(localizer{Lang: defaultLanguage}, recent, defaultWeekdayDays)
+	if report.Standout == "" {
+		return nil
+	}
+	return []FeedInsight{{Key: "weekday:" + weekKey(now), Text: report.Insight, Tone: "neutral"}}
+}
+
+// streakMilestoneInsight celebrates check-in streak milestones
+func streakMilestoneInsight(entries []BurnoutEntry, now time.Time) []FeedInsight {
+	streak := computeStreaks(entries, now).CheckIn
+	for i := len(checkinStreakMilestones) - 1; i >= 0; i-- {
+		m := checkinStreakMilestones[i]
+		if streak >= m {
+			start := bucketStart(now, granularityDay).AddDate(0, 0, 1-streak)
+			return []FeedInsight{{
+				Key:  fmt.Sprintf("streak:%d:%s", m, start.Format("2006-01-02")),
+				Text: fmt.Sprintf("%d days of check-ins in a row — consistency makes every other insight sharper.", m),
+				Tone: "positive",
+			}}
+		}
+	}
+	return nil
+}
+
+// generateInsights runs every generator over recent entries and stores new
+// observations. Observations whose key already exists are ignored.
+func generateInsights() error {
+	now := time.Now()
+	entries, err := queryEntries(now.AddDate(0, 0, -7*feedLookbackWeeks), time.Time{})
+	if err != nil {
+		return err
+	}
+	for _, gen := range insightGenerators {
+		for _, insight := range gen(entries, now) {
+			if _, err := db.Exec(`INSERT OR IGNORE INTO insight_feed (key, text, tone) VALUES (?, ?, ?)`,
+				insight.Key, insight.Text, insight.Tone); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// listFeed returns the newest stored observations
+func listFeed(limit int) ([]FeedInsight, error) {
+	rows, err := db.Query(`SELECT id, key, text, tone, created_at FROM insight_feed
+		ORDER BY created_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	feed := []FeedInsight{}
+	for rows.Next() {
+		var f FeedInsight
+		if err := rows.Scan(&f.ID, &f.Key, &f.Text, &f.Tone, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		feed = append(feed, f)
+	}
+	return feed, rows.Err()
+}
+
+// handleInsightFeed returns the stored feed (GET, ?limit) or generates new
+// observations immediately and returns the refreshed feed (POST). With the
+// insight-feed flag off the feed is always empty.
+func handleInsightFeed(w http.ResponseWriter, r *http.Request) {
+	limit := defaultFeedLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 200 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", v), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	if !features.enabled(r, "insight-feed") {
+		if r.Method != "GET" && r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, []FeedInsight{})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+	case "POST":
+		if err := generateInsights(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	feed, err := listFeed(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, feed)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// fhirSystem is the local code system for check-in concepts that have
+	// no standard code
+	fhirSystem = "urn:burnout-detector:checkin"
+	// fhirQuestionnaireURL is the canonical URL of the check-in questionnaire
+	fhirQuestionnaireURL = "urn:burnout-detector:questionnaire:checkin"
+	loincSystem          = "http://loinc.org"
+	ucumSystem           = "http://unitsofmeasure.org"
+)
+
+// Minimal FHIR R4 resource shapes; only the elements the export fills in
+
+type fhirCoding struct {
+	System  string `json:"system"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+type fhirCodeableConcept struct {
+	Coding []fhirCoding `json:"coding"`
+	Text   string       `json:"text,omitempty"`
+}
+
+type fhirReference struct {
+	Reference string `json:"reference,omitempty"`
+	Display   string `json:"display,omitempty"`
+}
+
+type fhirQuantity struct {
+	Value  float64 `json:"value"`
+	Unit   string  `json:"unit"`
+	System string  `json:"system,omitempty"`
+	Code   string  `json:"code,omitempty"`
+}
+
+type fhirAnswer struct {
+	ValueDecimal *float64 `json:"valueDecimal,omitempty"`
+	ValueInteger *int     `json:"valueInteger,omitempty"`
+	ValueBoolean *bool    `json:"valueBoolean,omitempty"`
+	ValueString  string   `json:"valueString,omitempty"`
+}
+
+type fhirResponseItem struct {
+	LinkID string       `json:"linkId"`
+	Text   string       `json:"text"`
+	Answer []fhirAnswer `json:"answer"`
+}
+
+type fhirQuestionnaireItem struct {
+	LinkID   string `json:"linkId"`
+	Text     string `json:"text"`
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+}
+
+type fhirResource struct {
+	ResourceType      string                `json:"resourceType"`
+	ID                string                `json:"id,omitempty"`
+	Questionnaire     string                `json:"questionnaire,omitempty"`
+	Status            string                `json:"status"`
+	Category          []fhirCodeableConcept `json:"category,omitempty"`
+	Code              *fhirCodeableConcept  `json:"code,omitempty"`
+	Subject           *fhirReference        `json:"subject,omitempty"`
+	Authored          string                `json:"authored,omitempty"`
+	EffectiveDateTime string                `json:"effectiveDateTime,omitempty"`
+	ValueQuantity     *fhirQuantity         `json:"valueQuantity,omitempty"`
+	ValueBoolean      *bool                 `json:"valueBoolean,omitempty"`
+	Interpretation    []fhirCodeableConcept `json:"interpretation,omitempty"`
+	DerivedFrom       []fhirReference       `json:"derivedFrom,omitempty"`
+	Item              []fhirResponseItem    `json:"item,omitempty"`
+}
+
+type fhirBundleEntry struct {
+	FullURL  string `json:"fullUrl"`
+	Resource any    `json:"resource"`
+}
+
+type fhirBundle struct {
+	ResourceType string            `json:"resourceType"`
+	Type         string            `json:"type"`
+	Timestamp    string            `json:"timestamp"`
+	Entry        []fhirBundleEntry `json:"entry"`
+}
+
+// checkinQuestionnaire describes the check-in form as a FHIR Questionnaire
+func checkinQuestionnaire() map[string]any {
+	return map[string]any{
+		"resourceType": "Questionnaire",
+		"id":           "burnout-checkin",
+		"url":          fhirQuestionnaireURL,
+		"name":         "BurnoutCheckIn",
+		"title":        "Daily burnout check-in",
+		"status":       "active",
+		"item": []fhirQuestionnaireItem{
+			{LinkID: "sleep", Text: "Sleep (hours)", Type: "decimal", Required: true},
+			{LinkID: "study_hours", Text: "Study (hours)", Type: "decimal", Required: true},
+			{LinkID: "deadlines", Text: "Deadlines this week", Type: "integer", Required: true},
+			{LinkID: "mood", Text: "Mood (1-5)", Type: "integer", Required: true},
+			{LinkID: "stress", Text: "Stress (1-5)", Type: "integer", Required: true},
+			{LinkID: "exercise", Text: "Did you exercise today?", Type: "boolean"},
+			{LinkID: "journal", Text: "Journal", Type: "text"},
+		},
+	}
+}
+
+// fhirResources converts one entry into a QuestionnaireResponse plus
+// Observations for the score and sleep duration
+func fhirResources(e BurnoutEntry, subject *fhirReference, includeJournal bool) []fhirBundleEntry {
+	at := e.CreatedAt.UTC().Format(time.RFC3339)
+	responseID := fmt.Sprintf("checkin-%d", e.ID)
+	decimal := func(v float64) []fhirAnswer { return []fhirAnswer{{ValueDecimal: &v}} }
+	integer := func(v int) []fhirAnswer { return []fhirAnswer{{ValueInteger: &v}} }
+	exercise := e.Exercise
+
+	response := fhirResource{
+		ResourceType:  "QuestionnaireResponse",
+		ID:            responseID,
+		Questionnaire: fhirQuestionnaireURL,
+		Status:        "completed",
+		Subject:       subject,
+		Authored:      at,
+		Item: []fhirResponseItem{
+			{LinkID: "sleep", Text: "Sleep (hours)", Answer: decimal(e.Sleep)},
+			{LinkID: "study_hours", Text: "Study (hours)", Answer: decimal(e.StudyHours)},
+			{LinkID: "deadlines", Text: "Deadlines this week", Answer: integer(e.Deadlines)},
+			{LinkID: "mood", Text: "Mood (1-5)", Answer: integer(e.Mood)},
+			{LinkID: "stress", Text: "Stress (1-5)", Answer: integer(e.Stress)},
+			{LinkID: "exercise", Text: "Did you exercise today?", Answer: []fhirAnswer{{ValueBoolean: &exercise}}},
+		},
+	}
+	if includeJournal && strings.TrimSpace(e.Journal) != "" {
+		response.Item = append(response.Item, fhirResponseItem{LinkID: "journal", Text: "Journal", Answer: []fhirAnswer{{ValueString: e.Journal}}})
+	}
+
+	survey := []fhirCodeableConcept{{Coding: []fhirCoding{{
+		System: "http://terminology.hl7.org/CodeSystem/observation-category", Code: "survey", Display: "Survey",
+	}}}}
+	derived := []fhirReference{{Reference: fhirUUID(responseID)}}
+	score := fhirResource{
+		ResourceType:      "Observation",
+		ID:                fmt.Sprintf("checkin-%d-score", e.ID),
+		Status:            "final",
+		Category:          survey,
+		Code:              &fhirCodeableConcept{Coding: []fhirCoding{{System: fhirSystem, Code: "burnout-score", Display: "Burnout risk score"}}},
+		Subject:           subject,
+		EffectiveDateTime: at,
+		ValueQuantity:     &fhirQuantity{Value: e.Score, Unit: "score", System: ucumSystem, Code: "{score}"},
+		Interpretation:    []fhirCodeableConcept{{Coding: []fhirCoding{{System: fhirSystem, Code: levelCode(e.Score)}}, Text: levelName(e.Level)}},
+		DerivedFrom:       derived,
+	}
+	sleep := fhirResource{
+		ResourceType:      "Observation",
+		ID:                fmt.Sprintf("checkin-%d-sleep", e.ID),
+		Status:            "final",
+		Category:          survey,
+		Code:              &fhirCodeableConcept{Coding: []fhirCoding{{System: loincSystem, Code: "93832-4", Display: "Sleep duration"}}},
+		Subject:           subject,
+		EffectiveDateTime: at,
+		ValueQuantity:     &fhirQuantity{Value: e.Sleep, Unit: "h", System: ucumSystem, Code: "h"},
+		DerivedFrom:       derived,
+	}
+
+	return []fhirBundleEntry{
+		{FullURL: fhirUUID(responseID), Resource: response},
+		{FullURL: fhirUUID(score.ID), Resource: score},
+		{FullURL: fhirUUID(sleep.ID), Resource: sleep},
+	}
+}
+
+// fhirUUID derives a stable name-based (version 5) UUID URN for a resource
+// id, so exporting the same entry twice yields the same fullUrl
+func fhirUUID(id string) string {
+	sum := sha1.Sum([]byte(fhirSystem + ":" + id))
+	sum[6] = sum[6]&0x0f | 0x50
+	sum[8] = sum[8]&0x3f | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// levelCode is the machine-readable form of scoreLevel
+func levelCode(score float64) string {
+	return [...]string{"healthy", "at-risk", "high-risk", "severe"}[currentScoring().level(score)]
+}
+
+// levelName drops the emoji from a level, e.g. "🟡 At Risk" -> "At Risk"
+func levelName(level string) string {
+	if i := strings.IndexByte(level, ' '); i >= 0 {
+		return level[i+1:]
+	}
+	return level
+}
+
+// handleExportFHIR returns entries in ?from/?to as a FHIR R4 collection
+// Bundle. Because the data leaves the app for a clinical system the caller
+// must pass consent=1; ?patient=Patient/123 sets the subject reference and
+// journal notes are only included with ?journal=1.
+func handleExportFHIR(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("consent") != "1" {
+		http.Error(w, "Sharing check-ins with a health service requires explicit consent (consent=1)", http.StatusForbidden)
+		return
+	}
+	since, until, policy, err := parseExportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if policy.drops("id") {
+		http.Error(w, "FHIR resources need an id; use hash=id to pseudonymise it instead", http.StatusBadRequest)
+		return
+	}
+	entries, err := exportEntries(since, until, policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	subject := &fhirReference{Display: "Burnout Detector user"}
+	if p := q.Get("patient"); p != "" {
+		if !strings.HasPrefix(p, "Patient/") {
+			http.Error(w, "patient must be a reference like Patient/123", http.StatusBadRequest)
+			return
+		}
+		subject = &fhirReference{Reference: p}
+	}
+
+	bundle := fhirBundle{
+		ResourceType: "Bundle",
+		Type:         "collection",
+		Timestamp:    time.Now().UTC().Format(time.RFC3339),
+		Entry:        []fhirBundleEntry{{FullURL: fhirUUID("burnout-checkin"), Resource: checkinQuestionnaire()}},
+	}
+	for _, e := range entries {
+		bundle.Entry = append(bundle.Entry, fhirResources(e, subject, q.Get("journal") == "1")...)
+	}
+
+	w.Header().Set("Content-Type", "application/fhir+json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="burnout-fhir-%s.json"`, time.Now().Format("2006-01-02")))
+	if err := json.NewEncoder(w).Encode(bundle); err != nil {
+		requestLog(r).Error("export failed", "format", "fhir", "err", err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// fieldCheck is the verdict on one check-in field while it is being
+// filled in. Warnings flag values that are allowed but probably a typo;
+// errors are values /calculate would not store as entered.
+type fieldCheck struct {
+	Field   string
+	Level   string // "ok", "warning" or "error"; empty shows nothing
+	Message string
+}
+
+// checkField validates one field of the check-in form. The whole form is
+// posted, so checks can look at related fields too.
+func checkField(loc localizer, field string, r *http.Request) fieldCheck {
+	c := fieldCheck{Field: field}
+	raw := strings.TrimSpace(r.PostFormValue(field))
+	number := func() (float64, bool) {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			c.Level, c.Message = "error", loc.T("validate.number")
+		}
+		return v, err == nil
+	}
+	other := func(name string) float64 {
+		v, _ := strconv.ParseFloat(strings.TrimSpace(r.PostFormValue(name)), 64)
+		return v
+	}
+
+	switch field {
+	case "sleep", "study":
+		if raw == "" {
+			return c // filled in from today's logs
+		}
+		v, ok := number()
+		switch {
+		case !ok:
+		case v < 0 || v > 24:
+			c.Level, c.Message = "error", loc.T("validate.hours_range")
+		case v+other(map[string]string{"sleep": "study", "study": "sleep"}[field]) > 24:
+			c.Level, c.Message = "error", loc.T("validate.over_day")
+		case field == "sleep" && v > 14:
+			c.Level, c.Message = "warning", loc.T("validate.sleep_long", "Hours", raw)
+		case field == "sleep" && v < 3:
+			c.Level, c.Message = "warning", loc.T("validate.sleep_short", "Hours", raw)
+		case field == "study" && v > 16:
+			c.Level, c.Message = "warning", loc.T("validate.study_long", "Hours", raw)
+		default:
+			c.Level = "ok"
+		}
+	case "deadlines":
+		if raw == "" {
+			return c
+		}
+		v, ok := number()
+		switch {
+		case !ok:
+		case v < 0 || v > 100 || v != float64(int(v)):
+			c.Level, c.Message = "error", loc.T("validate.deadlines_range")
+		case v > 20:
+			c.Level, c.Message = "warning", loc.T("validate.deadlines_many", "Count", int(v))
+		default:
+			c.Level = "ok"
+		}
+	case "journal":
+		if n := len([]rune(raw)); n > maxJournalLength {
+			c.Level, c.Message = "error", loc.T("validate.journal_long", "Max", maxJournalLength)
+		}
+	}
+	return c
+}
+
+// checkedFields are the form fields with a validation endpoint
+var checkedFields = []string{"sleep", "study", "deadlines", "journal"}
+
+// handleFieldCheck returns the inline message under one check-in field
+func handleFieldCheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	field := r.PathValue("field")
+	if !slices.Contains(checkedFields, field) {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	tmpl, loc, err := localizedTemplate("index.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.ExecuteTemplate(w, "field-check", checkField(loc, field, r))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// gaugeBandFills are the level colours in the stronger shade used on the
+// gauge arc; scoreBandFills are the pale ones behind line charts
+var gaugeBandFills = [4]color.RGBA{
+	{34, 197, 94, 255},
+	{234, 179, 8, 255},
+	{249, 115, 22, 255},
+	{220, 38, 38, 255},
+}
+
+// gaugeBands are the level thresholds as drawn on the gauge
+func gaugeBands() []scoreBand { return bandsWithFills(gaugeBandFills) }
+
+// gaugeNeedleColor draws the score marker and the PDF needle
+var gaugeNeedleColor = color.RGBA{31, 41, 55, 255}
+
+// Gauge geometry in SVG user units; PNGs are scaled from it
+const (
+	gaugeWidth  = 200
+	gaugeHeight = 120
+	gaugeRadius = 80
+	gaugeStroke = 16
+)
+
+// gaugeAngle is the needle angle for a score in radians: pi at 0 (left),
+// zero at 100 (right)
+func gaugeAngle(score float64) float64 {
+	return math.Pi - math.Pi*max(0, min(100, score))/100
+}
+
+// gaugeColor is the band colour a score falls in
+func gaugeColor(score float64) color.RGBA {
+	for _, band := range gaugeBands() {
+		if score < band.To {
+			return band.Fill
+		}
+	}
+	return gaugeBandFills[len(gaugeBandFills)-1]
+}
+
+// scoreGauge is the half-circle score meter shown on the result card. The
+// same drawing backs the inline SVG, the PNG for clients that cannot show
+// SVG (mail readers) and the PDF report.
+type scoreGauge struct {
+	Score float64
+	Label string // caption under the number, e.g. "Score"
+}
+
+// point is a position on the arc of radius r around the gauge's hub
+func (g scoreGauge) point(score, r float64) (float64, float64) {
+	a := gaugeAngle(score)
+	return gaugeWidth/2 + r*math.Cos(a), gaugeRadius + gaugeStroke/2 + 6 - r*math.Sin(a)
+}
+
+// SVG renders the gauge as an inline, scalable <svg> element
+func (g scoreGauge) SVG() template.HTML {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" role="img" aria-label="%s" class="w-full h-auto" font-family="Inter, Arial, sans-serif">`,
+		gaugeWidth, gaugeHeight, html.EscapeString(fmt.Sprintf("%s: %.0f / 100", g.Label, g.Score)))
+	for _, band := range gaugeBands() {
+		x0, y0 := g.point(band.From, gaugeRadius)
+		x1, y1 := g.point(band.To, gaugeRadius)
+		fmt.Fprintf(&b, `<path d="M %.2f %.2f A %d %d 0 0 1 %.2f %.2f" fill="none" stroke="%s" stroke-width="%d"/>`,
+			x0, y0, gaugeRadius, gaugeRadius, x1, y1, hexColor(band.Fill), gaugeStroke)
+	}
+	// A marker on the ring rather than a needle keeps the centre free for
+	// the number
+	mx, my := g.point(g.Score, gaugeRadius)
+	fmt.Fprintf(&b, `<circle cx="%.2f" cy="%.2f" r="%d" fill="#ffffff" stroke="%s" stroke-width="3"/>`,
+		mx, my, gaugeStroke/2+2, hexColor(gaugeNeedleColor))
+	cx, cy := g.point(0, 0)
+	fmt.Fprintf(&b, `<text x="%.2f" y="%.2f" font-size="32" font-weight="800" text-anchor="middle" fill="%s">%.0f</text>`,
+		cx, cy-6, hexColor(gaugeColor(g.Score)), g.Score)
+	if g.Label != "" {
+		fmt.Fprintf(&b, `<text x="%.2f" y="%.2f" font-size="8" font-weight="600" letter-spacing="1.5" text-anchor="middle" fill="%s">%s</text>`,
+			cx, cy+10, hexColor(chartTextColor), html.EscapeString(strings.ToUpper(g.Label)))
+	}
+	b.WriteString(`</svg>`)
+	// Every value above is a number or escaped, so the markup is safe
+	return template.HTML(b.String())
+}
+
+// PNG rasterises the gauge at scale times the SVG size
+func (g scoreGauge) PNG(scale int) image.Image {
+	s := float64(scale)
+	img := image.NewRGBA(image.Rect(0, 0, gaugeWidth*scale, gaugeHeight*scale))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+	// Colour each pixel of the ring by the score at its angle, so bands
+	// meet on clean radial edges
+	hx, hy := g.point(0, 0)
+	for y := 0; y <= int(hy*s); y++ {
+		for x := 0; x < gaugeWidth*scale; x++ {
+			dx, dy := float64(x)/s-hx, hy-float64(y)/s
+			if math.Abs(math.Hypot(dx, dy)-gaugeRadius) > gaugeStroke/2 {
+				continue
+			}
+			img.Set(x, y, gaugeColor(100-math.Atan2(dy, dx)/math.Pi*100))
+		}
+	}
+	mx, my := g.point(g.Score, gaugeRadius)
+	fillCircle(img, mx*s, my*s, (gaugeStroke/2+3.5)*s, gaugeNeedleColor)
+	fillCircle(img, mx*s, my*s, (gaugeStroke/2+0.5)*s, chartBackground)
+
+	// The bitmap font has one size, so draw the number small and enlarge it
+	label := strconv.Itoa(int(math.Round(g.Score)))
+	text := image.NewRGBA(image.Rect(0, 0, 7*len(label), 13))
+	draw.Draw(text, text.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+	drawText(text, 0, 10, label)
+	k := 3 * scale
+	left, top := int(hx*s)-text.Bounds().Dx()*k/2, int((hy-6)*s)-10*k
+	for y := 0; y < text.Bounds().Dy()*k; y++ {
+		for x := 0; x < text.Bounds().Dx()*k; x++ {
+			if c := text.RGBAAt(x/k, y/k); c != chartBackground {
+				img.Set(left+x, top+y, gaugeColor(g.Score))
+			}
+		}
+	}
+	return img
+}
+
+// handleGauge renders /api/gauge.svg or /api/gauge.png for ?score, for
+// pages and messages that link the gauge instead of inlining it
+func handleGauge(w http.ResponseWriter, r *http.Request) {
+	score, err := strconv.ParseFloat(r.URL.Query().Get("score"), 64)
+	if err != nil || score < 0 || score > 100 {
+		http.Error(w, "score must be a number from 0 to 100", http.StatusBadRequest)
+		return
+	}
+	scale := 2
+	if v := r.URL.Query().Get("scale"); v != "" {
+		if scale, err = strconv.Atoi(v); err != nil || scale < 1 || scale > 8 {
+			http.Error(w, "scale must be 1-8", http.StatusBadRequest)
+			return
+		}
+	}
+	g := scoreGauge{Score: score, Label: requestLocalizer(r).T("result.score")}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if strings.HasSuffix(r.URL.Path, ".svg") {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte(g.SVG()))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, g.PNG(scale)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const goalsSchema = `
+	CREATE TABLE IF NOT EXISTS goals (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		comparator TEXT NOT NULL,
+		target REAL NOT NULL,
+		period TEXT NOT NULL,
+		active BOOLEAN DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS goal_progress (
+		goal_id INTEGER NOT NULL,
+		period_start TEXT NOT NULL,
+		value REAL NOT NULL,
+		met BOOLEAN NOT NULL,
+		evaluated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (goal_id, period_start)
+	);
+`
+
+// goalMetrics maps metric names to how they are measured over a period.
+// Averages use the entry field; "exercise_days" counts days with exercise.
+var goalMetrics = map[string]func([]BurnoutEntry) float64{
+	"score":       func(es []BurnoutEntry) float64 { return averageOf(es, entryScore) },
+	"sleep":       func(es []BurnoutEntry) float64 { return averageOf(es, entrySleep) },
+	"study_hours": func(es []BurnoutEntry) float64 { return averageOf(es, entryStudyHours) },
+	"stress":      func(es []BurnoutEntry) float64 { return averageOf(es, entryStress) },
+	"mood":        func(es []BurnoutEntry) float64 { return averageOf(es, entryMood) },
+	"exercise_days": func(es []BurnoutEntry) float64 {
+		n := 0
+		for _, d := range bucketEntries(es, granularityDay) {
+			if averageOf(d.Entries, entryExercise) > 0 {
+				n++
+			}
+		}
+		return float64(n)
+	},
+}
+
+// Goal is a measurable target over a week or month, e.g. "average at least 7h
+// sleep this week" (metric=sleep, comparator=at_least, target=7, period=week)
+type Goal struct {
+	ID         int64         `json:"id"`
+	Title      string        `json:"title"`
+	Metric     string        `json:"metric"`
+	Comparator string        `json:"comparator"`
+	Target     float64       `json:"target"`
+	Period     string        `json:"period"`
+	Active     bool          `json:"active"`
+	Progress   *GoalProgress `json:"progress,omitempty"`
+}
+
+// GoalProgress is how a goal stands for its current period
+type GoalProgress struct {
+	PeriodStart string  `json:"period_start"`
+	Value       float64 `json:"value"`
+	Entries     int     `json:"entries"`
+	Met         bool    `json:"met"`
+	Percent     float64 `json:"percent"`
+}
+
+// validate normalises and checks a goal submitted by a client
+func (g *Goal) validate() error {
+	g.Title = strings.TrimSpace(g.Title)
+	if g.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if _, ok := goalMetrics[g.Metric]; !ok {
+		return fmt.Errorf("unknown metric %q", g.Metric)
+	}
+	if g.Comparator != "at_least" && g.Comparator != "at_most" {
+		return fmt.Errorf("comparator must be at_least or at_most")
+	}
+	if g.Period != "week" && g.Period != "month" {
+		return fmt.Errorf("period must be week or month")
+	}
+	if g.Target < 0 {
+		return fmt.Errorf("target must not be negative")
+	}
+	return nil
+}
+
+// periodBounds returns the week or month containing t
+func (g Goal) periodBounds(t time.Time) (time.Time, time.Time) {
+	if g.Period == "month" {
+		t = t.In(loadZone())
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := bucketStart(t, granularityWeek)
+	return start, start.AddDate(0, 0, 7)
+}
+
+// evaluate measures the goal over the period containing t
+func (g Goal) evaluate(t time.Time) (GoalProgress, error) {
+	start, end := g.periodBounds(t)
+	entries, err := queryEntries(start, end)
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	p := GoalProgress{PeriodStart: start.Format("2006-01-02"), Entries: len(entries)}
+	if len(entries) == 0 {
+		return p, nil
+	}
+	p.Value = roundTo(goalMetrics[g.Metric](entries), 1)
+
+	switch g.Comparator {
+	case "at_least":
+		p.Met = p.Value >= g.Target
+		if g.Target > 0 {
+			p.Percent = math.Min(100, p.Value/g.Target*100)
+		} else {
+			p.Percent = 100
+		}
+	case "at_most":
+		p.Met = p.Value <= g.Target
+		if p.Met {
+			p.Percent = 100
+		} else if p.Value > 0 {
+			p.Percent = g.Target / p.Value * 100
+		}
+	}
+	p.Percent = roundTo(p.Percent, 0)
+	return p, nil
+}
+
+const goalColumns = `id, title, metric, comparator, target, period, active`
+
+func scanGoal(row interface{ Scan(...any) error }) (Goal, error) {
+	var g Goal
+	err := row.Scan(&g.ID, &g.Title, &g.Metric, &g.Comparator, &g.Target, &g.Period, &g.Active)
+	return g, err
+}
+
+// listGoals returns all goals, optionally only the active ones
+func listGoals(activeOnly bool) ([]Goal, error) {
+	query := `SELECT ` + goalColumns + ` FROM goals`
+	if activeOnly {
+		query += ` WHERE active = 1`
+	}
+	rows, err := db.Query(query + ` ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	goals := []Goal{}
+	for rows.Next() {
+		g, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, g)
+	}
+	return goals, rows.Err()
+}
+
+// withProgress attaches live progress for the current period to each goal
+func withProgress(goals []Goal) ([]Goal, error) {
+	now := time.Now()
+	for i := range goals {
+		p, err := goals[i].evaluate(now)
+		if err != nil {
+			return nil, err
+		}
+		goals[i].Progress = &p
+	}
+	return goals, nil
+}
+
+// runNightlyGoalEvaluation records progress for every active goal. Running
+// just after midnight it evaluates the day that ended, so the final result
+// of a finished week or month is stored once that period closes.
+func runNightlyGoalEvaluation() error {
+	goals, err := listGoals(true)
+	if err != nil {
+		return err
+	}
+	day := time.Now().UTC().AddDate(0, 0, -1)
+	for _, g := range goals {
+		p, err := g.evaluate(day)
+		if err != nil {
+			return err
+		}
+		if p.Entries == 0 {
+			continue
+		}
+		_, err = db.Exec(`
+			INSERT INTO goal_progress (goal_id, period_start, value, met, evaluated_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT(goal_id, period_start) DO UPDATE SET value = excluded.value, met = excluded.met, evaluated_at = excluded.evaluated_at`,
+			g.ID, p.PeriodStart, p.Value, p.Met, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleGoals lists goals with progress (GET) or creates one (POST)
+func handleGoals(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		goals, err := listGoals(r.URL.Query().Get("all") != "1")
+		if err == nil {
+			goals, err = withProgress(goals)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, goals)
+
+	case "POST":
+		var g Goal
+		if err := decodeJSON(r, &g); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := g.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		g.Active = true
+		res, err := db.Exec(`INSERT INTO goals (title, metric, comparator, target, period, active) VALUES (?, ?, ?, ?, ?, 1)`,
+			g.Title, g.Metric, g.Comparator, g.Target, g.Period)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		g.ID, _ = res.LastInsertId()
+		writeJSON(w, http.StatusCreated, g)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGoal reads (GET), replaces (PUT) or deletes (DELETE) a goal
+func handleGoal(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		g, err := scanGoal(db.QueryRow(`SELECT `+goalColumns+` FROM goals WHERE id = ?`, id))
+		if err == sql.ErrNoRows {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p, err := g.evaluate(time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		g.Progress = &p
+		writeJSON(w, http.StatusOK, g)
+
+	case "PUT":
+		var g Goal
+		if err := decodeJSON(r, &g); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := g.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`UPDATE goals SET title = ?, metric = ?, comparator = ?, target = ?, period = ?, active = ? WHERE id = ?`,
+			g.Title, g.Metric, g.Comparator, g.Target, g.Period, g.Active, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		g.ID = id
+		writeJSON(w, http.StatusOK, g)
+
+	case "DELETE":
+		res, err := db.Exec(`DELETE FROM goals WHERE id = ?`, id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			http.Error(w, "Goal not found", http.StatusNotFound)
+			return
+		}
+		db.Exec(`DELETE FROM goal_progress WHERE goal_id = ?`, id)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
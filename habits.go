@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const habitsSchema = `
+	CREATE TABLE IF NOT EXISTS habits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		recovery BOOLEAN NOT NULL DEFAULT 0,
+		archived BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS habit_logs (
+		habit_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		done BOOLEAN NOT NULL,
+		PRIMARY KEY (habit_id, day)
+	);
+`
+
+// habitHistoryDays is how many recent days are returned with each habit
+const habitHistoryDays = 14
+
+// Habit is a user-defined daily habit. Recovery habits feed the score.
+type Habit struct {
+	ID       int64           `json:"id"`
+	Name     string          `json:"name"`
+	Recovery bool            `json:"recovery"`
+	Days     map[string]bool `json:"days"`
+	DoneRate float64         `json:"done_rate"`
+}
+
+// HabitLog marks a habit done or not done on a date
+type HabitLog struct {
+	Date string `json:"date"`
+	Done bool   `json:"done"`
+}
+
+// listHabits returns active habits with the last habitHistoryDays of logs
+func listHabits() ([]Habit, error) {
+	rows, err := db.Query(`SELECT id, name, recovery FROM habits WHERE archived = 0 ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	habits := []Habit{}
+	for rows.Next() {
+		h := Habit{Days: map[string]bool{}}
+		if err := rows.Scan(&h.ID, &h.Name, &h.Recovery); err != nil {
+			return nil, err
+		}
+		habits = append(habits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	since := dayKey(time.Now().AddDate(0, 0, -(habitHistoryDays - 1)))
+	for i := range habits {
+		logs, err := db.Query(`SELECT day, done FROM habit_logs WHERE habit_id = ? AND day >= ?`, habits[i].ID, since)
+		if err != nil {
+			return nil, err
+		}
+		done := 0
+		for logs.Next() {
+			var day string
+			var d bool
+			if err := logs.Scan(&day, &d); err != nil {
+				logs.Close()
+				return nil, err
+			}
+			habits[i].Days[day] = d
+			if d {
+				done++
+			}
+		}
+		logs.Close()
+		habits[i].DoneRate = roundTo(float64(done)/habitHistoryDays*100, 0)
+	}
+	return habits, nil
+}
+
+// recoveryHabitsDone counts recovery habits marked done on day
+func recoveryHabitsDone(day time.Time) (int, error) {
+	var n int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM habit_logs l JOIN habits h ON h.id = l.habit_id
+		WHERE h.recovery = 1 AND h.archived = 0 AND l.done = 1 AND l.day = ?`,
+		dayKey(day)).Scan(&n)
+	return n, err
+}
+
+// handleHabits lists (GET) or creates (POST) habits
+func handleHabits(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		habits, err := listHabits()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, habits)
+
+	case "POST":
+		var h Habit
+		if err := decodeJSON(r, &h); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		h.Name = strings.TrimSpace(h.Name)
+		if h.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`INSERT INTO habits (name, recovery) VALUES (?, ?)`, h.Name, h.Recovery)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.ID, _ = res.LastInsertId()
+		h.Days = map[string]bool{}
+		writeJSON(w, http.StatusCreated, h)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleHabit archives a habit; its logs are kept
+func handleHabit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := db.Exec(`UPDATE habits SET archived = 1 WHERE id = ? AND archived = 0`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Habit not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleHabitLog records done/not-done for a habit on a date (default today)
+func handleHabitLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var l HabitLog
+	if err := decodeJSON(r, &l); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if l.Date == "" {
+		l.Date = dayKey(time.Now())
+	}
+	if _, err := time.Parse("2006-01-02", l.Date); err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM habits WHERE id = ? AND archived = 0`, id).Scan(&exists); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Habit not found", http.StatusNotFound)
+		return
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO habit_logs (habit_id, day, done) VALUES (?, ?, ?)
+		ON CONFLICT(habit_id, day) DO UPDATE SET done = excluded.done`, id, l.Date, l.Done)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, l)
+}
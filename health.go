@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// readyTimeout bounds the database ping behind /readyz
+const readyTimeout = 2 * time.Second
+
+// migrated is set once runMigrations has succeeded at startup
+var migrated atomic.Bool
+
+// handleHealthz is the liveness probe: the process is up and serving
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz is the readiness probe: the database answers, migrations
+// ran and background jobs are scheduled. Any failing check makes it 503,
+// which is also what it returns while shutting down.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"database": "ok", "migrations": "ok", "scheduler": "ok"}
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		checks["database"] = err.Error()
+	}
+	if !migrated.Load() {
+		checks["migrations"] = "not applied"
+	}
+	if !scheduler.Running() {
+		checks["scheduler"] = "not running"
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, v := range checks {
+		if v != "ok" {
+			status, code = "not ready", http.StatusServiceUnavailable
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, map[string]any{"status": status, "checks": checks})
+}
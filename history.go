@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyPageSize is how many entries one page of history holds
+const historyPageSize = 20
+
+// levelRanges are the score ranges (lo, hi] behind each level code,
+// matching scoreLevel and levelCode
+func levelRanges() map[string][2]float64 {
+	s := currentScoring()
+	return map[string][2]float64{
+		"healthy":   {math.Inf(-1), s.HealthyMax},
+		"at-risk":   {s.HealthyMax, s.AtRiskMax},
+		"high-risk": {s.AtRiskMax, s.HighRiskMax},
+		"severe":    {s.HighRiskMax, math.Inf(1)},
+	}
+}
+
+// historyQuery selects one page of past entries, newest first, optionally
+// filtered
+type historyQuery struct {
+	Page     int
+	Since    time.Time // inclusive, zero for no bound
+	Until    time.Time // exclusive, zero for no bound
+	Level    string    // a levelRanges code
+	MinScore *float64
+	MaxScore *float64
+	Search   string // matched against journal notes and advice
+
+	raw url.Values
+}
+
+// parseHistoryQuery reads ?page (1-based), ?from and ?to (YYYY-MM-DD,
+// inclusive), ?level, ?min_score, ?max_score and ?q
+func parseHistoryQuery(r *http.Request) (historyQuery, error) {
+	params := r.URL.Query()
+	q := historyQuery{Page: 1, raw: params}
+	if v := params.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return q, fmt.Errorf("invalid page %q", v)
+		}
+		q.Page = page
+	}
+
+	var err error
+	if q.Since, q.Until, err = parseExportRange(r); err != nil {
+		return q, err
+	}
+	if q.Level = params.Get("level"); q.Level != "" {
+		if _, ok := levelRanges()[q.Level]; !ok {
+			return q, fmt.Errorf("invalid level %q (use healthy, at-risk, high-risk or severe)", q.Level)
+		}
+	}
+	for name, dst := range map[string]**float64{"min_score": &q.MinScore, "max_score": &q.MaxScore} {
+		if v := params.Get(name); v != "" {
+			score, err := strconv.ParseFloat(v, 64)
+			if err != nil || score < 0 || score > 100 {
+				return q, fmt.Errorf("invalid %s %q (use 0-100)", name, v)
+			}
+			*dst = &score
+		}
+	}
+	q.Search = strings.TrimSpace(params.Get("q"))
+	return q, nil
+}
+
+// values encodes the filters with another page number, for the next link
+func (q historyQuery) values(page int) url.Values {
+	v := url.Values{}
+	for key, vals := range q.raw {
+		if len(vals) > 0 && vals[0] != "" && key != "page" {
+			v.Set(key, vals[0])
+		}
+	}
+	v.Set("page", strconv.Itoa(page))
+	return v
+}
+
+// where builds the SQL filter and its arguments
+func (q historyQuery) where() (string, []any) {
+	clauses := []string{"1 = 1"}
+	var args []any
+	if !q.Since.IsZero() {
+		clauses = append(clauses, "created_at >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		clauses = append(clauses, "created_at < ?")
+		args = append(args, q.Until.UTC())
+	}
+	if band, ok := levelRanges()[q.Level]; ok {
+		if !math.IsInf(band[0], -1) {
+			clauses = append(clauses, "score > ?")
+			args = append(args, band[0])
+		}
+		if !math.IsInf(band[1], 1) {
+			clauses = append(clauses, "score <= ?")
+			args = append(args, band[1])
+		}
+	}
+	if q.MinScore != nil {
+		clauses = append(clauses, "score >= ?")
+		args = append(args, *q.MinScore)
+	}
+	if q.MaxScore != nil {
+		clauses = append(clauses, "score <= ?")
+		args = append(args, *q.MaxScore)
+	}
+	if q.Search != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(q.Search) + "%"
+		clauses = append(clauses, `(journal LIKE ? ESCAPE '\' OR advice LIKE ? ESCAPE '\')`)
+		args = append(args, pattern, pattern)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// HistoryPage is one page of entries plus whether more follow
+type HistoryPage struct {
+	Entries []BurnoutEntry
+	Page    int
+	HasMore bool
+}
+
+// queryHistory loads the requested page. One extra row is fetched to learn
+// whether another page exists without a separate COUNT.
+func queryHistory(q historyQuery) (HistoryPage, error) {
+	where, args := q.where()
+	args = append(args, historyPageSize+1, (q.Page-1)*historyPageSize)
+	rows, err := db.Query(`SELECT `+entryColumns+` FROM entries
+		WHERE `+where+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ? OFFSET ?`, args...)
+	if err != nil {
+		return HistoryPage{}, err
+	}
+	defer rows.Close()
+
+	page := HistoryPage{Entries: []BurnoutEntry{}, Page: q.Page}
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return HistoryPage{}, err
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	if len(page.Entries) > historyPageSize {
+		page.Entries = page.Entries[:historyPageSize]
+		page.HasMore = true
+	}
+	return page, rows.Err()
+}
+
+// handleEntries lists past entries as JSON, newest first, one page at a
+// time, with the same filters as the history page. DELETE clears the
+// history and returns an undo token.
+func handleEntries(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "DELETE":
+		// Clear the whole history; undoable for a short while
+		snap, err := deleteEntries("")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		respondDeleted(w, r, snap)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := queryHistory(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	records := []entryRecord{}
+	for _, e := range page.Entries {
+		records = append(records, newEntryRecord(e))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"entries": records, "page": page.Page, "has_more": page.HasMore})
+}
+
+// handleHistoryPage renders /history. HTMX requests for later pages (sent
+// when the last row scrolls into view) get just the next rows.
+func handleHistoryPage(w http.ResponseWriter, r *http.Request) {
+	q, err := parseHistoryQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := queryHistory(q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, _, err := localizedTemplate("history.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]any{
+		"Page":    page,
+		"Next":    "/history?" + q.values(page.Page+1).Encode(),
+		"Filters": q.raw,
+		"Levels":  []string{"healthy", "at-risk", "high-risk", "severe"},
+	}
+	if r.Header.Get("HX-Request") == "true" {
+		tmpl.ExecuteTemplate(w, "rows", data)
+		return
+	}
+	tmpl.Execute(w, data)
+}
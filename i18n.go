@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// defaultLanguage is used when nothing the browser or settings ask for is
+// translated; its catalog is also the fallback for missing messages
+const defaultLanguage = "en"
+
+// localeFiles holds one message catalog per language, locales/<tag>.json,
+// in the go-i18n JSON layout: each message ID maps either to a string or,
+// when it depends on a count, to its CLDR plural forms ("one", "other").
+// Messages are text/template strings, e.g. "{{.Count}} check-ins".
+//
+//go:embed locales
+var localeFiles embed.FS
+
+// message is one catalog entry; simple messages only have Other
+type message struct {
+	One   *texttemplate.Template
+	Other *texttemplate.Template
+}
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	var forms map[string]string
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		forms = map[string]string{"other": text}
+	} else if err := json.Unmarshal(data, &forms); err != nil {
+		return err
+	}
+	for form, text := range forms {
+		tmpl, err := texttemplate.New(form).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return err
+		}
+		switch form {
+		case "one":
+			m.One = tmpl
+		case "other":
+			m.Other = tmpl
+		default:
+			return fmt.Errorf("unsupported plural form %q", form)
+		}
+	}
+	if m.Other == nil {
+		return fmt.Errorf("missing the \"other\" form")
+	}
+	return nil
+}
+
+// catalogs maps a language tag to its messages, loaded once at startup
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]message {
+	all := map[string]map[string]message{}
+	files, _ := fs.Glob(localeFiles, "locales/*.json")
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(file)
+		if err != nil {
+			fatal("locale file unreadable", err)
+		}
+		messages := map[string]message{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			fatal("locale file invalid", fmt.Errorf("%s: %w", file, err))
+		}
+		all[strings.TrimSuffix(path.Base(file), ".json")] = messages
+	}
+	if _, ok := all[defaultLanguage]; !ok {
+		fatal("default locale missing", fmt.Errorf("locales/%s.json is missing", defaultLanguage))
+	}
+	return all
+}
+
+// languages lists the translated language tags, sorted
+func languages() []string {
+	tags := []string{}
+	for tag := range catalogs {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// localizer translates messages into one language and shows times in one
+// zone (the server's when Zone is nil)
+type localizer struct {
+	Lang string
+	Zone *time.Location
+}
+
+// T renders a message. args are name/value pairs for its placeholders; a
+// "Count" argument selects the plural form. Missing messages fall back to
+// the default language, then to the ID itself.
+func (l localizer) T(id string, args ...any) string {
+	m, ok := catalogs[l.Lang][id]
+	if !ok {
+		if m, ok = catalogs[defaultLanguage][id]; !ok {
+			return id
+		}
+	}
+	data := map[string]any{}
+	for i := 0; i+1 < len(args); i += 2 {
+		data[fmt.Sprint(args[i])] = args[i+1]
+	}
+	tmpl := m.Other
+	// English and Indonesian only distinguish "one" from "other"
+	if count, ok := data["Count"]; ok && m.One != nil && fmt.Sprint(count) == "1" {
+		tmpl = m.One
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		slog.Error("message failed to render", "message", id, "err", err)
+		return id
+	}
+	return buf.String()
+}
+
+// negotiateLanguage picks the catalog for a request: the language saved in
+// settings, unless that is "auto", then the best Accept-Language match
+func negotiateLanguage(r *http.Request) string {
+	if s, err := loadSettings(); err == nil && s.Language != "auto" {
+		if tag := matchLanguage(s.Language); tag != "" {
+			return tag
+		}
+	}
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if tag != "" && q > 0 {
+			prefs = append(prefs, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		if tag := matchLanguage(p.tag); tag != "" {
+			return tag
+		}
+	}
+	return defaultLanguage
+}
+
+// matchLanguage maps a requested tag such as "id-ID" to a catalog, trying
+// the full tag before its base language
+func matchLanguage(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	base, _, _ := strings.Cut(tag, "-")
+	for _, candidate := range []string{tag, base} {
+		if _, ok := catalogs[candidate]; ok {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// requestLocalizer returns the localizer for a request's language and the
+// configured time zone
+func requestLocalizer(r *http.Request) localizer {
+	return localizer{Lang: negotiateLanguage(r), Zone: loadZone()}
+}
+
+// localizedTemplate returns a page whose "t", "lang" and date functions
+// follow the request's language and the configured time zone. Each
+// language and zone gets its own copy of the page, made once, so
+// concurrent requests in different languages don't share functions.
+func localizedTemplate(name string, r *http.Request) (*template.Template, localizer, error) {
+	loc := requestLocalizer(r)
+	tmpl, err := templates.localizedPage(name, loc)
+	return tmpl, loc, err
+}
+
+// localizedFuncs are the template functions that depend on the language
+// and time zone
+func localizedFuncs(loc localizer) template.FuncMap {
+	return template.FuncMap{
+		"t":        loc.T,
+		"lang":     func() string { return loc.Lang },
+		"date":     loc.Date,
+		"datetime": loc.DateTime,
+		"time":     loc.Time,
+		"day":      loc.Day,
+		// timezone is the IANA name for the browser, "" for the server's
+		"timezone": func() string {
+			if loc.zone() == time.Local {
+				return ""
+			}
+			return loc.zone().String()
+		},
+	}
+}
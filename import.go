@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxImportSize bounds the uploaded file
+	maxImportSize = 10 << 20
+	// maxImportMemory is how much of an upload is held in memory; the
+	// rest goes to a temporary file until the request ends
+	maxImportMemory = 2 << 20
+	// importPreviewRows is how many parsed rows a dry run echoes back
+	importPreviewRows = 20
+)
+
+// importFields are the entry fields a column can be mapped to
+var importFields = []string{"created_at", "sleep", "study_hours", "deadlines", "mood", "stress", "exercise", "journal"}
+
+// requiredImportFields must be mapped before anything is imported
+var requiredImportFields = []string{"created_at", "sleep", "study_hours", "deadlines", "mood", "stress"}
+
+// importTimeLayouts are the timestamp formats accepted for created_at
+var importTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// ImportRow is one validated row ready to be stored
+type ImportRow struct {
+	Line       int       `json:"line"`
+	CreatedAt  time.Time `json:"created_at"`
+	Sleep      float64   `json:"sleep"`
+	StudyHours float64   `json:"study_hours"`
+	Deadlines  int       `json:"deadlines"`
+	Mood       int       `json:"mood"`
+	Stress     int       `json:"stress"`
+	Exercise   bool      `json:"exercise"`
+	Journal    string    `json:"journal,omitempty"`
+	Score      float64   `json:"score"`
+	Level      string    `json:"level"`
+}
+
+// ImportError points at a row (0 for the header) that failed validation
+type ImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ImportResult is the response of /api/import/{format}. A dry run fills in the
+// suggested mapping and a preview so the client can confirm before importing.
+// A real import is queued: Job points at /api/queue/{id}, whose result holds
+// the Imported and Duplicates counts once it is done.
+type ImportResult struct {
+	DryRun     bool              `json:"dry_run"`
+	Headers    []string          `json:"headers"`
+	Mapping    map[string]string `json:"mapping"`
+	Preview    []ImportRow       `json:"preview"`
+	Errors     []ImportError     `json:"errors"`
+	Valid      int               `json:"valid"`
+	Duplicates int               `json:"duplicates"`
+	Imported   int               `json:"imported"`
+	Job        int64             `json:"job,omitempty"`
+	JobURL     string            `json:"job_url,omitempty"`
+}
+
+// importJob is the queued part of an import: storing rows that already
+// passed validation
+type importJob struct {
+	Rows []ImportRow `json:"rows"`
+}
+
+// importOutcome is an import job's result
+type importOutcome struct {
+	Imported   int `json:"imported"`
+	Duplicates int `json:"duplicates"`
+}
+
+// normalizeHeader folds "Study Hours" and "study_hours" to the same key
+func normalizeHeader(h string) string {
+	h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(h)
+}
+
+// suggestMapping matches headers to entry fields by name, including the
+// columns written by our own exports and any adapter-specific aliases
+func suggestMapping(headers []string, extra map[string]string) map[string]string {
+	aliases := map[string]string{"date": "created_at", "timestamp": "created_at", "study": "study_hours"}
+	for k, v := range extra {
+		aliases[k] = v
+	}
+	mapping := map[string]string{}
+	for _, h := range headers {
+		key := normalizeHeader(h)
+		if alias, ok := aliases[key]; ok {
+			key = alias
+		}
+		for _, f := range importFields {
+			if key == f {
+				if _, taken := mapping[f]; !taken {
+					mapping[f] = h
+				}
+			}
+		}
+	}
+	return mapping
+}
+
+// parseImportBool accepts the usual spreadsheet spellings of yes and no
+func parseImportBool(v string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "1", "true", "yes", "y", "x", "on":
+		return true, nil
+	case "", "0", "false", "no", "n", "off":
+		return false, nil
+	}
+	return false, fmt.Errorf("%q is not yes/no", v)
+}
+
+// parseImportRow validates one row of mapped field values
+func parseImportRow(values map[string]string) (ImportRow, error) {
+	var row ImportRow
+	get := func(field string) string {
+		return strings.TrimSpace(values[field])
+	}
+	number := func(field string, lo, hi float64) (float64, error) {
+		v, err := strconv.ParseFloat(get(field), 64)
+		if err != nil || v < lo || v > hi {
+			return 0, fmt.Errorf("%s must be a number between %g and %g", field, lo, hi)
+		}
+		return v, nil
+	}
+
+	var err error
+	for _, layout := range importTimeLayouts {
+		if row.CreatedAt, err = time.Parse(layout, get("created_at")); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return row, fmt.Errorf("created_at %q is not a recognised date", get("created_at"))
+	}
+	if row.Sleep, err = number("sleep", 0, 24); err != nil {
+		return row, err
+	}
+	if row.StudyHours, err = number("study_hours", 0, 24); err != nil {
+		return row, err
+	}
+	deadlines, err := number("deadlines", 0, 100)
+	if err != nil {
+		return row, err
+	}
+	mood, err := number("mood", 1, 5)
+	if err != nil {
+		return row, err
+	}
+	stress, err := number("stress", 1, 5)
+	if err != nil {
+		return row, err
+	}
+	row.Deadlines, row.Mood, row.Stress = int(deadlines), int(mood), int(stress)
+	if row.Exercise, err = parseImportBool(get("exercise")); err != nil {
+		return row, fmt.Errorf("exercise: %v", err)
+	}
+	row.Journal = get("journal")
+	if len([]rune(row.Journal)) > maxJournalLength {
+		row.Journal = string([]rune(row.Journal)[:maxJournalLength])
+	}
+
+	// Scores are always recomputed so imported history matches live check-ins
+	row.Score = burnoutScore(row.Sleep, row.StudyHours, row.Deadlines, row.Stress, recoveryCredit(row.Exercise, row.CreatedAt))
+	row.Level = scoreLevel(row.Score)
+	return row, nil
+}
+
+// handleImport imports entries from a multipart upload in any format in
+// importAdapters, e.g. POST /api/import/csv. Form fields:
+//
+//	file     the upload (a CSV needs a header row)
+//	mapping  optional JSON object of entry field -> mapping expression (see
+//	         compileMapping); columns named like the fields map automatically
+//	dry_run  "1" to validate and preview without writing anything
+//
+// The file is parsed and validated here; storing the rows runs in the job
+// queue, and the response is 202 Accepted with the job to poll. The import
+// is all-or-nothing: any invalid row aborts it. Rows whose timestamp
+// already exists are skipped, so re-importing an export is safe.
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	adapter, ok := importAdapters[r.PathValue("format")]
+	if !ok {
+		http.Error(w, "unknown import format; use one of "+strings.Join(importFormats(), ", "), http.StatusNotFound)
+		return
+	}
+	if r.ContentLength > maxImportSize {
+		writeBodyError(w, &http.MaxBytesError{Limit: maxImportSize})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	// Only the file may spill to disk; the other fields are small
+	if err := r.ParseMultipartForm(maxImportMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyError(w, err)
+			return
+		}
+		http.Error(w, "expected a multipart upload under 10 MB: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	if len(r.MultipartForm.File["file"]) != 1 {
+		http.Error(w, "upload exactly one file, under the field name \"file\"", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	source, err := adapter.Open(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	headers := source.Headers()
+
+	result := ImportResult{
+		DryRun:  r.FormValue("dry_run") == "1",
+		Headers: headers,
+		Mapping: suggestMapping(headers, adapter.Aliases),
+		Preview: []ImportRow{},
+		Errors:  []ImportError{},
+	}
+	if m := r.FormValue("mapping"); m != "" {
+		var custom map[string]string
+		dec := json.NewDecoder(strings.NewReader(m))
+		if err := dec.Decode(&custom); err != nil || dec.More() {
+			if err == nil {
+				err = errors.New("unexpected data after the object")
+			}
+			http.Error(w, "mapping must be a JSON object: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		for field, expr := range custom {
+			result.Mapping[field] = expr
+		}
+	}
+
+	fields := map[string]fieldMapping{}
+	for field, expr := range result.Mapping {
+		if !slices.Contains(importFields, field) {
+			result.Errors = append(result.Errors, ImportError{Message: fmt.Sprintf("unknown field %q", field)})
+			continue
+		}
+		mapping, err := compileMapping(expr, headers)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Message: fmt.Sprintf("%s: %v", field, err)})
+			continue
+		}
+		fields[field] = mapping
+	}
+	for _, field := range requiredImportFields {
+		if _, ok := result.Mapping[field]; !ok {
+			result.Errors = append(result.Errors, ImportError{Message: fmt.Sprintf("no column mapped to %s", field)})
+		}
+	}
+	if len(result.Errors) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	var rows []ImportRow
+	for {
+		line, record, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: line, Message: err.Error()})
+			break
+		}
+		values := map[string]string{}
+		for field, mapping := range fields {
+			if values[field], err = mapping(record); err != nil {
+				err = fmt.Errorf("%s: %v", field, err)
+				break
+			}
+		}
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: line, Message: err.Error()})
+			continue
+		}
+		row, err := parseImportRow(values)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{Line: line, Message: err.Error()})
+			continue
+		}
+		row.Line = line
+		rows = append(rows, row)
+		if len(result.Preview) < importPreviewRows {
+			result.Preview = append(result.Preview, row)
+		}
+	}
+	result.Valid = len(rows)
+
+	if result.DryRun {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	if len(result.Errors) > 0 {
+		writeJSON(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+
+	if result.Job, err = queue.enqueue("import", importJob{Rows: rows}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	result.JobURL = fmt.Sprintf("/api/queue/%d", result.Job)
+	w.Header().Set("Location", result.JobURL)
+	writeJSON(w, http.StatusAccepted, result)
+}
+
+// runImportJob stores the rows of a queued import in one transaction
+func runImportJob(payload json.RawMessage) (any, error) {
+	var job importJob
+	var out importOutcome
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return nil, err
+	}
+	// Loaded before the transaction: reading the formula and advice inside
+	// would need a second connection for every row, since each insert moves
+	// the data generation
+	advise := currentAdvisor().advise
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, row := range job.Rows {
+		createdAt := row.CreatedAt.UTC().Format("2006-01-02 15:04:05")
+		var exists int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM entries WHERE created_at = ?`, createdAt).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if exists > 0 {
+			out.Duplicates++
+			continue
+		}
+		advice := advise(row.Sleep, row.Deadlines, row.Stress, row.Score)
+		if _, err := tx.Exec(`
+			INSERT INTO entries (created_at, sleep, study_hours, deadlines, mood, stress, exercise, score, level, advice, journal)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			createdAt, row.Sleep, row.StudyHours, row.Deadlines, row.Mood, row.Stress, row.Exercise,
+			row.Score, row.Level, advice, row.Journal); err != nil {
+			return nil, fmt.Errorf("line %d: %w", row.Line, err)
+		}
+		out.Imported++
+	}
+	return out, tx.Commit()
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// importSource yields the records of one uploaded file. Records are keyed
+// by column name so adapters for any format feed the same mapping step.
+type importSource interface {
+	// Headers lists the column names available to the mapping
+	Headers() []string
+	// Next returns the next record and its line in the file, or io.EOF
+	Next() (line int, record map[string]string, err error)
+}
+
+// importAdapter opens one source format. Aliases add format-specific
+// header names to the automatic mapping.
+type importAdapter struct {
+	Open    func(io.Reader) (importSource, error)
+	Aliases map[string]string
+}
+
+// importAdapters are the formats accepted by /api/import/{format}. A new
+// source (another wellness app, an LMS export) is added here as an adapter.
+var importAdapters = map[string]importAdapter{
+	"csv":    {Open: openCSVSource},
+	"ndjson": {Open: openNDJSONSource},
+}
+
+// importFormats lists the adapter names for error messages
+func importFormats() []string {
+	var names []string
+	for name := range importAdapters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// csvSource reads a CSV with a header row
+type csvSource struct {
+	reader  *csv.Reader
+	headers []string
+	line    int
+}
+
+func openCSVSource(r io.Reader) (importSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the CSV header: %v", err)
+	}
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	}
+	return &csvSource{reader: reader, headers: headers, line: 1}, nil
+}
+
+func (s *csvSource) Headers() []string { return s.headers }
+
+func (s *csvSource) Next() (int, map[string]string, error) {
+	fields, err := s.reader.Read()
+	s.line++
+	if err != nil {
+		return s.line, nil, err
+	}
+	record := map[string]string{}
+	for i, h := range s.headers {
+		if i < len(fields) {
+			record[h] = fields[i]
+		}
+	}
+	return s.line, record, nil
+}
+
+// ndjsonSource reads JSON Lines such as /api/export.ndjson. The headers are
+// the keys of the first object.
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+	headers []string
+	first   map[string]string
+	line    int
+}
+
+func openNDJSONSource(r io.Reader) (importSource, error) {
+	s := &ndjsonSource{scanner: bufio.NewScanner(r)}
+	s.scanner.Buffer(make([]byte, 64*1024), maxImportSize)
+	line, first, err := s.read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the file has no records")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", line, err)
+	}
+	for key := range first {
+		s.headers = append(s.headers, key)
+	}
+	sort.Strings(s.headers)
+	s.first = first
+	return s, nil
+}
+
+func (s *ndjsonSource) Headers() []string { return s.headers }
+
+func (s *ndjsonSource) Next() (int, map[string]string, error) {
+	if s.first != nil {
+		record := s.first
+		s.first = nil
+		return s.line, record, nil
+	}
+	return s.read()
+}
+
+// read decodes the next non-blank line, flattening values to strings
+func (s *ndjsonSource) read() (int, map[string]string, error) {
+	for s.scanner.Scan() {
+		s.line++
+		text := strings.TrimSpace(s.scanner.Text())
+		if text == "" {
+			continue
+		}
+		var object map[string]any
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return s.line, nil, fmt.Errorf("not a JSON object: %v", err)
+		}
+		record := map[string]string{}
+		for key, v := range object {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				record[key] = v
+			case float64:
+				record[key] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[key] = fmt.Sprint(v)
+			}
+		}
+		return s.line, record, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return s.line, nil, err
+	}
+	return s.line, nil, io.EOF
+}
+
+// fieldMapping produces one entry field's raw value from a record
+type fieldMapping func(record map[string]string) (string, error)
+
+// compileMapping parses a mapping expression. The simplest expression is a
+// column name; steps can be piped after it to reshape the value:
+//
+//	"Sleep (min)" | div 60                 minutes to hours
+//	Mood | scale 1 10 1 5 | round          a 1-10 scale onto 1-5
+//	Day | date "02/01/2006"                a non-ISO date (Go layout)
+//	Workout | map yes=true no=false        translate labels
+//	Stress | default 3                     fill blanks
+//	const 0                                a fixed value, no column
+//
+// Column names containing spaces or "|" must be quoted unless they match
+// a header exactly.
+func compileMapping(expr string, headers []string) (fieldMapping, error) {
+	if slices.Contains(headers, expr) {
+		return func(record map[string]string) (string, error) { return record[expr], nil }, nil
+	}
+	segments, err := splitMapping(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var source fieldMapping
+	head := segments[0]
+	switch {
+	case len(head) == 2 && head[0] == "const":
+		value := head[1]
+		source = func(map[string]string) (string, error) { return value, nil }
+	case len(head) >= 1 && slices.Contains(headers, strings.Join(head, " ")):
+		column := strings.Join(head, " ")
+		source = func(record map[string]string) (string, error) { return record[column], nil }
+	default:
+		return nil, fmt.Errorf("column %q is not in the file", strings.Join(head, " "))
+	}
+
+	steps := []func(string) (string, error){}
+	for _, seg := range segments[1:] {
+		step, err := compileMappingStep(seg)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return func(record map[string]string) (string, error) {
+		v, err := source(record)
+		for _, step := range steps {
+			if err != nil {
+				break
+			}
+			v, err = step(strings.TrimSpace(v))
+		}
+		return v, err
+	}, nil
+}
+
+// splitMapping breaks an expression into "|"-separated segments of words,
+// honouring double quotes
+func splitMapping(expr string) ([][]string, error) {
+	var segments [][]string
+	var words []string
+	var word strings.Builder
+	quoted, inWord := false, false
+	flush := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case quoted:
+			word.WriteRune(r)
+		case r == '|':
+			flush()
+			if len(words) == 0 {
+				return nil, fmt.Errorf("empty step in %q", expr)
+			}
+			segments = append(segments, words)
+			words = nil
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in %q", expr)
+	}
+	flush()
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty step in %q", expr)
+	}
+	return append(segments, words), nil
+}
+
+// compileMappingStep builds one transform. Blank values pass through the
+// numeric steps untouched so a later default can fill them.
+func compileMappingStep(words []string) (func(string) (string, error), error) {
+	name, args := words[0], words[1:]
+	nums := make([]float64, len(args))
+	numeric := func(n int) error {
+		if len(args) != n {
+			return fmt.Errorf("%s takes %d number(s)", name, n)
+		}
+		for i, a := range args {
+			v, err := strconv.ParseFloat(a, 64)
+			if err != nil {
+				return fmt.Errorf("%s: %q is not a number", name, a)
+			}
+			nums[i] = v
+		}
+		return nil
+	}
+	arith := func(f func(float64) float64) func(string) (string, error) {
+		return func(v string) (string, error) {
+			if v == "" {
+				return v, nil
+			}
+			x, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", fmt.Errorf("%s: %q is not a number", name, v)
+			}
+			return strconv.FormatFloat(f(x), 'f', -1, 64), nil
+		}
+	}
+
+	switch name {
+	case "div":
+		if err := numeric(1); err != nil {
+			return nil, err
+		}
+		if nums[0] == 0 {
+			return nil, fmt.Errorf("div by zero")
+		}
+		return arith(func(x float64) float64 { return x / nums[0] }), nil
+	case "mul":
+		if err := numeric(1); err != nil {
+			return nil, err
+		}
+		return arith(func(x float64) float64 { return x * nums[0] }), nil
+	case "add":
+		if err := numeric(1); err != nil {
+			return nil, err
+		}
+		return arith(func(x float64) float64 { return x + nums[0] }), nil
+	case "scale":
+		if err := numeric(4); err != nil {
+			return nil, err
+		}
+		if nums[0] == nums[1] {
+			return nil, fmt.Errorf("scale needs a non-empty source range")
+		}
+		return arith(func(x float64) float64 {
+			return nums[2] + (x-nums[0])*(nums[3]-nums[2])/(nums[1]-nums[0])
+		}), nil
+	case "round":
+		if err := numeric(0); err != nil {
+			return nil, err
+		}
+		return arith(math.Round), nil
+	case "date":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("date takes one Go layout, e.g. date \"02/01/2006\"")
+		}
+		layout := args[0]
+		return func(v string) (string, error) {
+			if v == "" {
+				return v, nil
+			}
+			t, err := time.Parse(layout, v)
+			if err != nil {
+				return "", fmt.Errorf("%q does not match the date layout %q", v, layout)
+			}
+			if len(layout) <= len("2006-01-02") && t.Hour() == 0 && t.Minute() == 0 {
+				// Keep bare dates bare so they get the same noon placement
+				return t.Format("2006-01-02"), nil
+			}
+			return t.Format(time.RFC3339), nil
+		}, nil
+	case "map":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("map needs at least one from=to pair")
+		}
+		table := map[string]string{}
+		for _, pair := range args {
+			from, to, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("map: %q is not a from=to pair", pair)
+			}
+			table[strings.ToLower(from)] = to
+		}
+		return func(v string) (string, error) {
+			if to, ok := table[strings.ToLower(v)]; ok {
+				return to, nil
+			}
+			return "", fmt.Errorf("map: no translation for %q", v)
+		}, nil
+	case "default":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("default takes one value")
+		}
+		return func(v string) (string, error) {
+			if v == "" {
+				return args[0], nil
+			}
+			return v, nil
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown step %q (use div, mul, add, scale, round, date, map or default)", name)
+}
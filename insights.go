@@ -0,0 +1,357 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultInsightDays is the analysis window used when no range is given
+const defaultInsightDays = 30
+
+// minCorrelationSamples is the fewest check-ins needed before a correlation is reported
+const minCorrelationSamples = 5
+
+// insightInputs are the check-in fields analysed as potential drivers
+var insightInputs = []chartSeries{
+	{Label: "sleep", Field: entrySleep},
+	{Label: "study_hours", Field: entryStudyHours},
+	{Label: "deadlines", Field: entryDeadlines},
+	{Label: "stress", Field: entryStress},
+	{Label: "exercise", Field: entryExercise},
+	{Label: "mood", Field: entryMood},
+}
+
+// Correlation relates one input to one outcome
+type Correlation struct {
+	Input      string   `json:"input"`
+	Target     string   `json:"target"`
+	Pearson    *float64 `json:"pearson"`
+	Spearman   *float64 `json:"spearman"`
+	SampleSize int      `json:"sample_size"`
+}
+
+// CorrelationReport is the payload of /api/insights/correlations
+type CorrelationReport struct {
+	Days           int           `json:"days"`
+	SampleSize     int           `json:"sample_size"`
+	Correlations   []Correlation `json:"correlations"`
+	StrongestInput string        `json:"strongest_input,omitempty"`
+	Summary        string        `json:"summary"`
+}
+
+// correlate computes both coefficients between input and target over entries
+func correlate(entries []BurnoutEntry, input, target chartSeries) Correlation {
+	c := Correlation{Input: input.Label, Target: target.Label, SampleSize: len(entries)}
+	if len(entries) < minCorrelationSamples {
+		return c
+	}
+	xs := make([]float64, len(entries))
+	ys := make([]float64, len(entries))
+	for i, e := range entries {
+		xs[i] = input.Field(e)
+		ys[i] = target.Field(e)
+	}
+	if r, ok := pearson(xs, ys); ok {
+		r = roundTo(r, 3)
+		c.Pearson = &r
+	}
+	if r, ok := spearman(xs, ys); ok {
+		r = roundTo(r, 3)
+		c.Spearman = &r
+	}
+	return c
+}
+
+// buildCorrelationReport correlates every input with score and mood
+func buildCorrelationReport(loc localizer, entries []BurnoutEntry, days int) CorrelationReport {
+	report := CorrelationReport{Days: days, SampleSize: len(entries), Correlations: []Correlation{}}
+	targets := []chartSeries{{Label: "score", Field: entryScore}, {Label: "mood", Field: entryMood}}
+
+	var strongest float64
+	for _, target := range targets {
+		for _, input := range insightInputs {
+			if input.Label == target.Label {
+				continue
+			}
+			c := correlate(entries, input, target)
+			report.Correlations = append(report.Correlations, c)
+			if target.Label == "score" && c.Pearson != nil && math.Abs(*c.Pearson) > math.Abs(strongest) {
+				strongest = *c.Pearson
+				report.StrongestInput = c.Input
+			}
+		}
+	}
+
+	switch {
+	case len(entries) < minCorrelationSamples:
+		report.Summary = loc.T("correlation.too_few", "Count", minCorrelationSamples)
+	case report.StrongestInput == "":
+		report.Summary = loc.T("correlation.none")
+	default:
+		id := "correlation.driver_higher"
+		if strongest < 0 {
+			id = "correlation.driver_lower"
+		}
+		name := inputDisplayName(loc, report.StrongestInput)
+		report.Summary = loc.T(id, "Input", name, "InputLower", strings.ToLower(name), "R", fmt.Sprintf("%.2f", strongest))
+	}
+	return report
+}
+
+// inputDisplayName turns an input key into readable text
+func inputDisplayName(loc localizer, input string) string {
+	id := "input." + input
+	if name := loc.T(id); name != id {
+		return name
+	}
+	return input
+}
+
+// handleCorrelations returns Pearson/Spearman correlations for each input
+func handleCorrelations(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultInsightDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := queryEntries(time.Now().AddDate(0, 0, -days), time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(buildCorrelationReport(requestLocalizer(r), entries, days)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// defaultWeekdayDays is the window for day-of-week patterns; long enough to
+// see each weekday several times
+const defaultWeekdayDays = 90
+
+// weekdayPatternThreshold is the score gap (vs the other days) worth calling out
+const weekdayPatternThreshold = 10.0
+
+// WeekdayStat summarises all check-ins that fell on one weekday
+type WeekdayStat struct {
+	Weekday    string  `json:"weekday"`
+	AvgScore   float64 `json:"avg_score"`
+	AvgSleep   float64 `json:"avg_sleep"`
+	SampleSize int     `json:"sample_size"`
+}
+
+// WeekdayReport is the payload of /api/insights/weekday
+type WeekdayReport struct {
+	Days     int           `json:"days"`
+	Weekdays []WeekdayStat `json:"weekdays"`
+	Chart    ChartData     `json:"chart"`
+	Insight  string        `json:"insight"`
+	// Standout is the weekday whose gap cleared the pattern threshold, if any
+	Standout string `json:"standout,omitempty"`
+}
+
+// buildWeekdayReport groups entries Monday-first and finds the standout day
+func buildWeekdayReport(loc localizer, entries []BurnoutEntry, days int) WeekdayReport {
+	var byDay [7][]BurnoutEntry
+	zone := loadZone()
+	for _, e := range entries {
+		i := (int(e.CreatedAt.In(zone).Weekday()) + 6) % 7 // Monday = 0
+		byDay[i] = append(byDay[i], e)
+	}
+
+	report := WeekdayReport{Days: days, Chart: ChartData{Labels: []string{}, Data: []float64{}}}
+	sleepData := []float64{}
+	for i, group := range byDay {
+		name := time.Weekday((i + 1) % 7).String()
+		stat := WeekdayStat{
+			Weekday:    name,
+			AvgScore:   roundTo(averageOf(group, entryScore), 1),
+			AvgSleep:   roundTo(averageOf(group, entrySleep), 1),
+			SampleSize: len(group),
+		}
+		report.Weekdays = append(report.Weekdays, stat)
+		report.Chart.Labels = append(report.Chart.Labels, name[:3])
+		report.Chart.Data = append(report.Chart.Data, stat.AvgScore)
+		sleepData = append(sleepData, stat.AvgSleep)
+	}
+	report.Chart.Datasets = []ChartDataset{
+		{Label: "Average Score", Data: report.Chart.Data},
+		{Label: "Average Sleep (Hrs)", Data: sleepData},
+	}
+
+	// Compare each weekday with the average of all other days and report the
+	// biggest gap, ignoring days with too few check-ins to be meaningful
+	var bestGap float64
+	var bestDay string
+	bestIndex := -1
+	for i, group := range byDay {
+		if len(group) < 2 {
+			continue
+		}
+		var others []BurnoutEntry
+		for j, g := range byDay {
+			if j != i {
+				others = append(others, g...)
+			}
+		}
+		if len(others) == 0 {
+			continue
+		}
+		gap := averageOf(group, entryScore) - averageOf(others, entryScore)
+		if math.Abs(gap) > math.Abs(bestGap) {
+			bestGap, bestDay, bestIndex = gap, report.Weekdays[i].Weekday, i
+		}
+	}
+
+	switch {
+	case bestDay == "":
+		report.Insight = loc.T("weekday.too_few")
+	case math.Abs(bestGap) < weekdayPatternThreshold:
+		report.Insight = loc.T("weekday.even")
+	default:
+		report.Standout = bestDay
+		id := "weekday.higher"
+		if bestGap < 0 {
+			id = "weekday.lower"
+		}
+		// 2006-01-02 was a Monday, so this is a date on the standout weekday
+		day := time.Date(2006, 1, 2+bestIndex, 0, 0, 0, 0, time.UTC)
+		report.Insight = loc.T(id, "Day", loc.Format(day, "Monday"), "Points", fmt.Sprintf("%.0f", math.Abs(bestGap)))
+	}
+	return report
+}
+
+// handleWeekdayPatterns returns average score and sleep by weekday
+func handleWeekdayPatterns(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultWeekdayDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := queryEntries(time.Now().AddDate(0, 0, -days), time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(buildWeekdayReport(requestLocalizer(r), entries, days)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// DayDetail describes one day's averages for the best/worst comparison
+type DayDetail struct {
+	Date      string  `json:"date"`
+	AvgScore  float64 `json:"avg_score"`
+	Sleep     float64 `json:"sleep"`
+	Study     float64 `json:"study_hours"`
+	Deadlines float64 `json:"deadlines"`
+	Mood      float64 `json:"mood"`
+	Stress    float64 `json:"stress"`
+	Exercised bool    `json:"exercised"`
+}
+
+// BestWorstReport is the payload of /api/insights/best-worst
+type BestWorstReport struct {
+	Days        int        `json:"days"`
+	Best        *DayDetail `json:"best,omitempty"`
+	Worst       *DayDetail `json:"worst,omitempty"`
+	Differences []string   `json:"differences"`
+}
+
+// dayDetail averages the inputs of a single day's entries
+func dayDetail(b entryBucket) *DayDetail {
+	return &DayDetail{
+		Date:      b.Start.Format("2006-01-02"),
+		AvgScore:  roundTo(averageOf(b.Entries, entryScore), 1),
+		Sleep:     roundTo(averageOf(b.Entries, entrySleep), 1),
+		Study:     roundTo(averageOf(b.Entries, entryStudyHours), 1),
+		Deadlines: roundTo(averageOf(b.Entries, entryDeadlines), 1),
+		Mood:      roundTo(averageOf(b.Entries, entryMood), 1),
+		Stress:    roundTo(averageOf(b.Entries, entryStress), 1),
+		Exercised: averageOf(b.Entries, entryExercise) > 0,
+	}
+}
+
+// buildBestWorstReport finds the lowest- and highest-scoring days and
+// explains what was different about them
+func buildBestWorstReport(loc localizer, entries []BurnoutEntry, days int) BestWorstReport {
+	report := BestWorstReport{Days: days, Differences: []string{}}
+	for _, b := range bucketEntries(entries, granularityDay) {
+		d := dayDetail(b)
+		if report.Best == nil || d.AvgScore < report.Best.AvgScore {
+			report.Best = d
+		}
+		if report.Worst == nil || d.AvgScore > report.Worst.AvgScore {
+			report.Worst = d
+		}
+	}
+	if report.Best == nil || report.Best.Date == report.Worst.Date {
+		report.Differences = append(report.Differences, loc.T("bestworst.too_few"))
+		return report
+	}
+
+	best, worst := report.Best, report.Worst
+	// Each difference has a message for either direction, e.g.
+	// bestworst.sleep_more and bestworst.sleep_less
+	differ := func(diff, min float64, more, less, amount string) {
+		if math.Abs(diff) < min {
+			return
+		}
+		id := less
+		if diff > 0 {
+			id = more
+		}
+		report.Differences = append(report.Differences, loc.T(id, "Amount", amount, "Count", math.Round(math.Abs(diff))))
+	}
+	diff := best.Sleep - worst.Sleep
+	differ(diff, 0.5, "bestworst.sleep_more", "bestworst.sleep_less", fmt.Sprintf("%.1f", math.Abs(diff)))
+	diff = best.Study - worst.Study
+	differ(diff, 0.5, "bestworst.study_more", "bestworst.study_less", fmt.Sprintf("%.1f", math.Abs(diff)))
+	diff = best.Deadlines - worst.Deadlines
+	differ(diff, 1, "bestworst.deadlines_more", "bestworst.deadlines_fewer", fmt.Sprintf("%.0f", math.Abs(diff)))
+	diff = best.Stress - worst.Stress
+	differ(diff, 1, "bestworst.stress_higher", "bestworst.stress_lower", fmt.Sprintf("%.0f", math.Abs(diff)))
+	if best.Exercised && !worst.Exercised {
+		report.Differences = append(report.Differences, loc.T("bestworst.exercised"))
+	}
+	if len(report.Differences) == 0 {
+		report.Differences = append(report.Differences, loc.T("bestworst.similar"))
+	}
+	return report
+}
+
+func lowerOrHigher(diff float64) string {
+	if diff > 0 {
+		return "higher"
+	}
+	return "lower"
+}
+
+// handleBestWorstDays returns the best and worst recent days and what differed
+func handleBestWorstDays(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultInsightDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := queryEntries(time.Now().AddDate(0, 0, -days), time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(buildBestWorstReport(requestLocalizer(r), entries, days)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
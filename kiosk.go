@@ -0,0 +1,306 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kioskResetAfter is how long the thank-you screen stays up before the
+// form comes back for the next person
+const kioskResetAfter = 20 * time.Second
+
+// kioskCookie marks a browser as a kiosk device
+const kioskCookie = "kiosk"
+
+// kioskCheckinsSchema keeps kiosk check-ins apart from entries: they are
+// other people's, so none of the owner's pages, stats or exports read them
+const kioskCheckinsSchema = `
+	CREATE TABLE IF NOT EXISTS kiosk_checkins (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		location TEXT NOT NULL,
+		kiosk_code TEXT DEFAULT '',
+		sleep REAL,
+		study_hours REAL,
+		deadlines INTEGER,
+		mood INTEGER,
+		stress INTEGER,
+		exercise BOOLEAN,
+		score REAL,
+		level TEXT
+	);
+`
+
+// kioskLocation is a place a shared device stands, e.g. the library
+type kioskLocation struct {
+	Code string
+	Name string
+}
+
+// kioskConfig holds the parsed KIOSK_* settings
+type kioskConfig struct {
+	Locations []kioskLocation
+	// Codes is "off" (anonymous), "optional" or "required"
+	Codes  string
+	Secret []byte
+	PIN    string
+}
+
+// kiosk is the running instance's kiosk setup; no locations means off
+var kiosk kioskConfig
+
+// loadKioskConfig reads the environment:
+//
+//	KIOSK_LOCATIONS  comma-separated code=Name pairs, e.g.
+//	                 library=Main Library,clinic=Health Centre
+//	KIOSK_CODES      off (anonymous, the default), optional or required:
+//	                 whether people type a personal code so their kiosk
+//	                 check-ins can be grouped
+//	KIOSK_SECRET     key the codes are hashed with; needed unless codes are off
+//	KIOSK_PIN        lets staff take a device out of kiosk mode
+func loadKioskConfig() (kioskConfig, error) {
+	cfg := kioskConfig{Codes: envOr("KIOSK_CODES", "off"), Secret: []byte(os.Getenv("KIOSK_SECRET")), PIN: os.Getenv("KIOSK_PIN")}
+	for _, pair := range strings.Split(os.Getenv("KIOSK_LOCATIONS"), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		code, name, _ := strings.Cut(pair, "=")
+		code, name = strings.ToLower(strings.TrimSpace(code)), strings.TrimSpace(name)
+		if code == "" || code == "exit" || strings.ContainsAny(code, "/ ") {
+			return cfg, fmt.Errorf("kiosk: invalid location %q in KIOSK_LOCATIONS", pair)
+		}
+		if name == "" {
+			name = code
+		}
+		cfg.Locations = append(cfg.Locations, kioskLocation{Code: code, Name: name})
+	}
+	switch cfg.Codes {
+	case "off":
+	case "optional", "required":
+		if len(cfg.Secret) < 16 {
+			return cfg, fmt.Errorf("kiosk: KIOSK_CODES=%s needs a KIOSK_SECRET of at least 16 characters", cfg.Codes)
+		}
+	default:
+		return cfg, fmt.Errorf("kiosk: KIOSK_CODES must be off, optional or required")
+	}
+	return cfg, nil
+}
+
+// location finds a configured location by code
+func (k kioskConfig) location(code string) (kioskLocation, bool) {
+	for _, l := range k.Locations {
+		if l.Code == strings.ToLower(code) {
+			return l, true
+		}
+	}
+	return kioskLocation{}, false
+}
+
+// participant turns a personal code into the pseudonym stored with the
+// entry. The code itself is never stored, and without the secret the
+// pseudonym cannot be matched back to it.
+func (k kioskConfig) participant(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.Secret)
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// kioskAllowed are the paths a kiosk device may open besides its form:
+// assets, inline validation and the support page
+var kioskAllowed = []string{"/kiosk/", "/static/", "/validate/", "/resources", "/icons/", "/brand/logo", "/manifest.webmanifest"}
+
+// kioskGuard keeps a browser that has opened a kiosk form on it, so a
+// shared device never shows the owner's history, reports or settings
+func kioskGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(kioskCookie)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		loc, ok := kiosk.location(c.Value)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range kioskAllowed {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Redirect(w, r, "/kiosk/"+loc.Code, http.StatusSeeOther)
+	})
+}
+
+// kioskView is the data behind templates/kiosk.html
+type kioskView struct {
+	Location   kioskLocation
+	Codes      string
+	Error      string
+	Done       bool
+	Score      float64
+	Level      string
+	ColorClass string
+	Advice     string
+	Severe     bool
+	ResetAfter int // seconds
+}
+
+// handleKiosk serves the check-in form for one location and saves what
+// is posted to it. The result screen shows only this check-in, then goes
+// back to an empty form.
+func handleKiosk(w http.ResponseWriter, r *http.Request) {
+	loc, ok := kiosk.location(r.PathValue("location"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	tmpl, localizer, err := localizedTemplate("kiosk.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	view := kioskView{Location: loc, Codes: kiosk.Codes, ResetAfter: int(kioskResetAfter / time.Second)}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.Method {
+	case "GET":
+		http.SetCookie(w, &http.Cookie{Name: kioskCookie, Value: loc.Code, Path: "/",
+			MaxAge: 365 * 24 * 60 * 60, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+		tmpl.Execute(w, view)
+
+	case "POST":
+		sleep, errSleep := strconv.ParseFloat(strings.TrimSpace(r.FormValue("sleep")), 64)
+		studyHours, errStudy := strconv.ParseFloat(strings.TrimSpace(r.FormValue("study")), 64)
+		deadlines, errDeadlines := strconv.Atoi(strings.TrimSpace(r.FormValue("deadlines")))
+		mood, _ := strconv.Atoi(r.FormValue("mood"))
+		stress, _ := strconv.Atoi(r.FormValue("stress"))
+		exercise := r.FormValue("exercise") == "on"
+		code := strings.TrimSpace(r.FormValue("code"))
+
+		switch {
+		case errSleep != nil || errStudy != nil || errDeadlines != nil ||
+			sleep < 0 || studyHours < 0 || sleep+studyHours > 24 || deadlines < 0 || deadlines > 100:
+			view.Error = localizer.T("kiosk.error_fields")
+		case mood < 1 || mood > 5 || stress < 1 || stress > 5:
+			view.Error = localizer.T("kiosk.error_fields")
+		case kiosk.Codes == "required" && code == "":
+			view.Error = localizer.T("kiosk.error_code")
+		case kiosk.Codes != "off" && code != "" && (len(code) < 4 || len(code) > 32):
+			view.Error = localizer.T("kiosk.error_code")
+		}
+		if view.Error != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			tmpl.Execute(w, view)
+			return
+		}
+		participant := ""
+		if kiosk.Codes != "off" {
+			participant = kiosk.participant(code)
+		}
+
+		// Recovery habits are the device owner's, so only exercise counts
+		recovery := 0.0
+		if exercise {
+			recovery = currentScoring().ExerciseRecovery
+		}
+		score := burnoutScore(sleep, studyHours, deadlines, stress, recovery)
+		advice := generateAIAdvice(sleep, deadlines, stress, score)
+		if _, err := db.Exec(`
+			INSERT INTO kiosk_checkins (location, kiosk_code, sleep, study_hours, deadlines, mood, stress, exercise, score, level)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			loc.Code, participant, sleep, studyHours, deadlines, mood, stress, exercise, score, scoreLevel(score)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		view.Done = true
+		view.Score = score
+		view.Level = localizer.T("level." + levelCode(score))
+		view.ColorClass = "text-" + map[string]string{"healthy": "green", "at-risk": "yellow", "high-risk": "orange", "severe": "red"}[levelCode(score)] + "-600"
+		view.Advice = advice
+		view.Severe = currentScoring().level(score) == levelSevere
+		tmpl.Execute(w, view)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// kioskCheckinCounts returns how many check-ins each location has taken,
+// by location code, for /admin
+func kioskCheckinCounts() (map[string]int, error) {
+	rows, err := db.Query(`SELECT location, COUNT(*) FROM kiosk_checkins GROUP BY location`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var code string
+		var n int
+		if err := rows.Scan(&code, &n); err != nil {
+			return nil, err
+		}
+		counts[code] = n
+	}
+	return counts, rows.Err()
+}
+
+// moveKioskCheckins moves kiosk check-ins that older versions saved in
+// entries, tagged with a location, over to kiosk_checkins
+func moveKioskCheckins() error {
+	var tagged int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('entries') WHERE name = 'location'`).Scan(&tagged)
+	if err != nil || tagged == 0 {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		INSERT INTO kiosk_checkins (created_at, location, kiosk_code, sleep, study_hours, deadlines, mood, stress, exercise, score, level)
+		SELECT created_at, location, COALESCE(kiosk_code, ''), sleep, study_hours, deadlines, mood, stress, exercise, score, level
+		FROM entries WHERE location != ''`); err != nil {
+		return fmt.Errorf("move kiosk check-ins: %w", err)
+	}
+	moved, err := tx.Exec(`DELETE FROM entries WHERE location != ''`)
+	if err != nil {
+		return err
+	}
+	if n, _ := moved.RowsAffected(); n > 0 {
+		slog.Info("moved kiosk check-ins out of entries", "rows", n)
+	}
+	return tx.Commit()
+}
+
+// handleKioskExit takes a device out of kiosk mode when staff enter the PIN
+func handleKioskExit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if kiosk.PIN == "" || !hmac.Equal([]byte(r.FormValue("pin")), []byte(kiosk.PIN)) {
+		http.Error(w, "Wrong PIN", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: kioskCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// systemdFirstFD is the first file descriptor systemd passes to a
+// socket-activated service (after stdin, stdout and stderr)
+const systemdFirstFD = 3
+
+// listen opens what the server accepts connections on, in order of
+// preference:
+//
+//  1. a socket passed by systemd (a .socket unit with this service),
+//  2. a Unix domain socket at UNIX_SOCKET, for a reverse proxy on the
+//     same host,
+//  3. TCP on PORT.
+//
+// It also returns a description of it for the startup log. For socket
+// activation, pair the service with a unit such as burnout.socket:
+//
+//	[Socket]
+//	ListenStream=/run/burnout/http.sock
+//	SocketGroup=www-data
+//	SocketMode=0660
+//
+//	[Install]
+//	WantedBy=sockets.target
+func listen(c serverConfig) (net.Listener, string, error) {
+	if ln, err := systemdListener(); ln != nil || err != nil {
+		return ln, "systemd socket", err
+	}
+	if c.Socket != "" {
+		ln, err := listenUnix(c.Socket, c.SocketMode)
+		return ln, "unix:" + c.Socket, err
+	}
+	ln, err := net.Listen("tcp", c.addr())
+	return ln, "tcp " + c.addr(), err
+}
+
+// systemdListener returns the first socket systemd passed in, or nil when
+// the process wasn't socket-activated. The LISTEN_* variables are cleared
+// so nothing started from here thinks it was activated too.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, errors.New("systemd: LISTEN_PID is set but LISTEN_FDS names no sockets")
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	f := os.NewFile(systemdFirstFD, "systemd-socket")
+	defer f.Close() // FileListener dups it
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd: %w", err)
+	}
+	return ln, nil
+}
+
+// listenUnix listens on a Unix domain socket, replacing a stale one left
+// by a crash, and sets its permissions so the proxy's group can connect.
+// The socket file is removed again when the listener closes.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("unix socket: %s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("unix socket: %s is in use by another server", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("unix socket: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("unix socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("unix socket: %w", err)
+	}
+	return ln, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// logLevels are the accepted LOG_LEVEL values
+var logLevels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// validateLogging checks LOG_LEVEL and LOG_FORMAT before anything is logged
+func (c serverConfig) validateLogging() error {
+	if _, ok := logLevels[c.LogLevel]; !ok {
+		return fmt.Errorf("config: log level must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("config: log format must be text or json, got %q", c.LogFormat)
+	}
+	return nil
+}
+
+// setupLogging makes slog's default logger write to stderr in the configured
+// format and level. The standard log package goes through it as well, so
+// anything still calling log.Printf ends up in the same stream.
+func setupLogging(c serverConfig) {
+	opts := &slog.HandlerOptions{Level: logLevels[c.LogLevel]}
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if c.LogFormat == "json" {
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	}
+	slog.SetDefault(slog.New(h))
+}
+
+// requestLog is the logger for something that happened while serving r,
+// tagged with its request ID and with the route so log lines can be
+// grouped like /metrics
+func requestLog(r *http.Request) *slog.Logger {
+	return slog.With("request_id", requestID(r), "method", r.Method, "route", routeOf(r))
+}
+
+// fatal logs err and exits, for startup failures
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
@@ -1,19 +1,22 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"html/template"
-	"log"
+	"log/slog"
 	"math"
-	"math/rand"
 	"net/http"
-	"path/filepath"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 // Data Structures
@@ -29,47 +32,304 @@ type BurnoutEntry struct {
 	Score      float64
 	Level      string
 	Advice     string
+	// ShareWithCohort marks entries the user opted in to the anonymous group averages
+	ShareWithCohort bool
+	// Journal is the optional free-text note written with the check-in
+	Journal string
 }
 
 type ChartData struct {
-	Labels []string  `json:"labels"`
-	Data   []float64 `json:"data"`
+	Labels      []string          `json:"labels"`
+	Data        []float64         `json:"data"`
+	Datasets    []ChartDataset    `json:"datasets,omitempty"`
+	Trend       *TrendLine        `json:"trend,omitempty"`
+	Ranges      []ChartRange      `json:"ranges,omitempty"`
+	Annotations []ChartAnnotation `json:"annotations,omitempty"`
+	// EntryIDs link raw points to their /entries/{id} page
+	EntryIDs []int `json:"entry_ids,omitempty"`
+}
+
+type ChartDataset struct {
+	Label string    `json:"label"`
+	Data  []float64 `json:"data"`
 }
 
 var db *sql.DB
 
 func main() {
+	// Offline restore helper: burnout-detector decrypt-backup <in.db.enc> <out.db>
+	if len(os.Args) == 4 && os.Args[1] == "decrypt-backup" {
+		if err := decryptBackupFile(os.Args[2], os.Args[3]); err != nil {
+			fatal("decrypt failed", err)
+		}
+		return
+	}
+
+	dev := flag.Bool("dev", false, "reload templates when files in the templates directory change")
+	flag.BoolVar(&demoMode, "demo", false, "serve sample data from a throwaway database that resets every hour")
+	registerServerFlags(&server)
+	flag.Parse()
+	if err := server.resolve(); err != nil {
+		fatal("invalid configuration", err)
+	}
+	setupLogging(server)
+
 	// Initialize Database
 	var err error
-	db, err = sql.Open("sqlite3", "./burnout.db")
+	dbPath := server.DBPath
+	if demoMode {
+		path, cleanup, err := openDemoDatabase()
+		if err != nil {
+			fatal("demo database", err)
+		}
+		defer cleanup()
+		dbPath = path
+	}
+	db, err = sql.Open(timedDriverName, dbPath)
 	if err != nil {
-		log.Fatal(err)
+		fatal("database open failed", err)
 	}
 	defer db.Close()
+	if dbPool, err = loadDBPoolConfig(); err != nil {
+		fatal("database configuration", err)
+	}
+	dbPool.apply(db)
+	if state, err = loadSharedState(dbPath); err != nil {
+		fatal("shared state", err)
+	}
 
 	// Run Migration
 	if err := runMigrations(); err != nil {
-		log.Fatal(err)
+		fatal("migrations failed", err)
+	}
+	migrated.Store(true)
+	if err := prepareStatements(); err != nil {
+		fatal("preparing statements failed", err)
+	}
+	defer closeStatements()
+	if demoMode {
+		if err := seedDemoData(time.Now()); err != nil {
+			fatal("demo data", err)
+		}
 	}
 
-	// Routes
-	http.HandleFunc("/", handleIndex)
-	http.HandleFunc("/calculate", handleCalculate)
-	http.HandleFunc("/history-chart", handleChartData)
+	if brand, err = loadBranding(); err != nil {
+		fatal("branding", err)
+	}
+	if crisis, err = loadCrisisDirectory(); err != nil {
+		fatal("crisis resources", err)
+	}
+	if kiosk, err = loadKioskConfig(); err != nil {
+		fatal("kiosk configuration", err)
+	}
+	if adminToken, err = loadAdminConfig(); err != nil {
+		fatal("admin configuration", err)
+	}
+	if features.configured, err = loadFeatureFlags(); err != nil {
+		fatal("feature flags", err)
+	}
+
+	// Templates are parsed once up front; -dev re-parses them on change
+	templates.dir = server.TemplatesDir
+	if err := templates.load(); err != nil {
+		fatal("templates", err)
+	}
+	if *dev {
+		go templates.watch()
+	}
+
+	// Tracing: spans for requests, queries, jobs and outgoing calls go to
+	// an OpenTelemetry collector when one is configured (see tracing.go)
+	if err := startTracing(context.Background()); err != nil {
+		fatal("tracing configuration", err)
+	}
+
+	// Error reporting: panics and 5xx responses go to a Sentry-compatible
+	// service when SENTRY_DSN is set (see errorreport.go)
+	if cfg, ok, err := loadErrorReportConfig(); err != nil {
+		fatal("error reporting configuration", err)
+	} else if ok {
+		errorReports.Start(cfg)
+	}
+
+	// Background Jobs
+	scheduler.Register("risk-index", daily(0, 5), runNightlyRiskIndex)
+	scheduler.Register("goal-progress", daily(0, 10), runNightlyGoalEvaluation)
+	scheduler.Register("challenges", daily(0, 15), settleEnrollments)
+	scheduler.Register("insights", every(6*time.Hour), generateInsights)
+	if demoMode {
+		// Sample data only: no backups or exports, and a fresh start every hour
+		scheduler.Register("demo-reset", every(demoResetInterval), resetDemo)
+	} else {
+		if cfg, ok, err := loadBackupConfig(); err != nil {
+			fatal("backup configuration", err)
+		} else if ok {
+			scheduler.Register("backup", every(cfg.Interval), func() error { return runBackup(cfg) })
+		}
+		if cfg, ok, err := loadExportConfig(); err != nil {
+			fatal("export configuration", err)
+		} else if ok {
+			scheduler.Register("export", every(cfg.Interval), func() error { return runScheduledExport(cfg) })
+		}
+	}
+	scheduler.Register("queue-cleanup", daily(0, 20), pruneJobQueue)
+	scheduler.Start()
 
-	fmt.Println("Server starting at http://localhost:8081")
-	log.Fatal(http.ListenAndServe(":8081", nil))
+	// Queued jobs: slow work handed off by requests (see queue.go)
+	queue.Register("import", 3, runImportJob)
+	queue.Register("webhook", webhookAttempts, runWebhookJob)
+	queueCfg, err := loadQueueConfig()
+	if err != nil {
+		fatal("queue configuration", err)
+	}
+	queue.Start(queueCfg)
+
+	// Routes. Patterns that name a method answer 405 to any other; the
+	// rest check the method themselves.
+	root := newRouter()
+	// Pages and API calls get REQUEST_TIMEOUT to answer before their
+	// context is cancelled (see timeouts.go) and take bodies up to
+	// maxRequestBody
+	mux := root.With(timeout(server.RequestTimeout), limitBody(maxRequestBody))
+	// Read-only data endpoints get ETags and a response cache (see etag.go)
+	data := mux.With(conditional)
+	// Downloads stream and uploads may be slow, so they are only bounded
+	// by the connection's timeouts
+	transfers := root.With(deadline(server.WriteTimeout))
+	mux.HandleFunc("GET /{$}", handleIndex)
+	mux.HandleFunc("/static/", handleStatic)
+	mux.HandleFunc("/manifest.webmanifest", handleManifest)
+	mux.HandleFunc("/sw.js", handleServiceWorker)
+	mux.HandleFunc("/icons/{file}", handleIcon)
+	mux.HandleFunc("/brand/logo", handleBrandLogo)
+	mux.HandleFunc("/calculate", handleCalculate)
+	mux.HandleFunc("/quick", handleQuick)
+	mux.HandleFunc("/kiosk/{location}", handleKiosk)
+	mux.HandleFunc("/kiosk/exit", handleKioskExit)
+	mux.HandleFunc("/validate/{field}", handleFieldCheck)
+	data.HandleFunc("GET /history-chart", handleChartData)
+	data.HandleFunc("GET /api/stats", handleStats)
+	data.HandleFunc("GET /api/summary/weekly", handleWeeklySummary)
+	data.HandleFunc("GET /api/compare", handleCompare)
+	mux.HandleFunc("/api/reports/monthly", handleMonthlyReport)
+	data.HandleFunc("GET /api/charts/factors", handleFactorChart)
+	data.HandleFunc("GET /api/charts/mood", handleMoodChart)
+	data.HandleFunc("GET /api/charts/heatmap", handleHeatmap)
+	data.HandleFunc("GET /api/charts/risk", handleRiskChart)
+	data.HandleFunc("GET /api/charts/distribution", handleDistribution)
+	data.HandleFunc("GET /api/charts/focus", handleFocusChart)
+	data.HandleFunc("GET /api/chart.png", handleChartImage)
+	data.HandleFunc("GET /api/chart.svg", handleChartImage)
+	mux.HandleFunc("/api/gauge.png", handleGauge)
+	mux.HandleFunc("/api/gauge.svg", handleGauge)
+	transfers.HandleFunc("/api/export.csv", handleExportCSV)
+	transfers.HandleFunc("/api/export.xlsx", handleExportXLSX)
+	transfers.HandleFunc("/api/export.md", handleExportMarkdown)
+	transfers.HandleFunc("/api/export.ndjson", handleExportNDJSON)
+	transfers.HandleFunc("/api/export.parquet", handleExportParquet)
+	transfers.HandleFunc("/api/export/fhir", handleExportFHIR)
+	transfers.HandleFunc("/api/report.pdf", handlePDFReport)
+	transfers.HandleFunc("/api/import/{format}", handleImport)
+	mux.HandleFunc("GET /api/queue/{id}", handleQueuedJob)
+	data.HandleFunc("GET /api/insights/correlations", handleCorrelations)
+	data.HandleFunc("GET /api/insights/weekday", handleWeekdayPatterns)
+	data.HandleFunc("GET /api/insights/cohort", handleCohortComparison)
+	data.HandleFunc("GET /api/insights/best-worst", handleBestWorstDays)
+	data.HandleFunc("GET /api/insights/periods", handlePeriodInsights)
+	data.HandleFunc("GET /api/insights/recovery", handleRecovery)
+	mux.HandleFunc("/api/insights/feed", handleInsightFeed)
+	mux.HandleFunc("/api/periods", handlePeriods)
+	mux.HandleFunc("/api/periods/{id}", handlePeriod)
+	mux.HandleFunc("/api/annotations", handleAnnotations)
+	mux.HandleFunc("/api/annotations/{id}", handleAnnotation)
+	mux.HandleFunc("/api/goals", handleGoals)
+	mux.HandleFunc("/api/goals/{id}", handleGoal)
+	mux.HandleFunc("/api/challenges", handleChallenges)
+	mux.HandleFunc("/api/challenges/{key}/enroll", handleChallengeEnroll)
+	mux.HandleFunc("/api/habits", handleHabits)
+	mux.HandleFunc("/api/habits/{id}", handleHabit)
+	mux.HandleFunc("/api/habits/{id}/log", handleHabitLog)
+	mux.HandleFunc("/api/pomodoro", handlePomodoroSessions)
+	mux.HandleFunc("/api/pomodoro/start", handlePomodoroStart)
+	mux.HandleFunc("/api/pomodoro/stop", handlePomodoroStop)
+	mux.HandleFunc("/api/study-sessions", handleStudySessions)
+	mux.HandleFunc("/api/study-sessions/subjects", handleStudySubjects)
+	mux.HandleFunc("/api/study-sessions/{id}", handleStudySession)
+	mux.HandleFunc("/api/deadlines", handleDeadlines)
+	mux.HandleFunc("/api/deadlines/reschedule-one", handleRescheduleOne)
+	mux.HandleFunc("/api/deadlines/{id}", handleDeadline)
+	mux.HandleFunc("/api/sleep", handleSleepLog)
+	data.HandleFunc("GET /api/sleep/summary", handleSleepSummary)
+	mux.HandleFunc("/api/sleep/{id}", handleSleepSegment)
+	mux.HandleFunc("/timeline", handleTimelinePage)
+	mux.HandleFunc("/history", handleHistoryPage)
+	mux.HandleFunc("/compare", handleCompare)
+	mux.HandleFunc("GET /api/entries", handleEntries)
+	mux.HandleFunc("DELETE /api/entries", handleEntries)
+	mux.HandleFunc("POST /api/sync", handleSync)
+	mux.HandleFunc("/api/checkin/defaults", handleCheckinDefaults)
+	mux.HandleFunc("GET /api/entries/{id}", handleEntry)
+	mux.HandleFunc("PUT /api/entries/{id}", handleEntry)
+	mux.HandleFunc("DELETE /api/entries/{id}", handleEntry)
+	mux.HandleFunc("POST /api/undo/{token}", handleUndo)
+	mux.HandleFunc("GET /entries/{id}", handleEntryDetail)
+	mux.HandleFunc("POST /entries/{id}/what-if", handleEntryWhatIf)
+	mux.HandleFunc("GET /entries/{id}/edit", handleEntryEditForm)
+	mux.HandleFunc("GET /entries/{id}/print", handleEntryPrint)
+	mux.HandleFunc("/report/weekly", handleWeeklyReport)
+	mux.HandleFunc("/shared/{token}", handleSharedReport)
+	mux.HandleFunc("/shared/{token}/qr.png", handleSharedQR)
+	mux.HandleFunc("/api/share-links", handleShareLinks)
+	mux.HandleFunc("/api/share-links/{id}", handleShareLink)
+	mux.HandleFunc("/api/timeline", handleTimeline)
+	mux.HandleFunc("/settings", handleSettingsPage)
+	mux.HandleFunc("/resources", handleResources)
+	mux.HandleFunc("/dashboard", handleDashboard)
+	mux.HandleFunc("/onboarding/{step}", handleOnboarding)
+	mux.HandleFunc("GET /api/onboarding", handleOnboardingState)
+	mux.HandleFunc("/api/settings", handleSettings)
+	mux.HandleFunc("/assessments", handleAssessments)
+	mux.HandleFunc("/assessments/{name}", handleAssessmentStart)
+	mux.HandleFunc("/assessments/runs/{id}", handleAssessmentRun)
+	mux.HandleFunc("/api/assessments", handleAssessmentAPI)
+	mux.HandleFunc("GET /admin", handleAdmin)
+	mux.HandleFunc("POST /admin", handleAdmin)
+	mux.HandleFunc("POST /admin/logout", handleAdminLogout)
+	admin := mux.With(adminOnly)
+	admin.HandleFunc("POST /admin/jobs/{name}/run", handleAdminRunJob)
+	admin.HandleFunc("POST /admin/share-links/{id}/revoke", handleAdminRevokeShareLink)
+	admin.HandleFunc("POST /admin/queue/{id}/retry", handleAdminRetryJob)
+	admin.HandleFunc("POST /admin/flags/{name}", handleAdminSetFlag)
+	admin.HandleFunc("POST /admin/scoring", handleAdminSetScoring)
+	admin.HandleFunc("POST /admin/advice", handleAdminSetAdvice)
+	admin.HandleFunc("POST /admin/webhooks", handleAdminAddWebhook)
+	admin.HandleFunc("POST /admin/webhooks/{id}/delete", handleAdminDeleteWebhook)
+	admin.HandleFunc("POST /admin/maintenance", handleAdminMaintenance)
+	admin.HandleFunc("GET /api/admin/health", handleAdminHealth)
+	// Profiles run for as long as they are asked to, up to the write timeout
+	registerPprof(root.With(adminOnly))
+	mux.HandleFunc("GET /healthz", handleHealthz)
+	mux.HandleFunc("GET /readyz", handleReadyz)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+
+	origin := server.BaseURL
+	if origin == "" && server.Socket == "" {
+		scheme := "http"
+		if server.tlsMode() != "" {
+			scheme = "https"
+		}
+		origin = scheme + "://localhost:" + server.Port
+	}
+	slog.Info("server starting", "url", origin, "log_level", server.LogLevel)
+	srv := &http.Server{Addr: server.addr(), Handler: chain(root, recordRoute, compress, accessLog, securityHeaders, traceRequests, instrument, reportErrors, recoverPanics, kioskGuard, maintenanceGuard, assignSubject)}
+	server.applyTimeouts(srv)
+	if err := serve(srv, server); err != nil {
+		fatal("server stopped", err)
+	}
 }
 
 // runMigrations handles plain SQL migrations
 func runMigrations() error {
-	// For this prototype, we'll drop the old table to support schema changes easily.
-	// In production, use ALTER TABLE.
-	dropQuery := `DROP TABLE IF EXISTS entries`
-	if _, err := db.Exec(dropQuery); err != nil {
-		return err
-	}
-
 	query := `
 	CREATE TABLE IF NOT EXISTS entries (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -82,21 +342,121 @@ func runMigrations() error {
 		exercise BOOLEAN,
 		score REAL,
 		level TEXT,
-		advice TEXT
+		advice TEXT,
+		share_with_cohort BOOLEAN DEFAULT 0,
+		journal TEXT DEFAULT '',
+		client_id TEXT UNIQUE
 	);
 	`
-	_, err := db.Exec(query)
-	return err
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+	if err := addEntryColumns(); err != nil {
+		return err
+	}
+
+	for _, schema := range tableMigrations {
+		if _, err := db.Exec(schema); err != nil {
+			return err
+		}
+	}
+	return moveKioskCheckins()
+}
+
+// addedEntryColumns are the columns entries gained after its first version. A
+// database made before one of them, such as a restored backup, gets it
+// added in place; existing rows are never touched.
+var addedEntryColumns = []struct{ name, definition string }{
+	{"share_with_cohort", "BOOLEAN DEFAULT 0"},
+	{"journal", "TEXT DEFAULT ''"},
+	{"client_id", "TEXT"},
+}
+
+// addEntryColumns adds whichever of addedEntryColumns entries lacks
+func addEntryColumns() error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('entries')`)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		have[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range addedEntryColumns {
+		if have[c.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE entries ADD COLUMN ` + c.name + ` ` + c.definition); err != nil {
+			return fmt.Errorf("add entries.%s: %w", c.name, err)
+		}
+		if c.name == "client_id" {
+			// ADD COLUMN can't carry UNIQUE; sync relies on it
+			if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS entries_client_id ON entries(client_id)`); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tableMigrations create the feature tables
+var tableMigrations = []string{
+	monthlyReportsSchema,
+	periodsSchema,
+	riskIndexSchema,
+	annotationsSchema,
+	goalsSchema,
+	challengesSchema,
+	habitsSchema,
+	pomodoroSchema,
+	studySessionsSchema,
+	deadlinesSchema,
+	sleepLogSchema,
+	insightFeedSchema,
+	shareLinksSchema,
+	exportCursorsSchema,
+	settingsSchema,
+	assessmentRunsSchema,
+	sharedStateSchema,
+	jobQueueSchema,
+	featureFlagsSchema,
+	adminSessionsSchema,
+	kioskCheckinsSchema,
+	webhooksSchema,
 }
 
 // handleIndex renders the main page
 func handleIndex(w http.ResponseWriter, r *http.Request) {
-	tmpl, err := template.ParseFiles(filepath.Join("templates", "index.html"))
+	// First visit: walk through the onboarding guide before the first check-in
+	if o, err := loadOnboarding(); err == nil && !o.Completed {
+		http.Redirect(w, r, "/onboarding/"+o.Step, http.StatusSeeOther)
+		return
+	}
+	dashboard, err := buildDashboard(time.Now().In(loadZone()))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	tmpl.Execute(w, nil)
+	tmpl, _, err := localizedTemplate("index.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	prefill, err := loadCheckinDefaults(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, map[string]any{"Dashboard": dashboard, "Prefill": prefill})
 }
 
 // handleCalculate processes the form submission
@@ -108,255 +468,268 @@ func handleCalculate(w http.ResponseWriter, r *http.Request) {
 
 	// Parse Form
 	sleep, _ := strconv.ParseFloat(r.FormValue("sleep"), 64)
+	if strings.TrimSpace(r.FormValue("sleep")) == "" {
+		// Left blank: use the quality-adjusted sleep log, naps included
+		if effective, ok, err := effectiveSleep(time.Now()); err == nil && ok {
+			sleep = effective
+		}
+	}
 	studyHours, _ := strconv.ParseFloat(r.FormValue("study"), 64)
+	if strings.TrimSpace(r.FormValue("study")) == "" {
+		// Left blank: use the focus time logged today instead of a guess
+		if logged, err := loggedStudyHours(time.Now()); err == nil {
+			studyHours = logged
+		}
+	}
 	deadlines, _ := strconv.Atoi(r.FormValue("deadlines"))
+	if strings.TrimSpace(r.FormValue("deadlines")) == "" {
+		// Left blank: count the open deadlines due this week from the tracker
+		if load, err := upcomingDeadlineLoad(time.Now()); err == nil {
+			deadlines = load
+		}
+	}
 	mood, _ := strconv.Atoi(r.FormValue("mood"))     // 1-5
 	stress, _ := strconv.Atoi(r.FormValue("stress")) // 1-5
 	exercise := r.FormValue("exercise") == "on"
-
-	// Calculate Burnout Score
-	// Formula: (deadline * 10) + (stress * 12) + ((8 - sleepHours) * 8) + (studyHours * 3) - (exercise ? 10 : 0)
-
-	sleepPenalty := (8.0 - sleep) * 8.0
-	// If sleep > 8, penalty becomes negative (bonus), which is fine but let's cap it slightly if needed.
-	// The user formula implies less sleep = higher score.
-
-	exerciseBonus := 0.0
-	if exercise {
-		exerciseBonus = 10.0
+	shareWithCohort := r.FormValue("share_with_cohort") == "on"
+	journal := strings.TrimSpace(r.FormValue("journal"))
+	if len([]rune(journal)) > maxJournalLength {
+		journal = string([]rune(journal)[:maxJournalLength])
 	}
 
-	rawScore := (float64(deadlines) * 10.0) +
-		(float64(stress) * 12.0) +
-		sleepPenalty +
-		(studyHours * 3.0) -
-		exerciseBonus
+	// Scoring, storage and rendering are spans of the request's trace, so
+	// a slow check-in shows which of them took the time (see tracing.go)
+	_, scoreSpan := startChildSpan(r.Context(), "score check-in")
+	score := burnoutScore(sleep, studyHours, deadlines, stress, recoveryCredit(exercise, time.Now()))
+	level := scoreLevel(score)
 
-	// Clamp 0-100
-	score := math.Max(0, math.Min(100, rawScore))
-
-	// Determine Category
-	var level, colorClass, barColor string
-	if score <= 30 {
-		level = "🟢 Healthy"
+	// Determine Category colours
+	var colorClass, barColor string
+	switch currentScoring().level(score) {
+	case levelHealthy:
 		colorClass = "text-green-600"
 		barColor = "bg-green-500"
-	} else if score <= 60 {
-		level = "🟡 At Risk"
+	case levelAtRisk:
 		colorClass = "text-yellow-600"
 		barColor = "bg-yellow-500"
-	} else if score <= 80 {
-		level = "🟠 High Risk"
+	case levelHighRisk:
 		colorClass = "text-orange-600"
 		barColor = "bg-orange-500"
-	} else {
-		level = "🔴 Severe Burnout"
+	default:
 		colorClass = "text-red-600"
 		barColor = "bg-red-600"
 	}
 
 	// Generate "AI Feel" Advice
 	advice := generateAIAdvice(sleep, deadlines, stress, score)
+	scoreSpan.SetAttributes(attribute.String("burnout.level", levelCode(score)))
+	scoreSpan.End()
 
 	// Save to DB
-	_, err := db.Exec(`
-		INSERT INTO entries (sleep, study_hours, deadlines, mood, stress, exercise, score, level, advice) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		sleep, studyHours, deadlines, mood, stress, exercise, score, level, advice)
+	res, err := stmts.insertEntry.ExecContext(r.Context(),
+		sleep, studyHours, deadlines, mood, stress, exercise, score, level, advice, shareWithCohort, journal)
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	entryID, _ := res.LastInsertId()
 
-	// Render Result Fragment
-	w.Header().Set("Content-Type", "text/html")
-	w.Header().Set("HX-Trigger", "newEntry")
+	// Daily challenge tracking and webhooks
+	if entry, err := getEntry(entryID); err == nil {
+		if err := trackChallenges(entry); err != nil {
+			requestLog(r).Error("challenge tracking failed", "entry_id", entryID, "err", err)
+		}
+		if err := notifyWebhooks(entry); err != nil {
+			requestLog(r).Error("queueing webhooks failed", "entry_id", entryID, "err", err)
+		}
+	}
 
-	// Visuals
-	rotation := (score/100.0)*180.0 - 180.0
+	loc := requestLocalizer(r)
+	view := resultView{
+		Score:      score,
+		Level:      loc.T("level." + levelCode(score)),
+		ColorClass: colorClass,
+		BarColor:   barColor,
+		Gauge:      scoreGauge{Score: score, Label: loc.T("result.score")}.SVG(),
+		Advice:     advice,
+		Sleep:      sleep,
+		Deadlines:  deadlines,
+		Stress:     stress,
+		Exercise:   exercise,
+		ResetPlan:  currentScoring().level(score) == levelSevere,
+		EntryID:    entryID,
+	}
 
-	// Format exercise string
-	exerciseStr := "No"
-	if exercise {
-		exerciseStr = "Yes"
-	}
-
-	// Prepare Reset Plan Button if score > 80
-	var resetPlanHTML string
-	if score > 80 {
-		resetPlanHTML = `
-			<div class="mt-6">
-				<button onclick="document.getElementById('reset-plan').classList.remove('hidden')" 
-						class="w-full bg-red-600 hover:bg-red-700 text-white font-bold py-3 px-4 rounded-lg shadow-lg animate-pulse transition">
-					🔥 Activate 24-Hour Reset Plan
-				</button>
-				<div id="reset-plan" class="hidden mt-4 bg-red-50 border border-red-200 rounded-lg p-4 text-left">
-					<h4 class="font-bold text-red-800 mb-2">🚨 Emergency Protocol</h4>
-					<ul class="space-y-2 text-sm text-red-700">
-						<li class="flex items-center"><span class="mr-2">❌</span> No academic work tonight</li>
-						<li class="flex items-center"><span class="mr-2">💤</span> Sleep minimum 7 hours</li>
-						<li class="flex items-center"><span class="mr-2">📵</span> 1 hour no social media</li>
-						<li class="flex items-center"><span class="mr-2">🚶</span> 20 minute walk outside</li>
-						<li class="flex items-center"><span class="mr-2">📅</span> Reschedule 1 deadline immediately</li>
-					</ul>
-				</div>
-			</div>
-		`
-	}
-
-	// Current date for PDF
-	currentDate := time.Now().Format("Jan 02, 2006")
-
-	html := fmt.Sprintf(`
-		<div class="animate-fade-in-up mt-8">
-			<!-- Score Card -->
-			<div class="bg-white p-6 rounded-2xl shadow-xl text-center border border-gray-100 relative overflow-hidden transition-all duration-500 hover:shadow-2xl">
-				<div class="absolute top-0 left-0 w-full h-2 %s"></div>
-				
-				<h2 class="text-3xl font-bold mb-6 text-gray-800">Burnout Analysis</h2>
-				
-				<!-- Gauge Meter -->
-				<div class="relative w-64 h-32 mx-auto overflow-hidden mb-6 group">
-					<div class="absolute top-0 left-0 w-full h-full bg-gray-100 rounded-t-full"></div>
-					<div class="absolute top-0 left-0 w-full h-full %s origin-bottom transition-all duration-1000 ease-out shadow-[0_0_20px_rgba(0,0,0,0.1)]"
-						 style="transform: rotate(%.2fdeg); background: currentColor; border-radius: 9999px 9999px 0 0;">
-					</div>
-					<div class="absolute bottom-0 left-1/2 w-48 h-24 -ml-24 bg-white rounded-t-full flex items-end justify-center pb-2 shadow-[0_-10px_20px_rgba(255,255,255,1)] z-10">
-						<div class="text-center group-hover:scale-110 transition-transform">
-							<span class="text-5xl font-extrabold %s block">%.0f</span>
-							<span class="text-xs text-gray-400 uppercase tracking-widest font-semibold">Score</span>
-						</div>
-					</div>
-				</div>
-
-				<div class="mb-8">
-					<span class="inline-block px-6 py-2 rounded-full text-sm font-bold bg-opacity-10 %s bg-gray-200 border border-current shadow-sm">
-						%s
-					</span>
-				</div>
-
-				<!-- AI Insight Section -->
-				<div class="bg-indigo-50 rounded-xl p-6 text-left border border-indigo-100 shadow-inner">
-					<div class="flex items-center mb-3">
-						<div class="bg-indigo-100 p-1.5 rounded-lg mr-3">
-							<svg class="w-5 h-5 text-indigo-600" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M13 10V3L4 14h7v7l9-11h-7z"></path></svg>
-						</div>
-						<h3 class="font-bold text-indigo-900">AI Personal Insight</h3>
-					</div>
-					<p class="text-indigo-800 text-sm leading-relaxed font-medium italic">
-						"%s"
-					</p>
-				</div>
-
-				<!-- Action Items Grid -->
-				<div class="mt-6 grid grid-cols-2 md:grid-cols-4 gap-3 text-xs text-gray-500 font-medium">
-					<div class="bg-gray-50 p-3 rounded-lg border border-gray-100">💤 Sleep: <span class="text-gray-800">%.1fh</span></div>
-					<div class="bg-gray-50 p-3 rounded-lg border border-gray-100">📚 Deadlines: <span class="text-gray-800">%d</span></div>
-					<div class="bg-gray-50 p-3 rounded-lg border border-gray-100">😓 Stress: <span class="text-gray-800">%d/5</span></div>
-					<div class="bg-gray-50 p-3 rounded-lg border border-gray-100">🏃 Exercise: <span class="text-gray-800">%s</span></div>
-				</div>
-
-				%s
-
-				<!-- Download Report Button -->
-				<div class="mt-4 pt-4 border-t border-gray-100">
-					<button onclick="generatePDF(%.2f, '%s', '%s', '%s')" class="text-indigo-600 hover:text-indigo-800 text-sm font-semibold flex items-center justify-center w-full">
-						<svg class="w-4 h-4 mr-2" fill="none" stroke="currentColor" viewBox="0 0 24 24"><path stroke-linecap="round" stroke-linejoin="round" stroke-width="2" d="M4 16v1a3 3 0 003 3h10a3 3 0 003-3v-1m-4-4l-4 4m0 0l-4-4m4 4V4"></path></svg>
-						Download Full Report (PDF)
-					</button>
-				</div>
-			</div>
-			
-			<!-- Auto Save Script -->
-			<script>
-				// Save this result to localStorage automatically
-				if (typeof saveToHistory === 'function') {
-					saveToHistory(%.2f);
+	// Streaks
+	if streaks, err := loadStreaks(); err == nil {
+		view.Streaks = &streaks
+	}
+
+	// Percentile vs personal history
+	if pct, n, err := scorePercentile(score, entryID); err == nil {
+		view.Percentile = percentileSentence(loc, pct, n)
+	}
+
+	// Anonymous group comparison (opted-in check-ins only)
+	if shareWithCohort {
+		if entry, err := getEntry(entryID); err == nil {
+			if c, err := buildCohortComparison(entry); err == nil {
+				view.Cohort = c.Differences
+				if !c.Available {
+					view.Cohort = []string{c.Reason}
 				}
-			</script>
-		</div>
-	`, barColor, colorClass, rotation, colorClass, score, colorClass, level, advice, sleep, deadlines, stress, exerciseStr, resetPlanHTML, score, level, advice, currentDate, score)
+			}
+		}
+	}
 
-	w.Write([]byte(html))
+	// Render the result card for HTMX, or a whole page for a plain form
+	// post (JavaScript disabled or a minimal screen reader setup)
+	_, renderSpan := startChildSpan(r.Context(), "render result")
+	defer renderSpan.End()
+	tmpl, _, err := localizedTemplate("result.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Trigger", "newEntry")
+		fragment := "card"
+		if r.FormValue("view") == "quick" {
+			fragment = "quick"
+		}
+		err = tmpl.ExecuteTemplate(w, fragment, view)
+	} else {
+		err = tmpl.Execute(w, view)
+	}
+	if err != nil {
+		renderSpan.SetStatus(codes.Error, err.Error())
+		requestLog(r).Error("template failed", "template", "result.html", "entry_id", entryID, "err", err)
+	}
 }
 
-// generateAIAdvice simulates an AI response based on inputs
-func generateAIAdvice(sleep float64, deadlines, stress int, score float64) string {
-	// Simple rule-based generation to "simulate" AI
+// resultView is the data behind templates/result.html. Every value is
+// escaped by html/template, including the free-text advice and cohort lines.
+type resultView struct {
+	Score      float64
+	Level      string
+	ColorClass string
+	BarColor   string
+	Gauge      template.HTML
+	Advice     string
+	Sleep      float64
+	Deadlines  int
+	Stress     int
+	Exercise   bool
+	Percentile string
+	Streaks    *Streaks
+	Cohort     []string
+	ResetPlan  bool
+	EntryID    int64
+}
 
-	intro := []string{
-		"Based on your current workload patterns,",
-		"Analyzing your physiological and academic inputs,",
-		"Correlating your sleep data with stress levels,",
-		"My assessment of your current state suggests",
+// recoveryCredit totals the recovery side of the formula for a day:
+// exercise plus the bonus for completing any recovery habit
+func recoveryCredit(exercise bool, day time.Time) float64 {
+	s := currentScoring()
+	recovery := 0.0
+	if exercise {
+		recovery = s.ExerciseRecovery
+	}
+	if n, err := recoveryHabitsDone(day); err == nil && n > 0 {
+		recovery += s.HabitRecoveryBonus
 	}
+	return recovery
+}
 
-	rand.Seed(time.Now().UnixNano())
-	selectedIntro := intro[rand.Intn(len(intro))]
+// burnoutScore applies the scoring formula and clamps it to 0-100. With the
+// default weights:
+// (deadline * 10) + (stress * 12) + ((8 - sleepHours) * 8) + (studyHours * 3) - recovery
+// where recovery is 10 for exercise plus any recovery-habit bonus.
+func burnoutScore(sleep, studyHours float64, deadlines, stress int, recovery float64) float64 {
+	// If sleep is over the target, penalty becomes negative (bonus), which
+	// is fine. Less sleep = higher score.
+	s := currentScoring()
+	sleepPenalty := (s.SleepTarget - sleep) * s.SleepWeight
+
+	rawScore := (float64(deadlines) * s.DeadlineWeight) +
+		(float64(stress) * s.StressWeight) +
+		sleepPenalty +
+		(studyHours * s.StudyWeight) -
+		recovery
 
-	var body string
-	if score > 80 {
-		body = "your system is in critical overdrive. The combination of high stress and sleep deprivation is unsustainable. Your cognitive performance is likely degrading."
-	} else if score > 60 {
-		body = fmt.Sprintf("you are navigating a high-pressure zone. Managing %d deadlines with elevated stress is depleting your reserves faster than you can recover.", deadlines)
-	} else if score > 30 {
-		body = "you are maintaining functionality but showing early signs of friction. Your sleep schedule needs slight optimization to buffer against upcoming deadlines."
-	} else {
-		body = "you have achieved an optimal balance between academic rigor and personal recovery. Your resilience metrics are currently peak."
-	}
+	return math.Max(0, math.Min(100, rawScore))
+}
 
-	var action string
-	if sleep < 5 {
-		action = "Immediate Priority: Disconnect 1 hour before bed to reclaim REM cycles."
-	} else if stress > 3 {
-		action = "Suggestion: Implement the Pomodoro technique (25/5) to fragment stress accumulation."
-	} else if deadlines > 4 {
-		action = "Strategy: Triage your deadlines; ask for extensions on low-priority tasks."
-	} else {
-		action = "Recommendation: Maintain current routine but monitor hydration levels."
-	}
-
-	// Escape single quotes for JS safety
-	// In a real app, use JSON encoding or proper escaping.
-	// For this prototype, we just replace ' with \'
-	// But since we use fmt.Sprintf, we should be careful.
-	// Let's keep it simple and hope no complex characters break the inline JS call.
-	// Or better: sanitize it inside the main function or here.
-	fullAdvice := fmt.Sprintf("%s %s %s", selectedIntro, body, action)
-	// Simple sanitization for JS string context
-	// (Note: In production, pass data via JSON data attributes, not raw string injection)
-	return fullAdvice
+// scoreLevel names the category a score falls into
+func scoreLevel(score float64) string {
+	return [...]string{"🟢 Healthy", "🟡 At Risk", "🟠 High Risk", "🔴 Severe Burnout"}[currentScoring().level(score)]
+}
+
+// generateAIAdvice simulates an AI response based on inputs, from the
+// advice templates in force (see advice.go)
+func generateAIAdvice(sleep float64, deadlines, stress int, score float64) string {
+	return currentAdvisor().advise(sleep, deadlines, stress, score)
 }
 
 // handleChartData returns JSON for Chart.js
 func handleChartData(w http.ResponseWriter, r *http.Request) {
-	rows, err := db.Query(`
-		SELECT created_at, score FROM (
-			SELECT created_at, score FROM entries ORDER BY created_at DESC LIMIT 10
-		) ORDER BY created_at ASC
-	`)
+	granularity, err := parseGranularity(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days, err := parseRangeDays(r, defaultAggregateDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer rows.Close()
 
-	var labels []string
-	var data []float64
+	points, history, err := loadChartPoints(granularity, days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	for rows.Next() {
-		var t time.Time
-		var s float64
-		if err := rows.Scan(&t, &s); err != nil {
-			continue
+	loc := requestLocalizer(r)
+	labels := []string{}
+	data := []float64{}
+	movingAvg := []float64{}
+	for _, p := range points {
+		labels = append(labels, chartLabel(loc, p.At, granularity))
+		data = append(data, roundTo(p.Score, 1))
+		movingAvg = append(movingAvg, roundTo(movingAverageAt(history, p.At, movingAverageWindow), 1))
+	}
+	var entryIDs []int
+	if granularity == granularityRaw {
+		for _, p := range points {
+			entryIDs = append(entryIDs, p.ID)
 		}
-		labels = append(labels, t.Format("15:04"))
-		data = append(data, s)
+	}
+
+	chart := ChartData{
+		Labels: labels,
+		Data:   data,
+		Datasets: []ChartDataset{
+			{Label: "Burnout Score", Data: data},
+			{Label: "7-Day Average", Data: movingAvg},
+		},
+		Trend:    buildTrendLine(loc, points, history),
+		EntryIDs: entryIDs,
+	}
+	if chart.Trend != nil {
+		chart.Datasets = append(chart.Datasets, ChartDataset{Label: "Trend", Data: chart.Trend.Points})
+	}
+	if periods, err := listPeriods(); err == nil {
+		chart.Ranges = periodRanges(periods, points)
+	}
+	if annotations, err := listAnnotations(); err == nil {
+		chart.Annotations = chartAnnotations(annotations, points)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(ChartData{Labels: labels, Data: data}); err != nil {
+	if err := json.NewEncoder(w).Encode(chart); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
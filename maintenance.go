@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maintenanceKey is the settings row that turns maintenance mode on; its
+// value is the note shown to people, which may be empty
+const maintenanceKey = "maintenance"
+
+// maintenanceRetryAfter is the Retry-After, in seconds, on refused writes
+const maintenanceRetryAfter = "300"
+
+// maintenanceStatus is whether the instance is in maintenance mode
+type maintenanceStatus struct {
+	On    bool
+	Note  string
+	Since time.Time
+}
+
+// maintenanceMode caches the settings row, re-reading it after a write
+// anywhere, so a toggle on one instance reaches all of them
+type maintenanceMode struct {
+	mu         sync.Mutex
+	loaded     bool
+	generation uint64
+	status     maintenanceStatus
+}
+
+var maintenance = &maintenanceMode{}
+
+// current reports the mode; while the database can't be read it keeps the
+// last known answer
+func (m *maintenanceMode) current() maintenanceStatus {
+	generation := state.Generation()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.loaded && generation == m.generation {
+		return m.status
+	}
+	var s maintenanceStatus
+	err := db.QueryRow(`SELECT value, updated_at FROM settings WHERE key = ?`, maintenanceKey).Scan(&s.Note, &s.Since)
+	switch {
+	case err == nil:
+		s.On = true
+	case !errors.Is(err, sql.ErrNoRows):
+		return m.status
+	}
+	m.status, m.generation, m.loaded = s, generation, true
+	return s
+}
+
+// set turns maintenance mode on with note, or off
+func (m *maintenanceMode) set(on bool, note string) error {
+	var err error
+	if on {
+		_, err = db.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, maintenanceKey, note, time.Now().UTC())
+	} else {
+		_, err = db.Exec(`DELETE FROM settings WHERE key = ?`, maintenanceKey)
+	}
+	if err == nil {
+		slog.Warn("maintenance mode", "on", on, "note", note)
+	}
+	return err
+}
+
+// maintenanceExempt are the paths that still take writes in maintenance
+// mode: signing in and out of /admin and the admin actions, so the mode
+// can be turned off again and jobs such as a backup run by hand
+var maintenanceExempt = []string{"/admin"}
+
+// maintenanceGuard refuses anything but reads while maintenance mode is
+// on. Pages and HTMX requests get a note to try again shortly, API clients
+// a 503 with Retry-After; the offline outbox keeps its check-ins and sends
+// them once the mode is off.
+func maintenanceGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS":
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range maintenanceExempt {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		status := maintenance.current()
+		if !status.On {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writeMaintenanceResponse(w, r, status)
+	})
+}
+
+var maintenanceFragment = template.Must(template.New("maintenance").Parse(
+	`<div role="alert" class="bg-amber-50 text-amber-800 text-sm rounded-xl px-4 py-3">{{.Message}}{{with .Note}} <span class="block mt-1">{{.}}</span>{{end}}</div>`))
+
+var maintenancePage = template.Must(template.New("maintenance").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head><meta charset="UTF-8"><meta name="viewport" content="width=device-width, initial-scale=1.0"><title>503</title></head>
+<body style="font-family: system-ui, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #1f2937">
+<h1 style="font-size: 1.25rem">{{.Message}}</h1>
+{{with .Note}}<p style="color: #6b7280">{{.}}</p>{{end}}
+<p><a href="/">{{.Home}}</a></p>
+</body>
+</html>
+`))
+
+func writeMaintenanceResponse(w http.ResponseWriter, r *http.Request, status maintenanceStatus) {
+	loc := localizer{Lang: negotiateLanguage(r)}
+	w.Header().Set("Retry-After", maintenanceRetryAfter)
+	w.Header().Set("Cache-Control", "no-store")
+	if strings.HasPrefix(r.URL.Path, "/api/") && r.Header.Get("HX-Request") != "true" {
+		http.Error(w, loc.T("error.maintenance"), http.StatusServiceUnavailable)
+		return
+	}
+	data := map[string]string{
+		"Lang":    loc.Lang,
+		"Message": loc.T("error.maintenance"),
+		"Note":    status.Note,
+		"Home":    loc.T("error.home"),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if r.Header.Get("HX-Request") == "true" {
+		maintenanceFragment.Execute(w, data)
+		return
+	}
+	maintenancePage.Execute(w, data)
+}
+
+// handleAdminMaintenance turns maintenance mode on or off from /admin
+func handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	on := r.FormValue("on") == "1"
+	if err := maintenance.set(on, strings.TrimSpace(r.FormValue("note"))); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	notice := "Maintenance mode off"
+	if on {
+		notice = "Maintenance mode on: writes are refused and background jobs paused"
+	}
+	http.Redirect(w, r, "/admin?"+url.Values{"notice": {notice}}.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// mdCell makes text safe inside a Markdown table cell
+func mdCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// buildMarkdownExport renders entries as a Markdown document with a summary,
+// a weekly table, every check-in and the journal notes
+func buildMarkdownExport(entries []BurnoutEntry, since, until time.Time) string {
+	var b strings.Builder
+
+	from, to := "the beginning", "today"
+	if !since.IsZero() {
+		from = since.Format("2006-01-02")
+	}
+	if !until.IsZero() {
+		to = until.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	fmt.Fprintf(&b, "# Burnout check-ins: %s to %s\n\n", from, to)
+
+	stats := summarize(entries)
+	if stats.Entries == 0 {
+		b.WriteString("_No check-ins in this range._\n")
+		return b.String()
+	}
+
+	b.WriteString("## Summary\n\n")
+	fmt.Fprintf(&b, "- **Check-ins:** %d\n", stats.Entries)
+	fmt.Fprintf(&b, "- **Average score:** %.1f (range %.0f–%.0f)\n", stats.AvgScore, stats.MinScore, stats.MaxScore)
+	fmt.Fprintf(&b, "- **Average sleep:** %.1fh · **study:** %.1fh\n", stats.AvgSleep, stats.AvgStudy)
+	fmt.Fprintf(&b, "- **Average mood:** %.1f/5 · **stress:** %.1f/5\n", stats.AvgMood, stats.AvgStress)
+	if best, worst := bestAndWorstDays(entries); best != nil && worst != nil {
+		fmt.Fprintf(&b, "- **Best day:** %s (%.0f) · **hardest day:** %s (%.0f)\n", best.Date, best.AvgScore, worst.Date, worst.AvgScore)
+	}
+
+	b.WriteString("\n## Weekly averages\n\n")
+	b.WriteString("| Week of | Check-ins | Score | Sleep | Study | Mood | Stress |\n")
+	b.WriteString("|---|---:|---:|---:|---:|---:|---:|\n")
+	for _, week := range bucketEntries(entries, granularityWeek) {
+		s := summarize(week.Entries)
+		fmt.Fprintf(&b, "| %s | %d | %.1f | %.1f | %.1f | %.1f | %.1f |\n",
+			week.Start.Format("2006-01-02"), s.Entries, s.AvgScore, s.AvgSleep, s.AvgStudy, s.AvgMood, s.AvgStress)
+	}
+
+	b.WriteString("\n## Check-ins\n\n")
+	b.WriteString("| Date | Score | Level | Sleep | Study | Deadlines | Mood | Stress | Exercise |\n")
+	b.WriteString("|---|---:|---|---:|---:|---:|---:|---:|:---:|\n")
+	for _, e := range entries {
+		exercise := ""
+		if e.Exercise {
+			exercise = "✓"
+		}
+		fmt.Fprintf(&b, "| %s | %.0f | %s | %.1f | %.1f | %d | %d | %d | %s |\n",
+			e.CreatedAt.UTC().Format("2006-01-02 15:04"), e.Score, mdCell(e.Level), e.Sleep, e.StudyHours,
+			e.Deadlines, e.Mood, e.Stress, exercise)
+	}
+
+	var notes []BurnoutEntry
+	for _, e := range entries {
+		if strings.TrimSpace(e.Journal) != "" {
+			notes = append(notes, e)
+		}
+	}
+	if len(notes) > 0 {
+		b.WriteString("\n## Journal\n")
+		for _, e := range notes {
+			fmt.Fprintf(&b, "\n### %s\n\n", e.CreatedAt.UTC().Format("Monday, January 2 2006"))
+			for _, line := range strings.Split(strings.TrimSpace(e.Journal), "\n") {
+				fmt.Fprintf(&b, "> %s\n", strings.TrimRight(line, "\r"))
+			}
+		}
+	}
+	return b.String()
+}
+
+// handleExportMarkdown returns the Markdown export for ?from/?to. The text
+// is served inline so it can be fetched and pasted; ?download=1 saves it.
+func handleExportMarkdown(w http.ResponseWriter, r *http.Request) {
+	since, until, policy, err := parseExportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	entries, err := exportEntries(since, until, policy)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	if r.URL.Query().Get("download") == "1" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="burnout-entries-%s.md"`, time.Now().Format("2006-01-02")))
+	}
+	w.Write([]byte(buildMarkdownExport(entries, since, until)))
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the histogram upper bounds, in seconds, for request,
+// query and job durations
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// latencyHistogram counts observations into latencyBuckets
+type latencyHistogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func (h *latencyHistogram) observe(seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, le := range latencyBuckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// metricSet is one labelled counter and/or histogram family. Labels are
+// joined with \x00 into the map key.
+type metricSet struct {
+	labels   []string
+	counters map[string]uint64
+	timings  map[string]*latencyHistogram
+}
+
+func newMetricSet(labels ...string) *metricSet {
+	return &metricSet{labels: labels, counters: map[string]uint64{}, timings: map[string]*latencyHistogram{}}
+}
+
+// metricsRegistry holds everything /metrics reports that is counted as it
+// happens; gauges such as entry counts are read at scrape time instead
+type metricsRegistry struct {
+	mu sync.Mutex
+	// requests by route, method and status code; durations by route
+	requests, requestTimes *metricSet
+	// database queries by operation (select, insert...) and result
+	queries, queryTimes *metricSet
+	// scheduler runs by job and result
+	jobs, jobTimes *metricSet
+	// export deliveries by kind (file, s3, webhook) and result
+	deliveries *metricSet
+	// response cache lookups by result (hit, miss)
+	cache *metricSet
+}
+
+var metrics = &metricsRegistry{
+	requests:     newMetricSet("route", "method", "code"),
+	requestTimes: newMetricSet("route"),
+	queries:      newMetricSet("operation", "result"),
+	queryTimes:   newMetricSet("operation"),
+	jobs:         newMetricSet("job", "result"),
+	jobTimes:     newMetricSet("job"),
+	deliveries:   newMetricSet("kind", "result"),
+	cache:        newMetricSet("result"),
+}
+
+// count adds one to a counter
+func (m *metricsRegistry) count(set *metricSet, labels ...string) {
+	m.mu.Lock()
+	set.counters[strings.Join(labels, "\x00")]++
+	m.mu.Unlock()
+}
+
+// time records a duration in a histogram
+func (m *metricsRegistry) time(set *metricSet, d time.Duration, labels ...string) {
+	key := strings.Join(labels, "\x00")
+	m.mu.Lock()
+	h := set.timings[key]
+	if h == nil {
+		h = &latencyHistogram{}
+		set.timings[key] = h
+	}
+	h.observe(d.Seconds())
+	m.mu.Unlock()
+}
+
+// result is the outcome label for an error
+func result(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+// statusRecorder remembers the status code a handler wrote
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses (the CSV export) streaming
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// instrument counts and times every request by the mux pattern that served
+// it, so /entries/12 and /entries/13 share the route "/entries/{id}"
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		route := routeOf(r)
+		if rec.code == 0 {
+			rec.code = http.StatusOK
+		}
+		metrics.count(metrics.requests, route, r.Method, strconv.Itoa(rec.code))
+		metrics.time(metrics.requestTimes, time.Since(start), route)
+	})
+}
+
+// handleMetrics serves the Prometheus text format. With METRICS_TOKEN set,
+// scrapers must send it as a Bearer token.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		bearer, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	metrics.mu.Lock()
+	writeCounter(w, "burnout_http_requests_total", "HTTP requests by route, method and status code.", metrics.requests)
+	writeHistogram(w, "burnout_http_request_duration_seconds", "HTTP request latency by route.", metrics.requestTimes)
+	writeCounter(w, "burnout_db_queries_total", "Database statements by operation and result.", metrics.queries)
+	writeHistogram(w, "burnout_db_query_duration_seconds", "Database statement latency by operation.", metrics.queryTimes)
+	writeCounter(w, "burnout_job_runs_total", "Background job runs by job and result.", metrics.jobs)
+	writeHistogram(w, "burnout_job_duration_seconds", "Background job duration by job.", metrics.jobTimes)
+	writeCounter(w, "burnout_export_deliveries_total", "Scheduled export deliveries by kind and result.", metrics.deliveries)
+	writeCounter(w, "burnout_response_cache_total", "Response cache lookups by result.", metrics.cache)
+	metrics.mu.Unlock()
+
+	var entries, recent int
+	db.QueryRow(`SELECT COUNT(*) FROM entries`).Scan(&entries)
+	db.QueryRow(`SELECT COUNT(*) FROM entries WHERE created_at >= ?`, time.Now().Add(-24*time.Hour)).Scan(&recent)
+	writeGauge(w, "burnout_entries", "Check-ins stored.", float64(entries))
+	writeGauge(w, "burnout_entries_last_24h", "Check-ins created in the last 24 hours.", float64(recent))
+
+	stats := db.Stats()
+	writeGauge(w, "burnout_db_open_connections", "Open database connections.", float64(stats.OpenConnections))
+	writeGauge(w, "burnout_db_in_use_connections", "Database connections in use.", float64(stats.InUse))
+	fmt.Fprintf(w, "# HELP burnout_db_wait_total Waits for a free database connection.\n# TYPE burnout_db_wait_total counter\nburnout_db_wait_total %d\n", stats.WaitCount)
+	writeGauge(w, "burnout_goroutines", "Goroutines running.", float64(runtime.NumGoroutine()))
+	writeGauge(w, "burnout_start_time_seconds", "Process start time as a Unix timestamp.", float64(startedAt.Unix()))
+}
+
+func writeGauge(w io.Writer, name, help string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(v))
+}
+
+func writeCounter(w io.Writer, name, help string, set *metricSet) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	for _, key := range sortedKeys(set.counters) {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, labelPairs(set.labels, key), set.counters[key])
+	}
+}
+
+func writeHistogram(w io.Writer, name, help string, set *metricSet) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for _, key := range sortedKeys(set.timings) {
+		h, labels := set.timings[key], labelPairs(set.labels, key)
+		var cumulative uint64
+		for i, le := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n%s_count{%s} %d\n", name, labels, formatFloat(h.sum), name, labels, h.count)
+	}
+}
+
+// labelPairs renders name="value" pairs for a \x00-joined key
+func labelPairs(names []string, key string) string {
+	values := strings.Split(key, "\x00")
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = n + "=" + strconv.Quote(values[i])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// onboardingSteps are the pages of the first-run guide, in order
+var onboardingSteps = []string{"score", "privacy", "baseline"}
+
+// baselineStressors are the choices offered for the main source of stress
+var baselineStressors = []string{"exams", "assignments", "work", "money", "relationships", "health", "other"}
+
+// Onboarding is the first-run progress, kept in the settings table so it
+// survives reloads and is shared by every device using the instance
+type Onboarding struct {
+	Step      string   `json:"step"` // the next step to show
+	Completed bool     `json:"completed"`
+	Baseline  Baseline `json:"baseline"`
+}
+
+// Baseline is what the user considers a normal week, asked once during
+// onboarding
+type Baseline struct {
+	SleepHours float64  `json:"sleep_hours"`
+	StudyHours float64  `json:"study_hours"` // per day
+	Stressors  []string `json:"stressors"`
+}
+
+// setSettings writes raw values to the settings table in one transaction
+func setSettings(values map[string]string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for key, value := range values {
+		_, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// loadOnboarding reads the stored progress; a fresh instance starts at the
+// first step
+func loadOnboarding() (Onboarding, error) {
+	o := Onboarding{Step: onboardingSteps[0], Baseline: Baseline{Stressors: []string{}}}
+	rows, err := db.Query(`SELECT key, value FROM settings WHERE key LIKE 'onboarding.%'`)
+	if err != nil {
+		return o, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return o, err
+		}
+		switch strings.TrimPrefix(key, "onboarding.") {
+		case "step":
+			if slices.Contains(onboardingSteps, value) {
+				o.Step = value
+			}
+		case "completed":
+			o.Completed = value == "1"
+		case "baseline_sleep":
+			o.Baseline.SleepHours, _ = strconv.ParseFloat(value, 64)
+		case "baseline_study":
+			o.Baseline.StudyHours, _ = strconv.ParseFloat(value, 64)
+		case "baseline_stressors":
+			if value != "" {
+				o.Baseline.Stressors = strings.Split(value, ",")
+			}
+		}
+	}
+	return o, rows.Err()
+}
+
+// parseBaseline reads the baseline step's form
+func parseBaseline(r *http.Request) (Baseline, error) {
+	b := Baseline{Stressors: []string{}}
+	var err error
+	if b.SleepHours, err = strconv.ParseFloat(r.PostFormValue("sleep"), 64); err != nil || b.SleepHours < 0 || b.SleepHours > 24 {
+		return b, fmt.Errorf("usual sleep must be between 0 and 24 hours")
+	}
+	if b.StudyHours, err = strconv.ParseFloat(r.PostFormValue("study"), 64); err != nil || b.StudyHours < 0 || b.StudyHours > 24 {
+		return b, fmt.Errorf("usual study time must be between 0 and 24 hours a day")
+	}
+	for _, s := range r.PostForm["stressor"] {
+		if !slices.Contains(baselineStressors, s) {
+			return b, fmt.Errorf("unknown stressor %q", s)
+		}
+		b.Stressors = append(b.Stressors, s)
+	}
+	return b, nil
+}
+
+// handleOnboarding shows one step (GET) or completes it (POST) and moves
+// on to the next; finishing the last step, or skipping, leads to the
+// check-in page
+func handleOnboarding(w http.ResponseWriter, r *http.Request) {
+	step := r.PathValue("step")
+	index := slices.Index(onboardingSteps, step)
+	if index < 0 && step != "skip" {
+		http.NotFound(w, r)
+		return
+	}
+	o, err := loadOnboarding()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{"Step": step, "Number": index + 1, "Total": len(onboardingSteps),
+		"Baseline": o.Baseline, "Stressors": baselineStressors}
+	status := http.StatusOK
+	switch r.Method {
+	case "GET":
+		if step == "skip" {
+			http.NotFound(w, r)
+			return
+		}
+	case "POST":
+		if err := r.ParseForm(); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		values := map[string]string{}
+		if step == "baseline" {
+			b, err := parseBaseline(r)
+			if err != nil {
+				data["Error"], data["Baseline"] = err.Error(), b
+				status = http.StatusBadRequest
+				break
+			}
+			values["onboarding.baseline_sleep"] = strconv.FormatFloat(b.SleepHours, 'f', -1, 64)
+			values["onboarding.baseline_study"] = strconv.FormatFloat(b.StudyHours, 'f', -1, 64)
+			values["onboarding.baseline_stressors"] = strings.Join(b.Stressors, ",")
+		}
+		next := "/"
+		if step == "skip" || index == len(onboardingSteps)-1 {
+			values["onboarding.completed"] = "1"
+		} else {
+			values["onboarding.step"] = onboardingSteps[index+1]
+			next = "/onboarding/" + onboardingSteps[index+1]
+		}
+		if err := setSettings(values); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := loadTemplate("onboarding.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if index > 0 {
+		data["Back"] = "/onboarding/" + onboardingSteps[index-1]
+	}
+	chosen := map[string]bool{}
+	for _, s := range data["Baseline"].(Baseline).Stressors {
+		chosen[s] = true
+	}
+	data["Chosen"] = chosen
+	data["Percent"] = 100 * (index + 1) / len(onboardingSteps)
+	w.WriteHeader(status)
+	tmpl.Execute(w, data)
+}
+
+// handleOnboardingState returns the onboarding progress and baseline
+func handleOnboardingState(w http.ResponseWriter, r *http.Request) {
+	o, err := loadOnboarding()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, o)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanics turns a panicking handler into a logged stack trace and a
+// 500, instead of a dropped connection. HTMX requests get a fragment that
+// app.js swaps in where the result would have gone; anything else gets a
+// bare page. It sits inside accessLog and instrument, so the trace carries
+// the request ID and the 500 is logged and counted like any other.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v) // the deliberate way to abort a response
+			}
+			requestLog(r).Error("panic", "panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+			reportPanic(r, v)
+			if rec.code != 0 {
+				return // too late for an error page; the client sees a cut-off response
+			}
+			writePanicResponse(w, r)
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+var panicFragment = template.Must(template.New("panic").Parse(
+	`<div role="alert" class="bg-red-50 text-red-800 text-sm rounded-xl px-4 py-3">{{.Message}} <span class="text-red-500">{{.Ref}}</span></div>`))
+
+var panicPage = template.Must(template.New("panic").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head><meta charset="UTF-8"><meta name="viewport" content="width=device-width, initial-scale=1.0"><title>500</title></head>
+<body style="font-family: system-ui, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #1f2937">
+<h1 style="font-size: 1.25rem">{{.Message}}</h1>
+<p style="color: #6b7280">{{.Ref}}</p>
+<p><a href="/">{{.Home}}</a></p>
+</body>
+</html>
+`))
+
+// writePanicResponse renders without the template cache or the database,
+// either of which may be what broke
+func writePanicResponse(w http.ResponseWriter, r *http.Request) {
+	loc := localizer{Lang: negotiateLanguage(r)}
+	data := map[string]string{
+		"Lang":    loc.Lang,
+		"Message": loc.T("error.internal"),
+		"Ref":     loc.T("error.reference", "ID", requestID(r)),
+		"Home":    loc.T("error.home"),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusInternalServerError)
+	if r.Header.Get("HX-Request") == "true" {
+		panicFragment.Execute(w, data)
+		return
+	}
+	panicPage.Execute(w, data)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// A minimal Parquet writer: one row group, one uncompressed PLAIN data page
+// per column and REQUIRED columns only. That is all the research export
+// needs and keeps the binary free of an Arrow dependency.
+
+// parquetType is the physical type of a column
+type parquetType int32
+
+const (
+	parquetBoolean   parquetType = 0
+	parquetInt32     parquetType = 1
+	parquetDouble    parquetType = 5
+	parquetByteArray parquetType = 6
+)
+
+// converted (logical) types used by the export
+const (
+	parquetNoConversion int32 = -1
+	parquetUTF8         int32 = 0
+	parquetDate         int32 = 6
+)
+
+// parquetColumn is one column's schema and values. Values must match Type:
+// bool, int32, float64 or string.
+type parquetColumn struct {
+	Name      string
+	Type      parquetType
+	Converted int32
+	Values    []any
+}
+
+// thriftWriter emits the Thrift compact protocol used by Parquet metadata
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID []int16
+}
+
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftWriter) zigzag(v int64) { t.varint(uint64((v << 1) ^ (v >> 63))) }
+
+func (t *thriftWriter) beginStruct() { t.lastID = append(t.lastID, 0) }
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0)
+	t.lastID = t.lastID[:len(t.lastID)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.lastID[len(t.lastID)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) { t.field(id, thriftI32); t.zigzag(int64(v)) }
+func (t *thriftWriter) i64(id int16, v int64) { t.field(id, thriftI64); t.zigzag(v) }
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xF0 | elem)
+		t.varint(uint64(n))
+	}
+}
+
+// plainValues encodes a column's values with PLAIN encoding
+func plainValues(c parquetColumn) []byte {
+	var b bytes.Buffer
+	switch c.Type {
+	case parquetBoolean:
+		packed := make([]byte, (len(c.Values)+7)/8)
+		for i, v := range c.Values {
+			if v.(bool) {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		b.Write(packed)
+	case parquetInt32:
+		for _, v := range c.Values {
+			binary.Write(&b, binary.LittleEndian, v.(int32))
+		}
+	case parquetDouble:
+		for _, v := range c.Values {
+			binary.Write(&b, binary.LittleEndian, math.Float64bits(v.(float64)))
+		}
+	case parquetByteArray:
+		for _, v := range c.Values {
+			s := v.(string)
+			binary.Write(&b, binary.LittleEndian, uint32(len(s)))
+			b.WriteString(s)
+		}
+	}
+	return b.Bytes()
+}
+
+// writeParquet writes columns of equal length as a Parquet file
+func writeParquet(w io.Writer, columns []parquetColumn) error {
+	const magic = "PAR1"
+	var file bytes.Buffer
+	file.WriteString(magic)
+
+	numRows := 0
+	if len(columns) > 0 {
+		numRows = len(columns[0].Values)
+	}
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(columns))
+	for i, c := range columns {
+		data := plainValues(c)
+		var header thriftWriter
+		header.beginStruct()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(data)))
+		header.i32(3, int32(len(data)))
+		header.field(5, thriftStruct)
+		header.beginStruct()
+		header.i32(1, int32(numRows))
+		header.i32(2, 0) // PLAIN
+		header.i32(3, 3) // RLE definition levels (none for REQUIRED)
+		header.i32(4, 3) // RLE repetition levels
+		header.endStruct()
+		header.endStruct()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.buf.Len() + len(data))}
+		file.Write(header.buf.Bytes())
+		file.Write(data)
+	}
+
+	var meta thriftWriter
+	meta.beginStruct()
+	meta.i32(1, 1) // version
+	meta.list(2, thriftStruct, len(columns)+1)
+	meta.beginStruct()
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(columns)))
+	meta.endStruct()
+	for _, c := range columns {
+		meta.beginStruct()
+		meta.i32(1, int32(c.Type))
+		meta.i32(3, 0) // REQUIRED
+		meta.str(4, c.Name)
+		if c.Converted != parquetNoConversion {
+			meta.i32(6, c.Converted)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, int64(numRows))
+
+	var total int64
+	for _, ch := range chunks {
+		total += ch.size
+	}
+	meta.list(4, thriftStruct, 1)
+	meta.beginStruct()
+	meta.list(1, thriftStruct, len(columns))
+	for i, c := range columns {
+		meta.beginStruct()
+		meta.i64(2, chunks[i].offset)
+		meta.field(3, thriftStruct)
+		meta.beginStruct()
+		meta.i32(1, int32(c.Type))
+		meta.list(2, thriftI32, 1)
+		meta.zigzag(0) // PLAIN
+		meta.list(3, thriftBinary, 1)
+		meta.varint(uint64(len(c.Name)))
+		meta.buf.WriteString(c.Name)
+		meta.i32(4, 0) // UNCOMPRESSED
+		meta.i64(5, int64(numRows))
+		meta.i64(6, chunks[i].size)
+		meta.i64(7, chunks[i].size)
+		meta.i64(9, chunks[i].offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(2, total)
+	meta.i64(3, int64(numRows))
+	meta.endStruct()
+	meta.str(6, "burnout-detector")
+	meta.endStruct()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString(magic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
@@ -0,0 +1,78 @@
+//go:build parquetreader
+
+// The Parquet export is checked against an independent reader, which is
+// only a test dependency: go test -tags parquetreader ./...
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// TestWriteParquetReadBack reads writeParquet's output with an independent
+// Parquet reader: the footer through its Thrift decoder, then every column
+func TestWriteParquetReadBack(t *testing.T) {
+	columns := []parquetColumn{
+		{Name: "date", Type: parquetInt32, Converted: parquetDate, Values: []any{int32(20000), int32(20001), int32(20002)}},
+		{Name: "sleep", Type: parquetDouble, Converted: parquetNoConversion, Values: []any{7.5, 4.25, 0.0}},
+		{Name: "exercise", Type: parquetBoolean, Converted: parquetNoConversion, Values: []any{true, false, true}},
+		{Name: "level", Type: parquetByteArray, Converted: parquetUTF8, Values: []any{"healthy", "at-risk", ""}},
+	}
+	var out bytes.Buffer
+	if err := writeParquet(&out, columns); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := buffer.NewBufferFile(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		t.Fatalf("reading the footer: %v", err)
+	}
+	defer pr.ReadStop()
+
+	if n := pr.GetNumRows(); n != 3 {
+		t.Fatalf("%d rows, want 3", n)
+	}
+	if got := pr.Footer.GetCreatedBy(); got != "burnout-detector" {
+		t.Errorf("created_by %q", got)
+	}
+	schema := pr.Footer.Schema
+	if len(schema) != len(columns)+1 || schema[0].GetNumChildren() != int32(len(columns)) {
+		t.Fatalf("schema has %d elements, root with %d children; want %d and %d",
+			len(schema), schema[0].GetNumChildren(), len(columns)+1, len(columns))
+	}
+	wantTypes := []parquet.Type{parquet.Type_INT32, parquet.Type_DOUBLE, parquet.Type_BOOLEAN, parquet.Type_BYTE_ARRAY}
+	wantConverted := []*parquet.ConvertedType{parquet.ConvertedTypePtr(parquet.ConvertedType_DATE), nil, nil,
+		parquet.ConvertedTypePtr(parquet.ConvertedType_UTF8)}
+	for i, c := range columns {
+		// The reader renames the elements to Go names; the file's are kept
+		// as the external names
+		el, name := schema[i+1], pr.SchemaHandler.Infos[i+1].ExName
+		if name != c.Name || el.GetType() != wantTypes[i] ||
+			el.GetRepetitionType() != parquet.FieldRepetitionType_REQUIRED {
+			t.Errorf("column %d: %s %v %v", i, name, el.GetType(), el.GetRepetitionType())
+		}
+		if !reflect.DeepEqual(el.ConvertedType, wantConverted[i]) {
+			t.Errorf("column %s: converted type %v, want %v", c.Name, el.ConvertedType, wantConverted[i])
+		}
+	}
+
+	for i, c := range columns {
+		values, _, _, err := pr.ReadColumnByIndex(int64(i), 3)
+		if err != nil {
+			t.Fatalf("column %s: %v", c.Name, err)
+		}
+		if !reflect.DeepEqual(values, c.Values) {
+			t.Errorf("column %s: read %v, wrote %v", c.Name, values, c.Values)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// pdfText strips characters the core PDF fonts cannot show, such as the
+// emoji in level names, and converts the rest to the font's encoding
+func pdfText(tr func(string) string, s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r > 0xFF && r != '–' && r != '—' && r != '’' && r != '“' && r != '”' && r != '…' {
+			return -1
+		}
+		return r
+	}, s)
+	return tr(strings.TrimSpace(s))
+}
+
+// scoreContribution is one line of the score breakdown
+type scoreContribution struct {
+	Factor string
+	Detail string
+	Points float64
+}
+
+// scoreBreakdown splits an entry's score into the terms of burnoutScore
+func scoreBreakdown(e BurnoutEntry) []scoreContribution {
+	s := currentScoring()
+	parts := []scoreContribution{
+		{"Deadlines", fmt.Sprintf("%d this week x %g", e.Deadlines, s.DeadlineWeight), float64(e.Deadlines) * s.DeadlineWeight},
+		{"Stress", fmt.Sprintf("%d/5 x %g", e.Stress, s.StressWeight), float64(e.Stress) * s.StressWeight},
+		{"Sleep", fmt.Sprintf("(%g - %.1fh) x %g", s.SleepTarget, e.Sleep, s.SleepWeight), (s.SleepTarget - e.Sleep) * s.SleepWeight},
+		{"Study", fmt.Sprintf("%.1fh x %g", e.StudyHours, s.StudyWeight), e.StudyHours * s.StudyWeight},
+	}
+	if e.Exercise {
+		parts = append(parts, scoreContribution{"Exercise", "recovery credit", -s.ExerciseRecovery})
+	}
+	return parts
+}
+
+// breakdownRemainder is the part of the stored score the breakdown does
+// not explain: the recovery-habit bonus and the 0-100 cap. Zero when the
+// parts add up.
+func breakdownRemainder(e BurnoutEntry, parts []scoreContribution) float64 {
+	diff := e.Score
+	for _, part := range parts {
+		diff -= part.Points
+	}
+	if math.Abs(diff) < 0.05 {
+		return 0
+	}
+	return diff
+}
+
+// drawGauge draws a semicircular 0-100 gauge centred on (cx, cy)
+func drawGauge(pdf *fpdf.Fpdf, cx, cy, r, score float64) {
+	pdf.SetLineWidth(6)
+	for _, band := range gaugeBands() {
+		pdf.SetDrawColor(int(band.Fill.R), int(band.Fill.G), int(band.Fill.B))
+		pdf.Arc(cx, cy, r, r, 0, gaugeAngle(band.To)*180/math.Pi, gaugeAngle(band.From)*180/math.Pi, "D")
+	}
+
+	angle := gaugeAngle(score)
+	pdf.SetDrawColor(int(gaugeNeedleColor.R), int(gaugeNeedleColor.G), int(gaugeNeedleColor.B))
+	pdf.SetLineWidth(1.2)
+	pdf.Line(cx, cy, cx+(r-6)*math.Cos(angle), cy-(r-6)*math.Sin(angle))
+	pdf.SetFillColor(int(gaugeNeedleColor.R), int(gaugeNeedleColor.G), int(gaugeNeedleColor.B))
+	pdf.Circle(cx, cy, 2, "F")
+	pdf.SetLineWidth(0.2)
+}
+
+// buildPDFReport renders the full report for one entry
+func buildPDFReport(e BurnoutEntry, loc localizer) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(brand.Name+" Report", true)
+	pdf.SetCreator(brand.Name, true)
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	if brand.Footer != "" {
+		pdf.SetFooterFunc(func() {
+			pdf.SetY(-15)
+			pdf.SetFont("Helvetica", "", 8)
+			pdf.SetTextColor(156, 163, 175)
+			pdf.CellFormat(0, 6, tr(brand.Footer), "", 0, "C", false, 0, "")
+		})
+	}
+	pdf.AddPage()
+
+	// The logo sits left of the title; SVG logos are only shown on pages
+	titleX := 20.0
+	if imageType, ok := map[string]string{"image/png": "PNG", "image/jpeg": "JPG"}[brand.logoType]; ok {
+		opts := fpdf.ImageOptions{ImageType: imageType}
+		pdf.RegisterImageOptionsReader("logo", opts, bytes.NewReader(brand.logo))
+		if info := pdf.GetImageInfo("logo"); info != nil && info.Height() > 0 {
+			width := 10 * info.Width() / info.Height()
+			pdf.ImageOptions("logo", 20, pdf.GetY(), width, 10, false, opts, 0, "")
+			titleX += width + 3
+		}
+	}
+	pdf.SetX(titleX)
+	pdf.SetFont("Helvetica", "B", 22)
+	pdf.SetTextColor(rgb(brand.Accent))
+	pdf.Cell(0, 10, tr(brand.Name+" Report"))
+	pdf.Ln(10)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetTextColor(107, 114, 128)
+	pdf.Cell(0, 6, tr(fmt.Sprintf("Check-in of %s %s  -  generated %s",
+		loc.DateTime(e.CreatedAt), loc.ZoneName(), loc.DateLong(time.Now()))))
+	pdf.Ln(8)
+	pdf.SetDrawColor(229, 231, 235)
+	pdf.Line(20, pdf.GetY(), 190, pdf.GetY())
+
+	// Gauge with the score and level beside it
+	top := pdf.GetY() + 8
+	drawGauge(pdf, 60, top+35, 30, e.Score)
+	pdf.SetXY(105, top+10)
+	pdf.SetTextColor(17, 24, 39)
+	pdf.SetFont("Helvetica", "B", 36)
+	pdf.Cell(0, 14, fmt.Sprintf("%.0f / 100", e.Score))
+	pdf.SetXY(105, top+26)
+	pdf.SetFont("Helvetica", "", 14)
+	pdf.Cell(0, 8, pdfText(tr, e.Level))
+	pdf.SetY(top + 45)
+
+	// Breakdown of the score
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.Cell(0, 8, "Score breakdown")
+	pdf.Ln(9)
+	pdf.SetFont("Helvetica", "", 11)
+	for _, part := range scoreBreakdown(e) {
+		pdf.CellFormat(40, 7, part.Factor, "B", 0, "L", false, 0, "")
+		pdf.CellFormat(90, 7, part.Detail, "B", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 7, fmt.Sprintf("%+.0f", part.Points), "B", 1, "R", false, 0, "")
+	}
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(130, 7, "Score (capped to 0-100)", "", 0, "L", false, 0, "")
+	pdf.CellFormat(40, 7, fmt.Sprintf("%.0f", e.Score), "", 1, "R", false, 0, "")
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.CellFormat(0, 7, fmt.Sprintf("Mood %d/5, exercise: %s", e.Mood, map[bool]string{true: "yes", false: "no"}[e.Exercise]), "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	// Trend chart, rendered by the same code as /api/chart.png
+	points, _, err := loadChartPoints(granularityDay, defaultChartDays)
+	if err != nil {
+		return nil, err
+	}
+	chart := chartImage{Width: 1000, Height: 360, Title: fmt.Sprintf("Burnout score - last %d days", defaultChartDays)}
+	for _, p := range points {
+		chart.Labels = append(chart.Labels, chartLabel(loc, p.At, granularityDay))
+		chart.Scores = append(chart.Scores, roundTo(p.Score, 1))
+	}
+	var img bytes.Buffer
+	if err := png.Encode(&img, chart.PNG()); err != nil {
+		return nil, err
+	}
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.Cell(0, 8, "Trend")
+	pdf.Ln(9)
+	pdf.RegisterImageOptionsReader("trend", fpdf.ImageOptions{ImageType: "PNG"}, &img)
+	pdf.ImageOptions("trend", 20, pdf.GetY(), 170, 0, true, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+	pdf.Ln(4)
+
+	// Advice
+	pdf.SetFont("Helvetica", "B", 13)
+	pdf.Cell(0, 8, "Personal insight")
+	pdf.Ln(9)
+	pdf.SetFont("Helvetica", "", 11)
+	pdf.SetTextColor(55, 65, 81)
+	pdf.MultiCell(0, 6, pdfText(tr, e.Advice), "", "L", false)
+	if e.Journal != "" {
+		pdf.Ln(3)
+		pdf.SetFont("Helvetica", "I", 11)
+		pdf.MultiCell(0, 6, pdfText(tr, "Journal: "+e.Journal), "", "L", false)
+	}
+
+	var out bytes.Buffer
+	if err := pdf.Output(&out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// handlePDFReport returns the PDF report for ?entry (default: latest check-in)
+func handlePDFReport(w http.ResponseWriter, r *http.Request) {
+	entry, ok := entryFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := buildPDFReport(entry, requestLocalizer(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="burnout-report-%s.pdf"`, entry.CreatedAt.UTC().Format("2006-01-02")))
+	w.Write(report)
+}
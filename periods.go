@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const periodsSchema = `
+	CREATE TABLE IF NOT EXISTS periods (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		start_date TEXT NOT NULL,
+		end_date TEXT NOT NULL
+	);
+`
+
+// Period is a named date range such as an exam season. Dates are inclusive
+// and stored as YYYY-MM-DD.
+type Period struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	StartDate string `json:"start_date"`
+	EndDate   string `json:"end_date"`
+}
+
+// bounds returns the period as a half-open [start, end) time range
+func (p Period) bounds() (time.Time, time.Time, error) {
+	start, err := parseDay(p.StartDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start_date %q (use YYYY-MM-DD)", p.StartDate)
+	}
+	end, err := parseDay(p.EndDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end_date %q (use YYYY-MM-DD)", p.EndDate)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end_date must not be before start_date")
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// contains reports whether t falls inside the period
+func (p Period) contains(t time.Time) bool {
+	start, end, err := p.bounds()
+	return err == nil && !t.Before(start) && t.Before(end)
+}
+
+// listPeriods returns all periods ordered by start date
+func listPeriods() ([]Period, error) {
+	rows, err := db.Query(`SELECT id, name, start_date, end_date FROM periods ORDER BY start_date ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := []Period{}
+	for rows.Next() {
+		var p Period
+		if err := rows.Scan(&p.ID, &p.Name, &p.StartDate, &p.EndDate); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// handlePeriods lists (GET) or creates (POST) named periods
+func handlePeriods(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		periods, err := listPeriods()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, periods)
+
+	case "POST":
+		var p Period
+		if err := decodeJSON(r, &p); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		p.Name = strings.TrimSpace(p.Name)
+		if p.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := p.bounds(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`INSERT INTO periods (name, start_date, end_date) VALUES (?, ?, ?)`,
+			p.Name, p.StartDate, p.EndDate)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p.ID, _ = res.LastInsertId()
+		writeJSON(w, http.StatusCreated, p)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePeriod deletes a single period
+func handlePeriod(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := db.Exec(`DELETE FROM periods WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Period not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PeriodComparison contrasts entries inside a period with all others
+type PeriodComparison struct {
+	Period     Period             `json:"period"`
+	InPeriod   PeriodStats        `json:"in_period"`
+	OutPeriod  PeriodStats        `json:"out_of_period"`
+	ScoreDelta *float64           `json:"score_delta"`
+	Deltas     map[string]float64 `json:"deltas,omitempty"`
+}
+
+// comparePeriod splits entries on whether they fall inside p
+func comparePeriod(p Period, entries []BurnoutEntry) PeriodComparison {
+	var in, out []BurnoutEntry
+	for _, e := range entries {
+		if p.contains(e.CreatedAt) {
+			in = append(in, e)
+		} else {
+			out = append(out, e)
+		}
+	}
+	c := PeriodComparison{Period: p, InPeriod: summarize(in), OutPeriod: summarize(out)}
+	if len(in) > 0 && len(out) > 0 {
+		delta := roundTo(c.InPeriod.AvgScore-c.OutPeriod.AvgScore, 1)
+		c.ScoreDelta = &delta
+		c.Deltas = map[string]float64{
+			"avg_sleep":       roundTo(c.InPeriod.AvgSleep-c.OutPeriod.AvgSleep, 1),
+			"avg_study_hours": roundTo(c.InPeriod.AvgStudy-c.OutPeriod.AvgStudy, 1),
+			"avg_stress":      roundTo(c.InPeriod.AvgStress-c.OutPeriod.AvgStress, 1),
+			"avg_mood":        roundTo(c.InPeriod.AvgMood-c.OutPeriod.AvgMood, 1),
+		}
+	}
+	return c
+}
+
+// handlePeriodInsights compares in-period vs out-of-period averages for
+// every defined period
+func handlePeriodInsights(w http.ResponseWriter, r *http.Request) {
+	periods, err := listPeriods()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entries, err := queryEntries(time.Time{}, time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	comparisons := []PeriodComparison{}
+	for _, p := range periods {
+		comparisons = append(comparisons, comparePeriod(p, entries))
+	}
+	writeJSON(w, http.StatusOK, comparisons)
+}
+
+// ChartRange marks a span of the chart (by label index) to shade
+type ChartRange struct {
+	Label      string `json:"label"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
+// periodRanges maps the periods onto the indices of the plotted points
+func periodRanges(periods []Period, points []scorePoint) []ChartRange {
+	var ranges []ChartRange
+	for _, p := range periods {
+		first, last := -1, -1
+		for i, pt := range points {
+			if p.contains(pt.At) {
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+		if first >= 0 {
+			ranges = append(ranges, ChartRange{Label: p.Name, StartIndex: first, EndIndex: last})
+		}
+	}
+	return ranges
+}
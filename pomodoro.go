@@ -0,0 +1,242 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const pomodoroSchema = `
+	CREATE TABLE IF NOT EXISTS pomodoro_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		label TEXT NOT NULL DEFAULT '',
+		planned_minutes INTEGER NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME
+	);
+`
+
+// defaultPomodoroMinutes is the classic 25-minute focus block
+const defaultPomodoroMinutes = 25
+
+// PomodoroSession is one focus block; EndedAt is nil while it is running
+type PomodoroSession struct {
+	ID             int64      `json:"id"`
+	Label          string     `json:"label"`
+	PlannedMinutes int        `json:"planned_minutes"`
+	StartedAt      time.Time  `json:"started_at"`
+	EndedAt        *time.Time `json:"ended_at"`
+	Minutes        float64    `json:"minutes"`
+}
+
+// scanPomodoro reads a session row and fills in its elapsed minutes
+func scanPomodoro(row interface{ Scan(...any) error }) (PomodoroSession, error) {
+	var s PomodoroSession
+	var ended sql.NullTime
+	if err := row.Scan(&s.ID, &s.Label, &s.PlannedMinutes, &s.StartedAt, &ended); err != nil {
+		return s, err
+	}
+	end := time.Now().UTC()
+	if ended.Valid {
+		s.EndedAt = &ended.Time
+		end = ended.Time
+	}
+	s.Minutes = roundTo(end.Sub(s.StartedAt).Minutes(), 1)
+	return s, nil
+}
+
+// activePomodoro returns the running session, if any
+func activePomodoro() (*PomodoroSession, error) {
+	s, err := scanPomodoro(db.QueryRow(`
+		SELECT id, label, planned_minutes, started_at, ended_at FROM pomodoro_sessions
+		WHERE ended_at IS NULL ORDER BY id DESC LIMIT 1`))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// focusHoursByDay sums finished Pomodoro time per UTC day in [since, until)
+func focusHoursByDay(since, until time.Time) (map[string]float64, error) {
+	rows, err := db.Query(`
+		SELECT started_at, ended_at FROM pomodoro_sessions
+		WHERE ended_at IS NOT NULL AND started_at >= ? AND started_at < ?`, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hours := map[string]float64{}
+	for rows.Next() {
+		var start, end time.Time
+		if err := rows.Scan(&start, &end); err != nil {
+			return nil, err
+		}
+		hours[dayKey(start)] += end.Sub(start).Hours()
+	}
+	return hours, rows.Err()
+}
+
+// loggedStudyHours returns the study time logged on the day containing t
+// through Pomodoro blocks and study sessions, used when a check-in leaves
+// study hours blank. The two logs are summed, so a block should be recorded
+// in only one of them.
+func loggedStudyHours(t time.Time) (float64, error) {
+	day := bucketStart(t, granularityDay)
+	hours, err := focusHoursByDay(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+	sessions, err := studySessionHours(t)
+	if err != nil {
+		return 0, err
+	}
+	return roundTo(hours[day.Format("2006-01-02")]+sessions, 1), nil
+}
+
+// PomodoroStart is the body of POST /api/pomodoro/start
+type PomodoroStart struct {
+	Label   string `json:"label"`
+	Minutes int    `json:"minutes"`
+}
+
+// handlePomodoroStart starts a focus session
+func handlePomodoroStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req PomodoroStart
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+	}
+	if req.Minutes == 0 {
+		req.Minutes = defaultPomodoroMinutes
+	}
+	if req.Minutes < 1 || req.Minutes > 180 {
+		http.Error(w, "minutes must be between 1 and 180", http.StatusBadRequest)
+		return
+	}
+
+	running, err := activePomodoro()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if running != nil {
+		http.Error(w, "A session is already running", http.StatusConflict)
+		return
+	}
+
+	s := PomodoroSession{Label: strings.TrimSpace(req.Label), PlannedMinutes: req.Minutes, StartedAt: time.Now().UTC()}
+	res, err := db.Exec(`INSERT INTO pomodoro_sessions (label, planned_minutes, started_at) VALUES (?, ?, ?)`,
+		s.Label, s.PlannedMinutes, s.StartedAt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.ID, _ = res.LastInsertId()
+	writeJSON(w, http.StatusCreated, s)
+}
+
+// handlePomodoroStop ends the running focus session
+func handlePomodoroStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	running, err := activePomodoro()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if running == nil {
+		http.Error(w, "No session is running", http.StatusConflict)
+		return
+	}
+
+	ended := time.Now().UTC()
+	if _, err := db.Exec(`UPDATE pomodoro_sessions SET ended_at = ? WHERE id = ?`, ended, running.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	running.EndedAt = &ended
+	running.Minutes = roundTo(ended.Sub(running.StartedAt).Minutes(), 1)
+	writeJSON(w, http.StatusOK, running)
+}
+
+// handlePomodoroSessions lists sessions from the last ?days (default 7)
+func handlePomodoroSessions(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, 7)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rows, err := db.Query(`
+		SELECT id, label, planned_minutes, started_at, ended_at FROM pomodoro_sessions
+		WHERE started_at >= ? ORDER BY started_at DESC`, time.Now().UTC().AddDate(0, 0, -days))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	sessions := []PomodoroSession{}
+	for rows.Next() {
+		s, err := scanPomodoro(rows)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sessions = append(sessions, s)
+	}
+	writeJSON(w, http.StatusOK, sessions)
+}
+
+// handleFocusChart overlays daily focus hours on the daily average score
+func handleFocusChart(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultInsightDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	today := bucketStart(time.Now(), granularityDay)
+	start := today.AddDate(0, 0, -(days - 1))
+	focus, err := focusHoursByDay(start, today.AddDate(0, 0, 1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	entries, err := queryEntries(start, time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	scores := map[string]float64{}
+	for _, b := range bucketEntries(entries, granularityDay) {
+		scores[b.Start.Format("2006-01-02")] = roundTo(averageOf(b.Entries, entryScore), 1)
+	}
+
+	loc := requestLocalizer(r)
+	chart := ChartData{Labels: []string{}, Data: []float64{}}
+	focusData := []float64{}
+	for d := start; !d.After(today); d = d.AddDate(0, 0, 1) {
+		key := d.Format("2006-01-02")
+		chart.Labels = append(chart.Labels, chartLabel(loc, d, granularityDay))
+		chart.Data = append(chart.Data, scores[key])
+		focusData = append(focusData, roundTo(focus[key], 1))
+	}
+	chart.Datasets = []ChartDataset{
+		{Label: "Burnout Score", Data: chart.Data},
+		{Label: "Focus (Hrs)", Data: focusData},
+	}
+	writeJSON(w, http.StatusOK, chart)
+}
@@ -0,0 +1,22 @@
+package main
+
+import "net/http/pprof"
+
+// registerPprof mounts the profiler on an admin-only group. Capture a
+// profile with, e.g.:
+//
+//	curl -H "Authorization: Bearer $ADMIN_TOKEN" -o cpu.pprof \
+//	     https://host/debug/pprof/profile?seconds=30
+//	go tool pprof cpu.pprof
+//
+// A profile or trace must be shorter than HTTP_WRITE_TIMEOUT. Importing
+// net/http/pprof also registers it on http.DefaultServeMux, which
+// the server doesn't serve.
+func registerPprof(admin *router) {
+	admin.HandleFunc("GET /debug/pprof/", pprof.Index)
+	admin.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	admin.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	admin.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	admin.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	admin.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// prefillWindow is how many recent check-ins typical values are taken from
+const prefillWindow = 14
+
+// checkinDefaults are the values the check-in form starts with, so a daily
+// check-in is a few adjustments rather than a blank form
+type checkinDefaults struct {
+	// Source is "last", "typical" or "baseline" (the onboarding answers,
+	// before the first check-in); empty when there is nothing to go on
+	Source     string  `json:"source"`
+	Sleep      float64 `json:"sleep"`
+	StudyHours float64 `json:"study_hours"`
+	Deadlines  int     `json:"deadlines"`
+	Mood       int     `json:"mood"`
+	Stress     int     `json:"stress"`
+	Exercise   bool    `json:"exercise"`
+	// Fields left blank because today's logs fill them in on submit
+	SleepLogged     bool `json:"sleep_logged"`
+	StudyLogged     bool `json:"study_logged"`
+	DeadlinesLogged bool `json:"deadlines_logged"`
+}
+
+// FromEntries reports whether the values come from past check-ins, which
+// is when the deadlines count and toggles are worth pre-filling too
+func (d checkinDefaults) FromEntries() bool {
+	return d.Source == "last" || d.Source == "typical"
+}
+
+// loadCheckinDefaults picks the form's starting values according to the
+// prefill setting
+func loadCheckinDefaults(now time.Time) (checkinDefaults, error) {
+	d := checkinDefaults{Mood: 3, Stress: 3}
+	settings, err := loadSettings()
+	if err != nil {
+		return d, err
+	}
+	if settings.Prefill == "off" {
+		return d, nil
+	}
+
+	window := 1
+	if settings.Prefill == "typical" {
+		window = prefillWindow
+	}
+	recent, err := queryRecentEntries(window)
+	if err != nil {
+		return d, err
+	}
+	switch {
+	case len(recent) == 0:
+		o, err := loadOnboarding()
+		if err != nil {
+			return d, err
+		}
+		if o.Baseline.SleepHours > 0 || o.Baseline.StudyHours > 0 {
+			d.Source, d.Sleep, d.StudyHours = "baseline", o.Baseline.SleepHours, o.Baseline.StudyHours
+		}
+	case settings.Prefill == "typical":
+		d.Source = "typical"
+		d.Sleep = math.Round(median(recent, entrySleep)*2) / 2
+		d.StudyHours = math.Round(median(recent, entryStudyHours)*2) / 2
+		d.Deadlines = int(math.Round(median(recent, entryDeadlines)))
+		d.Mood = int(math.Round(median(recent, entryMood)))
+		d.Stress = int(math.Round(median(recent, entryStress)))
+		d.Exercise = averageOf(recent, entryExercise) > 0.5
+	default:
+		last := recent[len(recent)-1]
+		d.Source, d.Sleep, d.StudyHours, d.Deadlines = "last", last.Sleep, last.StudyHours, last.Deadlines
+		d.Mood, d.Stress, d.Exercise = last.Mood, last.Stress, last.Exercise
+	}
+
+	// The same blanks /calculate fills from the trackers
+	if _, ok, err := effectiveSleep(now); err == nil && ok {
+		d.SleepLogged = true
+	}
+	if logged, err := loggedStudyHours(now); err == nil && logged > 0 {
+		d.StudyLogged = true
+	}
+	if load, err := upcomingDeadlineLoad(now); err == nil && load > 0 {
+		d.DeadlinesLogged = true
+	}
+	return d, nil
+}
+
+// median returns the middle value of field over entries (0 when empty)
+func median(entries []BurnoutEntry, field func(BurnoutEntry) float64) float64 {
+	if len(entries) == 0 {
+		return 0
+	}
+	values := make([]float64, len(entries))
+	for i, e := range entries {
+		values[i] = field(e)
+	}
+	slices.Sort(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+// handleCheckinDefaults returns the values the check-in form would start
+// with
+func handleCheckinDefaults(w http.ResponseWriter, r *http.Request) {
+	d, err := loadCheckinDefaults(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
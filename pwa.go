@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// pwaIconSizes are the square icon sizes listed in the manifest
+var pwaIconSizes = []int{192, 512}
+
+// handleManifest serves the web app manifest that makes the app installable
+func handleManifest(w http.ResponseWriter, r *http.Request) {
+	icons := []map[string]string{}
+	for _, size := range pwaIconSizes {
+		icons = append(icons, map[string]string{
+			"src":     fmt.Sprintf("/icons/icon-%d.png", size),
+			"sizes":   fmt.Sprintf("%dx%d", size, size),
+			"type":    "image/png",
+			"purpose": "any maskable",
+		})
+	}
+	w.Header().Set("Content-Type", "application/manifest+json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(map[string]any{
+		"name":             brand.Name,
+		"short_name":       "Burnout",
+		"description":      "Daily student wellness check-ins",
+		"start_url":        "/",
+		"scope":            "/",
+		"display":          "standalone",
+		"background_color": "#F9FAFB",
+		"theme_color":      brand.Accent,
+		"icons":            icons,
+	})
+}
+
+// serviceWorker is the worker script with its precache list, built once
+var serviceWorker = sync.OnceValues(buildServiceWorker)
+
+// buildServiceWorker prefixes static/js/sw.js with the URLs to precache: the
+// check-in page and every embedded asset under its hashed URL. The cache
+// name is derived from that list, so any asset change rolls the cache.
+func buildServiceWorker() ([]byte, error) {
+	script, ok := staticAssets["js/sw.js"]
+	if !ok {
+		return nil, fmt.Errorf("static/js/sw.js is not embedded")
+	}
+	precache := []string{"/"}
+	for name, a := range staticAssets {
+		if a.name == name && name != "js/sw.js" {
+			precache = append(precache, a.url)
+		}
+	}
+	slices.Sort(precache[1:])
+
+	list, err := json.Marshal(precache)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(append(list, script.data...))
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "const CACHE_NAME = 'burnout-%s';\nconst PRECACHE = %s;\n\n", hex.EncodeToString(sum[:])[:10], list)
+	buf.Write(script.data)
+	return buf.Bytes(), nil
+}
+
+// handleServiceWorker serves the worker from the root so its scope covers
+// the whole app. Browsers re-check it on every navigation.
+func handleServiceWorker(w http.ResponseWriter, r *http.Request) {
+	script, err := serviceWorker()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(script)
+}
+
+// handleIcon draws the app icon: a white score gauge on the brand indigo,
+// with the artwork inside the maskable safe zone
+func handleIcon(w http.ResponseWriter, r *http.Request) {
+	var size int
+	_, err := fmt.Sscanf(r.PathValue("file"), "icon-%d.png", &size)
+	if err != nil || r.PathValue("file") != fmt.Sprintf("icon-%d.png", size) || !slices.Contains(pwaIconSizes, size) {
+		http.NotFound(w, r)
+		return
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	indigo := color.RGBA{0x4F, 0x46, 0xE5, 0xFF}
+	amber := color.RGBA{0xF5, 0x9E, 0x0B, 0xFF}
+	c := float64(size) / 2
+	outer, inner := float64(size)*0.30, float64(size)*0.22
+	// The needle points at a score of about 35%
+	needle := math.Pi * (1.25 - 1.5*0.35)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := float64(x)+0.5-c, c-(float64(y)+0.5)
+			dist := math.Hypot(dx, dy)
+			angle := math.Atan2(dy, dx)
+			px := indigo
+			switch {
+			// A 270° arc open at the bottom
+			case dist >= inner && dist <= outer && (angle > -math.Pi/4 || angle < -3*math.Pi/4):
+				px = color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+			case dist < inner*0.8 && math.Abs(dx*math.Sin(needle)-dy*math.Cos(needle)) < float64(size)*0.02 &&
+				dx*math.Cos(needle)+dy*math.Sin(needle) > 0:
+				px = amber
+			case dist < float64(size)*0.04:
+				px = amber
+			}
+			img.SetRGBA(x, y, px)
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	png.Encode(w, img)
+}
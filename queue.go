@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const jobQueueSchema = `
+	CREATE TABLE IF NOT EXISTS job_queue (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		payload BLOB NOT NULL,
+		status TEXT NOT NULL DEFAULT 'queued',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL,
+		run_at DATETIME NOT NULL,
+		locked_until DATETIME,
+		last_error TEXT NOT NULL DEFAULT '',
+		result BLOB,
+		created_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);
+	CREATE INDEX IF NOT EXISTS idx_job_queue_due ON job_queue (status, run_at);
+`
+
+// Queued job statuses. A job that used up its attempts is dead: it stays
+// in the table, listed on /admin, until someone retries it.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobDead    = "dead"
+)
+
+const (
+	// jobLease is how long a claimed job may run before another worker
+	// takes it over, assuming the instance running it died
+	jobLease = 10 * time.Minute
+	// jobRetryBase is the wait before the first retry; it doubles with
+	// every further attempt, up to jobRetryMax
+	jobRetryBase = 30 * time.Second
+	jobRetryMax  = time.Hour
+	// jobRetention is how long finished jobs are kept for their result
+	jobRetention = 7 * 24 * time.Hour
+)
+
+// queueKind is one kind of queued job. run gets the payload given to
+// enqueue; what it returns is stored as the job's result.
+type queueKind struct {
+	attempts int
+	run      func(payload json.RawMessage) (any, error)
+}
+
+// jobQueue runs slow work, such as imports and outbound calls, outside
+// the request that asked for it. Jobs live in the database, so they
+// survive a restart, and any instance sharing it may run them; claiming
+// one is a single UPDATE, so no job runs twice at once.
+type jobQueue struct {
+	mu      sync.Mutex
+	kinds   map[string]queueKind
+	running bool
+	stop    chan struct{}
+	// wake lets enqueue start a job without waiting for the next poll
+	wake    chan struct{}
+	workers sync.WaitGroup
+}
+
+var queue = &jobQueue{kinds: map[string]queueKind{}, wake: make(chan struct{}, 1)}
+
+// queueConfig holds the QUEUE_* settings
+type queueConfig struct {
+	Workers int
+	Poll    time.Duration
+}
+
+// loadQueueConfig reads QUEUE_WORKERS (default 2) and QUEUE_POLL (default
+// 5s, how often idle workers look for jobs other instances queued)
+func loadQueueConfig() (queueConfig, error) {
+	cfg := queueConfig{Workers: 2, Poll: 5 * time.Second}
+	if v := os.Getenv("QUEUE_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("queue: QUEUE_WORKERS must be a whole number of at least 1, got %q", v)
+		}
+		cfg.Workers = n
+	}
+	if v := os.Getenv("QUEUE_POLL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("queue: QUEUE_POLL must be a duration such as 5s, got %q", v)
+		}
+		cfg.Poll = d
+	}
+	return cfg, nil
+}
+
+// Register adds a kind of job, tried up to attempts times
+func (q *jobQueue) Register(kind string, attempts int, run func(json.RawMessage) (any, error)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.kinds[kind] = queueKind{attempts: attempts, run: run}
+}
+
+// enqueue stores a job for the workers and returns its ID
+func (q *jobQueue) enqueue(kind string, payload any) (int64, error) {
+	q.mu.Lock()
+	k, ok := q.kinds[kind]
+	q.mu.Unlock()
+	if !ok {
+		return 0, fmt.Errorf("queue: unknown job kind %q", kind)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	res, err := db.Exec(`INSERT INTO job_queue (kind, payload, max_attempts, run_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		kind, data, k.attempts, now, now)
+	if err != nil {
+		return 0, err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return res.LastInsertId()
+}
+
+// Start launches c.Workers workers
+func (q *jobQueue) Start(c queueConfig) {
+	q.mu.Lock()
+	q.running = true
+	stop := make(chan struct{})
+	q.stop = stop
+	q.mu.Unlock()
+
+	for range c.Workers {
+		q.workers.Add(1)
+		go func() {
+			defer q.workers.Done()
+			ticker := time.NewTicker(c.Poll)
+			defer ticker.Stop()
+			for {
+				// Work through whatever is due before waiting again
+				for q.runNext() {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+				}
+				select {
+				case <-ticker.C:
+				case <-q.wake:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Stop lets the workers finish their current job, then waits for them or
+// for ctx to expire. Jobs still queued stay in the database for the next
+// start.
+func (q *jobQueue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if q.running {
+		close(q.stop)
+		q.running = false
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// runNext claims and runs the next due job. It reports false when there
+// was none, claiming failed or maintenance mode has the queue paused.
+func (q *jobQueue) runNext() bool {
+	if maintenance.current().On {
+		return false
+	}
+	now := time.Now().UTC()
+	var id int64
+	var kind string
+	var payload []byte
+	var attempts, maxAttempts int
+	err := db.QueryRow(`
+		UPDATE job_queue SET status = ?, attempts = attempts + 1, locked_until = ?
+		WHERE id = (
+			SELECT id FROM job_queue
+			WHERE (status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)
+			ORDER BY run_at, id LIMIT 1
+		)
+		RETURNING id, kind, payload, attempts, max_attempts`,
+		jobRunning, now.Add(jobLease), jobQueued, now, jobRunning, now).Scan(&id, &kind, &payload, &attempts, &maxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		slog.Error("queue: claiming a job failed", "err", err)
+		return false
+	}
+
+	q.mu.Lock()
+	k, ok := q.kinds[kind]
+	q.mu.Unlock()
+	var result any
+	err = runJob("queue:"+kind, func() error {
+		if !ok {
+			return fmt.Errorf("no worker for job kind %q", kind)
+		}
+		var err error
+		result, err = k.run(payload)
+		return err
+	})
+	q.finish(id, attempts, maxAttempts, result, err)
+	return true
+}
+
+// finish records a job's outcome: done, queued again after a backoff, or
+// dead once its attempts are used up
+func (q *jobQueue) finish(id int64, attempts, maxAttempts int, result any, runErr error) {
+	now := time.Now().UTC()
+	var err error
+	switch {
+	case runErr == nil:
+		var data []byte
+		if data, err = json.Marshal(result); err == nil {
+			_, err = db.Exec(`UPDATE job_queue SET status = ?, result = ?, last_error = '', locked_until = NULL, finished_at = ? WHERE id = ?`,
+				jobDone, data, now, id)
+		}
+	case attempts >= maxAttempts:
+		_, err = db.Exec(`UPDATE job_queue SET status = ?, last_error = ?, locked_until = NULL, finished_at = ? WHERE id = ?`,
+			jobDead, runErr.Error(), now, id)
+		slog.Warn("queue: job moved to the dead letters", "id", id, "attempts", attempts)
+	default:
+		wait := min(jobRetryBase<<(attempts-1), jobRetryMax)
+		_, err = db.Exec(`UPDATE job_queue SET status = ?, last_error = ?, locked_until = NULL, run_at = ? WHERE id = ?`,
+			jobQueued, runErr.Error(), now.Add(wait), id)
+	}
+	if err != nil {
+		slog.Error("queue: recording a job's outcome failed", "id", id, "err", err)
+	}
+}
+
+// QueuedJob is a job as the status endpoint and /admin show it. The
+// payload is left out: it can hold journal text.
+type QueuedJob struct {
+	ID          int64           `json:"id"`
+	Kind        string          `json:"kind"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+const queuedJobColumns = `id, kind, status, attempts, max_attempts, run_at, last_error, result, created_at, finished_at`
+
+func scanQueuedJob(row interface{ Scan(...any) error }) (QueuedJob, error) {
+	var j QueuedJob
+	var result []byte
+	var finished sql.NullTime
+	err := row.Scan(&j.ID, &j.Kind, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.LastError, &result, &j.CreatedAt, &finished)
+	if len(result) > 0 {
+		j.Result = result
+	}
+	if finished.Valid {
+		j.FinishedAt = &finished.Time
+	}
+	return j, err
+}
+
+// loadQueuedJob returns one job, or sql.ErrNoRows
+func loadQueuedJob(id int64) (QueuedJob, error) {
+	return scanQueuedJob(db.QueryRow(`SELECT `+queuedJobColumns+` FROM job_queue WHERE id = ?`, id))
+}
+
+// deadJobs lists the dead letters, newest first
+func deadJobs() ([]QueuedJob, error) {
+	rows, err := db.Query(`SELECT `+queuedJobColumns+` FROM job_queue WHERE status = ? ORDER BY finished_at DESC LIMIT 50`, jobDead)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []QueuedJob{}
+	for rows.Next() {
+		j, err := scanQueuedJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// queueBacklog counts the jobs waiting or running
+func queueBacklog() (int, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM job_queue WHERE status IN (?, ?)`, jobQueued, jobRunning).Scan(&n)
+	return n, err
+}
+
+// retryDeadJob queues a dead job again with a fresh set of attempts. It
+// reports false when there is no dead job with that ID.
+func (q *jobQueue) retryDeadJob(id int64) (bool, error) {
+	res, err := db.Exec(`UPDATE job_queue SET status = ?, attempts = 0, run_at = ?, finished_at = NULL WHERE id = ? AND status = ?`,
+		jobQueued, time.Now().UTC(), id, jobDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if n == 1 {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return n == 1, err
+}
+
+// pruneJobQueue deletes finished jobs past jobRetention; dead ones stay
+// until they are retried
+func pruneJobQueue() error {
+	_, err := db.Exec(`DELETE FROM job_queue WHERE status = ? AND finished_at < ?`, jobDone, time.Now().UTC().Add(-jobRetention))
+	return err
+}
+
+// handleQueuedJob reports a job's progress, e.g. GET /api/queue/12 after
+// an import answered 202 Accepted
+func handleQueuedJob(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	j, err := loadQueuedJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// handleAdminRetryJob queues a dead letter again from the admin page
+func handleAdminRetryJob(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ok, err := queue.retryDeadJob(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "No dead job with that ID", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, "/admin?notice=Job+queued+again", http.StatusSeeOther)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// handleQuick renders /quick, a one-line check-in for people who log every
+// day: the fields in a row, number keys for mood and stress, Enter to save.
+// It posts to /calculate like the main form and gets a one-line result.
+func handleQuick(w http.ResponseWriter, r *http.Request) {
+	prefill, err := loadCheckinDefaults(time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl, _, err := localizedTemplate("quick.html", r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, map[string]any{"Prefill": prefill})
+}
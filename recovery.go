@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Recovery thresholds: an episode starts on a Severe Burnout day and ends on
+// the first later day back below At Risk (i.e. Healthy)
+const (
+	severeThreshold    = 80.0
+	recoveredThreshold = 30.0
+)
+
+// RecoveryEpisode is one severe episode and how long recovery took
+type RecoveryEpisode struct {
+	Start         string  `json:"start"`
+	RecoveredOn   string  `json:"recovered_on,omitempty"`
+	DaysToRecover *int    `json:"days_to_recover"`
+	PeakScore     float64 `json:"peak_score"`
+}
+
+// RecoveryReport is the payload of /api/insights/recovery
+type RecoveryReport struct {
+	Episodes    []RecoveryEpisode `json:"episodes"`
+	AverageDays *float64          `json:"average_days"`
+	Trend       string            `json:"trend"`
+	Summary     string            `json:"summary"`
+}
+
+// findRecoveryEpisodes scans daily averages for severe days and measures the
+// calendar days until the next healthy day
+func findRecoveryEpisodes(entries []BurnoutEntry) []RecoveryEpisode {
+	episodes := []RecoveryEpisode{}
+	var current *RecoveryEpisode
+	var startDay time.Time
+
+	for _, d := range bucketEntries(entries, granularityDay) {
+		avg := averageOf(d.Entries, entryScore)
+		if current == nil {
+			if avg > severeThreshold {
+				current = &RecoveryEpisode{Start: d.Start.Format("2006-01-02"), PeakScore: roundTo(avg, 1)}
+				startDay = d.Start
+			}
+			continue
+		}
+		if avg > current.PeakScore {
+			current.PeakScore = roundTo(avg, 1)
+		}
+		if avg <= recoveredThreshold {
+			days := int(d.Start.Sub(startDay).Hours() / 24)
+			current.RecoveredOn = d.Start.Format("2006-01-02")
+			current.DaysToRecover = &days
+			episodes = append(episodes, *current)
+			current = nil
+		}
+	}
+	if current != nil {
+		episodes = append(episodes, *current)
+	}
+	return episodes
+}
+
+// buildRecoveryReport summarises recovery times and whether they are shrinking
+func buildRecoveryReport(entries []BurnoutEntry) RecoveryReport {
+	report := RecoveryReport{Episodes: findRecoveryEpisodes(entries), Trend: "unknown"}
+
+	var xs, ys []float64
+	for i, ep := range report.Episodes {
+		if ep.DaysToRecover != nil {
+			xs = append(xs, float64(i))
+			ys = append(ys, float64(*ep.DaysToRecover))
+		}
+	}
+
+	if len(ys) > 0 {
+		var sum float64
+		for _, y := range ys {
+			sum += y
+		}
+		avg := roundTo(sum/float64(len(ys)), 1)
+		report.AverageDays = &avg
+	}
+
+	if slope, _, ok := linearRegression(xs, ys); ok && len(ys) >= 3 {
+		switch {
+		case slope <= -0.5:
+			report.Trend = "faster"
+		case slope >= 0.5:
+			report.Trend = "slower"
+		default:
+			report.Trend = "steady"
+		}
+	}
+
+	switch {
+	case len(report.Episodes) == 0:
+		report.Summary = "No severe burnout episodes recorded."
+	case report.AverageDays == nil:
+		report.Summary = "You're still recovering from your first severe episode."
+	case report.Trend == "faster":
+		report.Summary = fmt.Sprintf("You're bouncing back faster: %.1f days on average, and shrinking.", *report.AverageDays)
+	case report.Trend == "slower":
+		report.Summary = fmt.Sprintf("Recovery is taking longer lately (%.1f days on average).", *report.AverageDays)
+	default:
+		report.Summary = fmt.Sprintf("You take %.1f days on average to get back to healthy.", *report.AverageDays)
+	}
+	return report
+}
+
+// handleRecovery reports time-to-recovery after severe episodes
+func handleRecovery(w http.ResponseWriter, r *http.Request) {
+	entries, err := queryEntries(time.Time{}, time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, buildRecoveryReport(entries))
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const monthlyReportsSchema = `
+	CREATE TABLE IF NOT EXISTS monthly_reports (
+		month TEXT PRIMARY KEY,
+		generated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		data TEXT NOT NULL
+	);
+`
+
+// WeekAverage is one point of the monthly trend
+type WeekAverage struct {
+	WeekStart string  `json:"week_start"`
+	AvgScore  float64 `json:"avg_score"`
+	Entries   int     `json:"entries"`
+}
+
+// MonthlyReport aggregates a calendar month. Reports are stored as JSON
+// snapshots so they stay readable after the raw entries are gone.
+type MonthlyReport struct {
+	Month           string        `json:"month"`
+	GeneratedAt     time.Time     `json:"generated_at"`
+	Stats           PeriodStats   `json:"stats"`
+	Weeks           []WeekAverage `json:"weeks"`
+	Trend           string        `json:"trend"`
+	BestDay         *DaySummary   `json:"best_day,omitempty"`
+	WorstDay        *DaySummary   `json:"worst_day,omitempty"`
+	Milestones      []string      `json:"milestones"`
+	Recommendations []string      `json:"recommendations"`
+}
+
+// buildMonthlyReport aggregates the entries of the month starting at start
+func buildMonthlyReport(start time.Time) (MonthlyReport, error) {
+	end := start.AddDate(0, 1, 0)
+	entries, err := queryEntries(start, end)
+	if err != nil {
+		return MonthlyReport{}, err
+	}
+
+	report := MonthlyReport{
+		Month:           start.Format("2006-01"),
+		GeneratedAt:     time.Now().UTC(),
+		Stats:           summarize(entries),
+		Weeks:           []WeekAverage{},
+		Milestones:      []string{},
+		Recommendations: []string{},
+	}
+	for _, b := range bucketEntries(entries, granularityWeek) {
+		report.Weeks = append(report.Weeks, WeekAverage{
+			WeekStart: b.Start.Format("2006-01-02"),
+			AvgScore:  roundTo(averageOf(b.Entries, entryScore), 1),
+			Entries:   len(b.Entries),
+		})
+	}
+	report.BestDay, report.WorstDay = bestAndWorstDays(entries)
+	report.Trend = monthlyTrend(entries)
+	report.Milestones = monthlyMilestones(entries, end)
+	report.Recommendations = monthlyRecommendations(report.Stats)
+	return report, nil
+}
+
+// monthlyTrend describes the direction of the month from a regression fit
+func monthlyTrend(entries []BurnoutEntry) string {
+	if len(entries) < 2 {
+		return "unknown"
+	}
+	var points []scorePoint
+	for _, e := range entries {
+		points = append(points, scorePoint{At: e.CreatedAt, Score: e.Score})
+	}
+	xs, ys := daysSince(points[0].At, points)
+	slope, _, ok := linearRegression(xs, ys)
+	if !ok {
+		return "unknown"
+	}
+	switch change := slope * 30; {
+	case change <= -trendThreshold:
+		return "improving"
+	case change >= trendThreshold:
+		return "worsening"
+	}
+	return "stable"
+}
+
+// monthlyMilestones lists the achievements worth celebrating in the month
+func monthlyMilestones(entries []BurnoutEntry, end time.Time) []string {
+	milestones := []string{}
+	if len(entries) == 0 {
+		return milestones
+	}
+
+	days := bucketEntries(entries, granularityDay)
+	milestones = append(milestones, fmt.Sprintf("Checked in on %d day(s)", len(days)))
+
+	streaks := computeStreaks(entries, end)
+	if streaks.LongestCheckIn >= 3 {
+		milestones = append(milestones, fmt.Sprintf("Longest check-in streak: %d days", streaks.LongestCheckIn))
+	}
+
+	healthy := 0
+	for _, d := range days {
+		if scoreBucket(averageOf(d.Entries, entryScore)) == heatmapHealthy {
+			healthy++
+		}
+	}
+	if healthy > 0 {
+		milestones = append(milestones, fmt.Sprintf("%d healthy day(s)", healthy))
+	}
+
+	exercised := 0
+	for _, d := range days {
+		if averageOf(d.Entries, entryExercise) > 0 {
+			exercised++
+		}
+	}
+	if exercised > 0 {
+		milestones = append(milestones, fmt.Sprintf("Exercised on %d day(s)", exercised))
+	}
+	return milestones
+}
+
+// monthlyRecommendations derives next-month suggestions from the averages
+func monthlyRecommendations(stats PeriodStats) []string {
+	recs := []string{}
+	if stats.Entries == 0 {
+		return append(recs, "Check in a few times a week so next month's report has something to show.")
+	}
+	if stats.AvgSleep < 7 {
+		recs = append(recs, fmt.Sprintf("You averaged %.1fh of sleep. Aim for at least 7h on most nights.", stats.AvgSleep))
+	}
+	if stats.AvgStress > 3 {
+		recs = append(recs, "Stress stayed high. Schedule short breaks (e.g. Pomodoro 25/5) on heavy days.")
+	}
+	if stats.AvgStudy > 6 {
+		recs = append(recs, fmt.Sprintf("You studied %.1fh a day on average. Protect at least one lighter day per week.", stats.AvgStudy))
+	}
+	if currentScoring().level(stats.MaxScore) == levelSevere {
+		recs = append(recs, "You hit severe burnout at least once. Plan recovery time around your next big deadline.")
+	}
+	if len(recs) == 0 {
+		recs = append(recs, "Keep your current routine — it's working.")
+	}
+	return recs
+}
+
+// loadMonthlyReport returns the stored snapshot for month, if any
+func loadMonthlyReport(month string) (*MonthlyReport, error) {
+	var data string
+	err := db.QueryRow(`SELECT data FROM monthly_reports WHERE month = ?`, month).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var report MonthlyReport
+	if err := json.Unmarshal([]byte(data), &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// saveMonthlyReport stores (or replaces) the snapshot for report.Month
+func saveMonthlyReport(report MonthlyReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO monthly_reports (month, generated_at, data) VALUES (?, ?, ?)
+		ON CONFLICT(month) DO UPDATE SET generated_at = excluded.generated_at, data = excluded.data`,
+		report.Month, report.GeneratedAt, string(data))
+	return err
+}
+
+// handleMonthlyReport serves a month's report. GET returns the stored snapshot
+// when there is one, otherwise builds it (and stores it once the month has
+// ended); POST always regenerates and stores a fresh snapshot.
+func handleMonthlyReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now().In(loadZone())
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	if v := r.URL.Query().Get("month"); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, loadZone())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid month %q (use YYYY-MM)", v), http.StatusBadRequest)
+			return
+		}
+		start = t
+	}
+
+	var report *MonthlyReport
+	if r.Method == "GET" {
+		stored, err := loadMonthlyReport(start.Format("2006-01"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report = stored
+	}
+
+	if report == nil {
+		built, err := buildMonthlyReport(start)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Method == "POST" || !start.AddDate(0, 1, 0).After(now) {
+			if err := saveMonthlyReport(built); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		report = &built
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
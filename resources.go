@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// crisisResource is one hotline or service on the /resources page
+type crisisResource struct {
+	Name        string `json:"name"`
+	Phone       string `json:"phone,omitempty"`
+	Text        string `json:"text,omitempty"` // SMS or chat instructions
+	URL         string `json:"url,omitempty"`
+	Hours       string `json:"hours,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// TelURL is the tel: link for Phone, keeping only what a dialer needs. An
+// extension ("119 ext. 8") is dialed after a pause.
+func (c crisisResource) TelURL() template.URL {
+	number, ext, _ := strings.Cut(strings.ToLower(c.Phone), "ext")
+	dial := func(s string) string {
+		var digits strings.Builder
+		for _, r := range s {
+			if r >= '0' && r <= '9' || r == '+' {
+				digits.WriteRune(r)
+			}
+		}
+		return digits.String()
+	}
+	tel := "tel:" + dial(number)
+	if ext := dial(ext); ext != "" {
+		tel += "," + ext
+	}
+	// Only digits, "+" and "," remain, so the link is safe to mark trusted
+	return template.URL(tel)
+}
+
+// resourceRegion groups the resources for one country or campus
+type resourceRegion struct {
+	Code      string           `json:"code"`
+	Name      string           `json:"name"`
+	Resources []crisisResource `json:"resources"`
+}
+
+// crisisDirectory is what a deployment offers on /resources
+type crisisDirectory struct {
+	// Default is the region shown first, normally the deployment's own
+	Default string           `json:"default"`
+	Regions []resourceRegion `json:"regions"`
+}
+
+// defaultCrisisDirectory is used when RESOURCES_FILE is not set. It only
+// lists long-standing national lines and a worldwide directory; campuses
+// should configure their own counseling services.
+var defaultCrisisDirectory = crisisDirectory{
+	Default: "INTL",
+	Regions: []resourceRegion{
+		{Code: "INTL", Name: "Anywhere", Resources: []crisisResource{
+			{Name: "Emergency services", Description: "If you or someone else is in immediate danger, call your local emergency number."},
+			{Name: "Find A Helpline", URL: "https://findahelpline.com", Description: "Free, confidential helplines searchable by country and topic."},
+		}},
+		{Code: "ID", Name: "Indonesia", Resources: []crisisResource{
+			{Name: "Emergency", Phone: "112"},
+			{Name: "SEJIWA mental health line (Ministry of Health)", Phone: "119 ext. 8", Hours: "24/7"},
+		}},
+		{Code: "US", Name: "United States", Resources: []crisisResource{
+			{Name: "988 Suicide & Crisis Lifeline", Phone: "988", Text: "Text 988", URL: "https://988lifeline.org", Hours: "24/7"},
+		}},
+		{Code: "GB", Name: "United Kingdom", Resources: []crisisResource{
+			{Name: "Samaritans", Phone: "116 123", URL: "https://www.samaritans.org", Hours: "24/7"},
+		}},
+	},
+}
+
+// crisis is the running instance's directory, set at startup
+var crisis = defaultCrisisDirectory
+
+// loadCrisisDirectory reads the deployment's resources:
+//
+//	RESOURCES_FILE    JSON file in the crisisDirectory layout, replacing
+//	                  the built-in list
+//	RESOURCES_REGION  region code shown first, overriding the file's default
+func loadCrisisDirectory() (crisisDirectory, error) {
+	d := defaultCrisisDirectory
+	if file := os.Getenv("RESOURCES_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return d, fmt.Errorf("RESOURCES_FILE: %w", err)
+		}
+		d = crisisDirectory{}
+		if err := json.Unmarshal(data, &d); err != nil {
+			return d, fmt.Errorf("RESOURCES_FILE: %w", err)
+		}
+		if len(d.Regions) == 0 {
+			return d, fmt.Errorf("RESOURCES_FILE: %s lists no regions", file)
+		}
+		for _, region := range d.Regions {
+			if region.Code == "" || len(region.Resources) == 0 {
+				return d, fmt.Errorf("RESOURCES_FILE: every region needs a code and at least one resource")
+			}
+		}
+	}
+	if code := os.Getenv("RESOURCES_REGION"); code != "" {
+		d.Default = code
+	}
+	if d.Default == "" {
+		d.Default = d.Regions[0].Code
+	}
+	if d.region(d.Default) == nil {
+		return d, fmt.Errorf("resources: no region %q", d.Default)
+	}
+	return d, nil
+}
+
+// region finds a region by code, ignoring case
+func (d crisisDirectory) region(code string) *resourceRegion {
+	for i := range d.Regions {
+		if strings.EqualFold(d.Regions[i].Code, code) {
+			return &d.Regions[i]
+		}
+	}
+	return nil
+}
+
+// handleResources renders /resources for ?region, or the default region
+func handleResources(w http.ResponseWriter, r *http.Request) {
+	region := crisis.region(r.URL.Query().Get("region"))
+	if region == nil {
+		region = crisis.region(crisis.Default)
+	}
+	tmpl, err := loadTemplate("resources.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, map[string]any{"Region": region, "Regions": crisis.Regions})
+}
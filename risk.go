@@ -0,0 +1,135 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"time"
+)
+
+const riskIndexSchema = `
+	CREATE TABLE IF NOT EXISTS risk_index (
+		date TEXT PRIMARY KEY,
+		value REAL NOT NULL,
+		days_used INTEGER NOT NULL,
+		computed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// riskIndexDays is how many days of history feed the rolling risk index
+const riskIndexDays = 14
+
+// riskHalfLifeDays controls how quickly older days lose weight
+const riskHalfLifeDays = 7.0
+
+// riskSustainedBonus is added in proportion to the share of logged days that
+// were High Risk or worse, so a run of bad days outweighs a single spike
+const riskSustainedBonus = 15.0
+
+// RiskIndex is the rolling risk value for one day
+type RiskIndex struct {
+	Date     string  `json:"date"`
+	Value    float64 `json:"value"`
+	DaysUsed int     `json:"days_used"`
+}
+
+// computeRiskIndex blends the daily averages of the riskIndexDays days ending
+// on day (inclusive) into one 0-100 value. Recent days weigh more, and
+// sustained high-risk days add a bonus on top of the weighted mean.
+func computeRiskIndex(day time.Time) (RiskIndex, bool, error) {
+	end := bucketStart(day, granularityDay).AddDate(0, 0, 1)
+	start := end.AddDate(0, 0, -riskIndexDays)
+	entries, err := queryEntries(start, end)
+	if err != nil {
+		return RiskIndex{}, false, err
+	}
+
+	days := bucketEntries(entries, granularityDay)
+	if len(days) == 0 {
+		return RiskIndex{}, false, nil
+	}
+
+	var weighted, weights float64
+	high := 0
+	for _, d := range days {
+		avg := averageOf(d.Entries, entryScore)
+		age := end.Sub(d.Start).Hours()/24 - 1
+		w := math.Pow(0.5, age/riskHalfLifeDays)
+		weighted += avg * w
+		weights += w
+		if currentScoring().level(avg) >= levelHighRisk {
+			high++
+		}
+	}
+
+	value := weighted/weights + riskSustainedBonus*float64(high)/float64(len(days))
+	return RiskIndex{
+		Date:     end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Value:    roundTo(math.Max(0, math.Min(100, value)), 1),
+		DaysUsed: len(days),
+	}, true, nil
+}
+
+// storeRiskIndex computes and persists the index for day
+func storeRiskIndex(day time.Time) error {
+	idx, ok, err := computeRiskIndex(day)
+	if err != nil || !ok {
+		return err
+	}
+	_, err = db.Exec(`
+		INSERT INTO risk_index (date, value, days_used, computed_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(date) DO UPDATE SET value = excluded.value, days_used = excluded.days_used, computed_at = excluded.computed_at`,
+		idx.Date, idx.Value, idx.DaysUsed, time.Now().UTC())
+	return err
+}
+
+// runNightlyRiskIndex stores the index for the day that just ended
+func runNightlyRiskIndex() error {
+	return storeRiskIndex(time.Now().UTC().AddDate(0, 0, -1))
+}
+
+// handleRiskChart returns the stored nightly risk index series
+func handleRiskChart(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultAggregateDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	since := dayKey(time.Now().AddDate(0, 0, -days))
+	rows, err := db.Query(`SELECT date, value FROM risk_index WHERE date >= ? ORDER BY date ASC`, since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var dates []string
+	chart := ChartData{Labels: []string{}, Data: []float64{}}
+	for rows.Next() {
+		var date string
+		var value float64
+		if err := rows.Scan(&date, &value); err != nil {
+			rows.Close()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		dates = append(dates, date)
+		chart.Data = append(chart.Data, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Labelling reads the time zone setting, so it waits until the rows
+	// above have given their connection back
+	loc := requestLocalizer(r)
+	for _, date := range dates {
+		label := date
+		if t, err := parseDay(date); err == nil {
+			label = chartLabel(loc, t, granularityDay)
+		}
+		chart.Labels = append(chart.Labels, label)
+	}
+	chart.Datasets = []ChartDataset{{Label: "Risk Index", Data: chart.Data}}
+	writeJSON(w, http.StatusOK, chart)
+}
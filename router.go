@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// middleware wraps a handler, e.g. to check a token or add headers
+type middleware func(http.Handler) http.Handler
+
+// chain wraps h in mws, the first one outermost
+func chain(h http.Handler, mws ...middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// router registers routes on a ServeMux, which already matches methods
+// ("POST /admin/logout") and path parameters ("/entries/{id}"), and adds
+// middleware per group of routes. A route whose pattern names a method
+// gets 405 Method Not Allowed, with an Allow header, for any other.
+type router struct {
+	mux *http.ServeMux
+	mws []middleware
+}
+
+func newRouter() *router {
+	return &router{mux: http.NewServeMux()}
+}
+
+// With returns a group that registers on the same mux, wrapping each of
+// its routes in mws after the middleware the group already has
+func (rt *router) With(mws ...middleware) *router {
+	return &router{mux: rt.mux, mws: append(append([]middleware{}, rt.mws...), mws...)}
+}
+
+func (rt *router) Handle(pattern string, h http.Handler) {
+	rt.mux.Handle(pattern, chain(h, rt.mws...))
+}
+
+func (rt *router) HandleFunc(pattern string, h http.HandlerFunc) {
+	rt.Handle(pattern, h)
+}
+
+// ServeHTTP routes r, and hands the pattern that matched to the
+// middleware outside the mux (see recordRoute)
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m, ok := r.Context().Value(matchedRouteKey{}).(*matchedRoute); ok {
+		defer func() { m.pattern = r.Pattern }() // also after a panic
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// matchedRoute carries the pattern the mux matched back out. ServeMux sets
+// Request.Pattern only on the request it was given, and every middleware
+// that adds to the context passes a copy inward, so the ones outside can't
+// see it on their own request.
+type matchedRoute struct{ pattern string }
+
+type matchedRouteKey struct{}
+
+// recordRoute gives the request a matchedRoute for the router to fill in.
+// It goes outermost, so every middleware shares the same one.
+func recordRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), matchedRouteKey{}, &matchedRoute{})))
+	})
+}
+
+// routeOf is the pattern that matched r without its method, so
+// "GET /entries/{id}" and "/entries/{id}" share a label in logs and
+// metrics; "unmatched" when no route did
+func routeOf(r *http.Request) string {
+	pattern := r.Pattern
+	if m, ok := r.Context().Value(matchedRouteKey{}).(*matchedRoute); ok && pattern == "" {
+		pattern = m.pattern
+	}
+	if pattern == "" {
+		return "unmatched"
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
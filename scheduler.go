@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// schedulerTick is how often the scheduler checks for due jobs
+const schedulerTick = time.Minute
+
+// scheduledJob is a background task run by the scheduler
+type scheduledJob struct {
+	name string
+	// next returns the first run time strictly after t
+	next func(t time.Time) time.Time
+	run  func() error
+
+	due     time.Time
+	lastRun time.Time
+	lastErr error
+	busy    bool
+}
+
+// JobStatus is a read-only view of a job for the admin page
+type JobStatus struct {
+	Name      string    `json:"name"`
+	Next      time.Time `json:"next"`
+	LastRun   time.Time `json:"last_run"`
+	LastError string    `json:"last_error,omitempty"`
+	Running   bool      `json:"running"`
+}
+
+// daily returns a schedule firing once a day at hh:mm UTC
+func daily(hour, minute int) func(time.Time) time.Time {
+	return func(t time.Time) time.Time {
+		t = t.UTC()
+		at := time.Date(t.Year(), t.Month(), t.Day(), hour, minute, 0, 0, time.UTC)
+		if !at.After(t) {
+			at = at.AddDate(0, 0, 1)
+		}
+		return at
+	}
+}
+
+// every returns a schedule firing at a fixed interval, aligned to the
+// Unix epoch so that every instance arrives at the same run times
+func every(d time.Duration) func(time.Time) time.Time {
+	return func(t time.Time) time.Time { return t.Truncate(d).Add(d) }
+}
+
+// Scheduler runs registered jobs on their schedules in a single goroutine
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    []*scheduledJob
+	running bool
+	stop    chan struct{}
+	// inFlight counts jobs running right now, so Stop can wait for them
+	inFlight sync.WaitGroup
+}
+
+var scheduler = &Scheduler{}
+
+// Register adds a job; it first runs at its next scheduled time
+func (s *Scheduler) Register(name string, next func(time.Time) time.Time, run func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, &scheduledJob{name: name, next: next, run: run, due: next(time.Now())})
+}
+
+// Start launches the scheduler loop
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	s.running = true
+	stop := make(chan struct{})
+	s.stop = stop
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(schedulerTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				s.runDue(now)
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the loop so no new job starts, then waits for the running
+// ones to finish or for ctx to expire
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		close(s.stop)
+		s.running = false
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running reports whether the scheduler is started and not stopped
+func (s *Scheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// runDue runs every job whose due time has passed. In maintenance mode
+// nothing runs, and jobs that came due run once it is over.
+func (s *Scheduler) runDue(now time.Time) {
+	if maintenance.current().On {
+		return
+	}
+	s.mu.Lock()
+	var due []*scheduledJob
+	var slots []time.Time
+	for _, j := range s.jobs {
+		if !now.Before(j.due) {
+			slot := j.due
+			j.due = j.next(now)
+			if !j.busy && s.running {
+				j.busy = true
+				s.inFlight.Add(1)
+				due = append(due, j)
+				slots = append(slots, slot)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	for i, j := range due {
+		if !s.claim(j, slots[i]) {
+			continue
+		}
+		s.run(j)
+	}
+}
+
+// claim asks the shared state whether this instance runs j for slot, its
+// scheduled time, so two instances behind a load balancer don't both send
+// the same reminders. A job another instance took is released unrun.
+func (s *Scheduler) claim(j *scheduledJob, slot time.Time) bool {
+	ok, err := state.Claim(j.name, slot)
+	if err != nil {
+		slog.Error("job claim failed", "job", j.name, "err", err)
+	}
+	if ok && err == nil {
+		return true
+	}
+	if err == nil {
+		slog.Debug("job claimed elsewhere", "job", j.name, "slot", slot)
+	}
+	s.mu.Lock()
+	j.busy = false
+	s.mu.Unlock()
+	s.inFlight.Done()
+	return false
+}
+
+// Jobs lists the registered jobs in registration order
+func (s *Scheduler) Jobs() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make([]JobStatus, len(s.jobs))
+	for i, j := range s.jobs {
+		jobs[i] = JobStatus{Name: j.name, Next: j.due, LastRun: j.lastRun, Running: j.busy}
+		if j.lastErr != nil {
+			jobs[i].LastError = j.lastErr.Error()
+		}
+	}
+	return jobs
+}
+
+// RunNow starts the named job in the background without moving its next
+// scheduled run. It reports false when there is no such job, it is
+// already running or the scheduler is stopped.
+func (s *Scheduler) RunNow(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name && !j.busy && s.running {
+			j.busy = true
+			s.inFlight.Add(1)
+			go s.run(j)
+			return true
+		}
+	}
+	return false
+}
+
+// run runs a job the caller has marked busy and counted in inFlight, and
+// records the outcome, so a job is never run twice at once
+func (s *Scheduler) run(j *scheduledJob) {
+	defer s.inFlight.Done()
+	err := runJob(j.name, j.run)
+
+	s.mu.Lock()
+	j.busy, j.lastRun, j.lastErr = false, time.Now(), err
+	s.mu.Unlock()
+}
+
+// runJob runs a job, logging its failure instead of stopping the scheduler.
+// A panicking job counts as failed rather than taking the process down.
+func runJob(name string, run func() error) (err error) {
+	start := time.Now()
+	_, span := tracer.Start(context.Background(), "job "+name)
+	defer func() { endSpan(span, err) }()
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				slog.Error("job panicked", "job", name, "panic", fmt.Sprint(v), "stack", string(debug.Stack()))
+				reportJobPanic(name, v)
+				err = fmt.Errorf("panic: %v", v)
+			}
+		}()
+		err = run()
+	}()
+	metrics.count(metrics.jobs, name, result(err))
+	metrics.time(metrics.jobTimes, time.Since(start), name)
+	if err != nil {
+		slog.Error("job failed", "job", name, "err", err)
+		return err
+	}
+	slog.Info("job finished", "job", name, "duration", time.Since(start).Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// scoringConfig holds the weights of the burnout formula and the level
+// thresholds. The defaults are the formula the app has always used; a
+// deployment can tune them in the [scoring] and [thresholds] sections of
+// its config file.
+type scoringConfig struct {
+	DeadlineWeight float64 // points per deadline this week
+	StressWeight   float64 // points per stress point (1-5)
+	SleepTarget    float64 // hours of sleep that add nothing
+	SleepWeight    float64 // points per hour below the target
+	StudyWeight    float64 // points per study hour
+	// ExerciseRecovery and HabitRecoveryBonus are taken off the score for
+	// exercising and for completing any recovery habit (e.g. "meditate",
+	// "walk outside") on the day of the check-in
+	ExerciseRecovery   float64
+	HabitRecoveryBonus float64
+
+	// Scores up to HealthyMax are healthy, up to AtRiskMax at risk, up to
+	// HighRiskMax high risk and anything above severe
+	HealthyMax  float64
+	AtRiskMax   float64
+	HighRiskMax float64
+}
+
+var defaultScoring = scoringConfig{
+	DeadlineWeight:     10,
+	StressWeight:       12,
+	SleepTarget:        8,
+	SleepWeight:        8,
+	StudyWeight:        3,
+	ExerciseRecovery:   10,
+	HabitRecoveryBonus: 5,
+	HealthyMax:         30,
+	AtRiskMax:          60,
+	HighRiskMax:        80,
+}
+
+// configuredScoring is the formula from the config file, set at startup.
+// A formula saved on /admin replaces it; currentScoring returns whichever
+// is in force.
+var configuredScoring = defaultScoring
+
+// scoringKey is the settings row holding the formula saved on /admin, as
+// JSON
+const scoringKey = "scoring"
+
+// formulaCache holds the formula in force until the next write, so every
+// instance picks up a change made through any of them
+var formulaCache struct {
+	sync.Mutex
+	generation uint64
+	loaded     bool
+	formula    scoringConfig
+	overridden bool
+}
+
+// currentScoring returns the formula in force. While the database can't be
+// read it keeps the last known one.
+func currentScoring() scoringConfig {
+	s, _ := loadScoring()
+	return s
+}
+
+// loadScoring returns the formula in force and whether it was saved on
+// /admin rather than configured
+func loadScoring() (scoringConfig, bool) {
+	generation := state.Generation()
+	formulaCache.Lock()
+	defer formulaCache.Unlock()
+	if formulaCache.loaded && formulaCache.generation == generation {
+		return formulaCache.formula, formulaCache.overridden
+	}
+	formula, overridden := configuredScoring, false
+	var saved string
+	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, scoringKey).Scan(&saved)
+	switch {
+	case err == nil:
+		var s scoringConfig
+		if json.Unmarshal([]byte(saved), &s) == nil && s.validate() == nil {
+			formula, overridden = s, true
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		if formulaCache.loaded {
+			return formulaCache.formula, formulaCache.overridden
+		}
+		return configuredScoring, false
+	}
+	formulaCache.formula, formulaCache.overridden = formula, overridden
+	formulaCache.generation, formulaCache.loaded = generation, true
+	return formula, overridden
+}
+
+// saveScoring stores a formula edited on /admin; nil goes back to the
+// configured one. Scores already recorded keep the values they were saved
+// with.
+func saveScoring(s *scoringConfig) error {
+	if s == nil {
+		_, err := db.Exec(`DELETE FROM settings WHERE key = ?`, scoringKey)
+		return err
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`, scoringKey, string(value), time.Now().UTC())
+	return err
+}
+
+// Levels in order of severity, as returned by scoringConfig.level
+const (
+	levelHealthy = iota
+	levelAtRisk
+	levelHighRisk
+	levelSevere
+)
+
+// level places a score in one of the four levels
+func (s scoringConfig) level(score float64) int {
+	switch {
+	case score <= s.HealthyMax:
+		return levelHealthy
+	case score <= s.AtRiskMax:
+		return levelAtRisk
+	case score <= s.HighRiskMax:
+		return levelHighRisk
+	}
+	return levelSevere
+}
+
+// bounds are the score ranges of the four levels, lowest first
+func (s scoringConfig) bounds() [4][2]float64 {
+	return [4][2]float64{{0, s.HealthyMax}, {s.HealthyMax, s.AtRiskMax}, {s.AtRiskMax, s.HighRiskMax}, {s.HighRiskMax, 100}}
+}
+
+// validate rejects weights and thresholds that would make the levels
+// meaningless
+func (s scoringConfig) validate() error {
+	for name, v := range map[string]float64{
+		"deadline_weight": s.DeadlineWeight, "stress_weight": s.StressWeight, "sleep_target": s.SleepTarget,
+		"sleep_weight": s.SleepWeight, "study_weight": s.StudyWeight,
+		"exercise_recovery": s.ExerciseRecovery, "habit_recovery_bonus": s.HabitRecoveryBonus,
+	} {
+		if v < 0 {
+			return fmt.Errorf("scoring: %s must not be negative", name)
+		}
+	}
+	if !(0 < s.HealthyMax && s.HealthyMax < s.AtRiskMax && s.AtRiskMax < s.HighRiskMax && s.HighRiskMax < 100) {
+		return fmt.Errorf("thresholds: need 0 < healthy_max < at_risk_max < high_risk_max < 100")
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// hstsMaxAge is how long, in seconds, browsers should insist on HTTPS
+// after seeing it once: a year. Subdomains are left out, as they may be
+// other services on the institution's domain.
+const hstsMaxAge = "31536000"
+
+// contentSecurityPolicy is computed once from the embedded assets. Scripts
+// come only from this origin, so an injected <script> or onclick= does
+// nothing; the pages keep their behaviour in static/js for that reason.
+// Styles still allow inline, which Tailwind's runtime, the htmx indicator
+// styles and style= attributes need. The CDN a library falls back to is
+// allowed only while that library isn't vendored (see `make
+// vendor-assets`).
+var contentSecurityPolicy = buildContentSecurityPolicy()
+
+func buildContentSecurityPolicy() string {
+	script := []string{"'self'"}
+	style := []string{"'self'", "'unsafe-inline'"}
+	font := []string{"'self'"}
+	for _, v := range []struct{ asset, fallback string }{
+		{"vendor/tailwind.js", "https://cdn.tailwindcss.com"},
+		{"vendor/htmx.min.js", "https://unpkg.com/htmx.org@1.9.10"},
+		{"vendor/chart.umd.min.js", "https://cdn.jsdelivr.net/npm/chart.js"},
+	} {
+		if _, ok := staticAssets[v.asset]; !ok {
+			script = append(script, cspOrigin(v.fallback))
+		}
+	}
+	if _, ok := staticAssets["vendor/inter.css"]; !ok {
+		style = append(style, "https://fonts.googleapis.com")
+		font = append(font, "https://fonts.gstatic.com")
+	}
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src " + strings.Join(script, " "),
+		"style-src " + strings.Join(style, " "),
+		"font-src " + strings.Join(font, " "),
+		"img-src 'self' data: blob:",
+		"connect-src 'self'",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// cspOrigin is the scheme and host of a URL, as a CSP source
+func cspOrigin(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// securityHeaders sends the headers that keep pages with health data from
+// being framed, sniffed or leaked through a Referer to other sites, and
+// HSTS on HTTPS connections
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Security-Policy", contentSecurityPolicy)
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("X-Content-Type-Options", "nosniff")
+		// share links carry their token in the path, so other sites get
+		// no Referer at all
+		h.Set("Referrer-Policy", "same-origin")
+		if r.TLS != nil {
+			h.Set("Strict-Transport-Security", "max-age="+hstsMaxAge)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
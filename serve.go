@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout is how long in-flight requests and background jobs get
+// to finish after SIGINT or SIGTERM before the server gives up on them
+const shutdownTimeout = 15 * time.Second
+
+// serve runs srv, over HTTPS when c configures it, until SIGINT or
+// SIGTERM. It then shuts down in order: stop accepting connections and drain
+// requests, then let running and queued jobs finish. It returns nil after a clean
+// shutdown, so main's deferred cleanup (closing the database, removing the
+// demo copy) still runs.
+func serve(srv *http.Server, c serverConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ln, where, err := listen(c)
+	if err != nil {
+		return err
+	}
+	slog.Info("listening", "on", where)
+
+	errs := make(chan error, 2)
+	var redirect *http.Server
+	switch c.tlsMode() {
+	case "files":
+		redirect = prepareTLS(srv, c)
+		go func() { errs <- srv.ServeTLS(ln, c.TLSCert, c.TLSKey) }()
+	case "acme":
+		redirect = prepareTLS(srv, c)
+		go func() { errs <- srv.ServeTLS(ln, "", "") }()
+	default:
+		go func() { errs <- srv.Serve(ln) }()
+	}
+	if redirect != nil {
+		go func() { errs <- redirect.ListenAndServe() }()
+	}
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	stop() // a second signal kills the process straight away
+	slog.Info("shutting down: draining requests", "timeout", shutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if redirect != nil {
+		redirect.Shutdown(shutdownCtx)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("shutdown: requests still running", "err", err)
+	}
+	if err := scheduler.Stop(shutdownCtx); err != nil {
+		slog.Warn("shutdown: background jobs still running", "err", err)
+	}
+	if err := queue.Stop(shutdownCtx); err != nil {
+		slog.Warn("shutdown: queued jobs still running", "err", err)
+	}
+	if err := stopTracing(shutdownCtx); err != nil {
+		slog.Warn("shutdown: spans not sent", "err", err)
+	}
+	if err := errorReports.Stop(shutdownCtx); err != nil {
+		slog.Warn("shutdown: error reports not sent", "err", err)
+	}
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("shutdown complete")
+	return nil
+}
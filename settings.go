@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const settingsSchema = `
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	-- Units and integration credentials were settings once; nothing read
+	-- them, and the credentials were kept in plain text
+	DELETE FROM settings WHERE key = 'units' OR key LIKE 'integration.%';
+`
+
+// maxReminders bounds how many daily check-in reminders can be set
+const maxReminders = 4
+
+var (
+	settingThemes = []string{"system", "light", "dark", "high-contrast"}
+	// settingPrefill is what the check-in form starts with: the last
+	// check-in, typical recent values, or nothing
+	settingPrefill = []string{"last", "typical", "off"}
+	// commonTimezones are suggested on the settings page; any IANA name
+	// is accepted
+	commonTimezones = []string{"auto", "UTC", "Asia/Jakarta", "Asia/Makassar", "Asia/Jayapura",
+		"Asia/Singapore", "Asia/Tokyo", "Australia/Sydney", "Europe/London", "Europe/Berlin",
+		"America/New_York", "America/Chicago", "America/Los_Angeles"}
+)
+
+// Settings are the app-wide preferences kept in the settings table
+type Settings struct {
+	// ReminderTimes are daily check-in reminders as HH:MM, earliest first
+	ReminderTimes []string `json:"reminder_times"`
+	Theme         string   `json:"theme"`
+	Language      string   `json:"language"` // "auto" follows the browser
+	Prefill       string   `json:"prefill"`
+	// Timezone is an IANA name such as "Asia/Jakarta" for dates and times
+	// on pages and reports; "auto" uses the server's
+	Timezone string `json:"timezone"`
+}
+
+func defaultSettings() Settings {
+	return Settings{ReminderTimes: []string{}, Theme: "system", Language: "auto", Prefill: "last", Timezone: "auto"}
+}
+
+func (s *Settings) validate() error {
+	seen := map[string]bool{}
+	times := []string{}
+	for _, t := range s.ReminderTimes {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		if _, err := time.Parse("15:04", t); err != nil {
+			return fmt.Errorf("invalid reminder time %q (use HH:MM)", t)
+		}
+		seen[t] = true
+		times = append(times, t)
+	}
+	if len(times) > maxReminders {
+		return fmt.Errorf("at most %d reminders can be set", maxReminders)
+	}
+	slices.Sort(times)
+	s.ReminderTimes = times
+
+	if !slices.Contains(settingThemes, s.Theme) {
+		return fmt.Errorf("invalid theme %q (use %s)", s.Theme, strings.Join(settingThemes, ", "))
+	}
+	if s.Language != "auto" && matchLanguage(s.Language) == "" {
+		return fmt.Errorf("unsupported language %q (use auto or one of %s)", s.Language, strings.Join(languages(), ", "))
+	}
+	if s.Timezone != "auto" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" || s.Timezone == "Local" {
+			return fmt.Errorf("unknown time zone %q (use auto or an IANA name such as Asia/Jakarta)", s.Timezone)
+		}
+	}
+	if !slices.Contains(settingPrefill, s.Prefill) {
+		return fmt.Errorf("invalid prefill %q (use %s)", s.Prefill, strings.Join(settingPrefill, ", "))
+	}
+	return nil
+}
+
+// loadSettings reads the stored settings over the defaults
+func loadSettings() (Settings, error) {
+	s := defaultSettings()
+	rows, err := stmts.settings.Query()
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return s, err
+		}
+		switch key {
+		case "reminder_times":
+			if value != "" {
+				s.ReminderTimes = strings.Split(value, ",")
+			}
+		case "theme":
+			s.Theme = value
+		case "language":
+			s.Language = value
+		case "prefill":
+			s.Prefill = value
+		case "timezone":
+			s.Timezone = value
+		}
+	}
+	return s, rows.Err()
+}
+
+// saveSettings replaces the stored settings in one transaction
+func saveSettings(s Settings) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	values := map[string]string{
+		"reminder_times": strings.Join(s.ReminderTimes, ","),
+		"theme":          s.Theme,
+		"language":       s.Language,
+		"prefill":        s.Prefill,
+		"timezone":       s.Timezone,
+	}
+	for key, value := range values {
+		_, err := tx.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// handleSettings reads (GET) or updates (PUT) the settings as JSON. Fields
+// missing from a PUT body keep their current value.
+func handleSettings(w http.ResponseWriter, r *http.Request) {
+	current, err := loadSettings()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, current)
+
+	case "PUT":
+		updated := current
+		if err := decodeJSON(r, &updated); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if updated.ReminderTimes == nil {
+			updated.ReminderTimes = []string{}
+		}
+		if err := updated.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := saveSettings(updated); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, updated)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// settingsFromForm reads the settings page form
+func settingsFromForm(r *http.Request) Settings {
+	return Settings{
+		ReminderTimes: r.PostForm["reminder"],
+		Theme:         r.PostFormValue("theme"),
+		Language:      strings.TrimSpace(r.PostFormValue("language")),
+		Prefill:       r.PostFormValue("prefill"),
+		Timezone:      strings.TrimSpace(r.PostFormValue("timezone")),
+	}
+}
+
+// handleSettingsPage renders /settings and saves its form
+func handleSettingsPage(w http.ResponseWriter, r *http.Request) {
+	current, err := loadSettings()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]any{"Saved": r.URL.Query().Get("saved") == "1"}
+	status := http.StatusOK
+	switch r.Method {
+	case "GET":
+	case "POST":
+		if err := r.ParseForm(); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		submitted := settingsFromForm(r)
+		err := submitted.validate()
+		if err == nil {
+			err = saveSettings(submitted)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			http.Redirect(w, r, "/settings?saved=1", http.StatusSeeOther)
+			return
+		}
+		data["Error"] = err.Error()
+		data["Saved"] = false
+		current = submitted
+		status = http.StatusBadRequest
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl, err := loadTemplate("settings.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Always offer one empty slot for another reminder
+	reminders := current.ReminderTimes
+	if len(reminders) < maxReminders {
+		reminders = append(slices.Clip(reminders), "")
+	}
+	data["Settings"] = current
+	data["Reminders"] = reminders
+	data["Themes"] = settingThemes
+	data["Prefill"] = settingPrefill
+	data["Timezones"] = commonTimezones
+	data["ServerZone"] = localizer{}.ZoneName()
+	data["Languages"] = append([]string{"auto"}, languages()...)
+	w.WriteHeader(status)
+	tmpl.Execute(w, data)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const shareLinksSchema = `
+	CREATE TABLE IF NOT EXISTS share_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token_hash TEXT NOT NULL UNIQUE,
+		label TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	);
+`
+
+const (
+	defaultShareDays = 14
+	maxShareDays     = 90
+	// sharedReportWeeks is how much history a shared report shows
+	sharedReportWeeks = 8
+)
+
+// ShareLink is a tokenized, expiring read-only link to the trend report.
+// Only a hash of the token is stored, so the URL is shown once on creation.
+type ShareLink struct {
+	ID        int64      `json:"id"`
+	Label     string     `json:"label"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Active    bool       `json:"active"`
+	URL       string     `json:"url,omitempty"`
+}
+
+// hashShareToken is how tokens are stored and looked up
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newShareToken returns a random URL-safe token
+func newShareToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// listShareLinks returns every link, newest first
+func listShareLinks() ([]ShareLink, error) {
+	rows, err := db.Query(`SELECT id, label, created_at, expires_at, revoked_at FROM share_links ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	links := []ShareLink{}
+	for rows.Next() {
+		var l ShareLink
+		var revoked sql.NullTime
+		if err := rows.Scan(&l.ID, &l.Label, &l.CreatedAt, &l.ExpiresAt, &revoked); err != nil {
+			return nil, err
+		}
+		if revoked.Valid {
+			l.RevokedAt = &revoked.Time
+		}
+		l.Active = !revoked.Valid && now.Before(l.ExpiresAt)
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// handleShareLinks lists (GET) or creates (POST {label, days}) share links
+func handleShareLinks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		links, err := listShareLinks()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, links)
+
+	case "POST":
+		var req struct {
+			Label string `json:"label"`
+			Days  int    `json:"days"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		req.Label = strings.TrimSpace(req.Label)
+		if req.Label == "" {
+			req.Label = "Shared report"
+		}
+		if len(req.Label) > 80 {
+			http.Error(w, "label must be at most 80 characters", http.StatusBadRequest)
+			return
+		}
+		if req.Days == 0 {
+			req.Days = defaultShareDays
+		}
+		if req.Days < 1 || req.Days > maxShareDays {
+			http.Error(w, fmt.Sprintf("days must be between 1 and %d", maxShareDays), http.StatusBadRequest)
+			return
+		}
+
+		token, err := newShareToken()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		link := ShareLink{Label: req.Label, CreatedAt: time.Now().UTC(), ExpiresAt: time.Now().UTC().AddDate(0, 0, req.Days), Active: true}
+		res, err := db.Exec(`INSERT INTO share_links (token_hash, label, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+			hashShareToken(token), link.Label, link.CreatedAt, link.ExpiresAt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		link.ID, _ = res.LastInsertId()
+		link.URL = "/shared/" + token
+		writeJSON(w, http.StatusCreated, link)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// revokeShareLink revokes an active link, reporting false when there is no
+// such link or it was already revoked
+func revokeShareLink(id int64) (bool, error) {
+	res, err := db.Exec(`UPDATE share_links SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// handleShareLink revokes a link (DELETE); the row is kept for the audit trail
+func handleShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ok, err := revokeShareLink(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Share link not found or already revoked", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sharedReportPage is the data behind templates/shared_report.html. It holds
+// aggregates only; individual check-ins and journal notes never leave the app.
+type sharedReportPage struct {
+	Label     string
+	ExpiresAt string
+	Weeks     []reportRow
+	Trend     string
+	Chart     template.HTML
+}
+
+// handleSharedReport renders the read-only trend report for a valid token
+func handleSharedReport(w http.ResponseWriter, r *http.Request) {
+	label, expires, err := activeShareLink(r.PathValue("token"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "This link has expired or been revoked.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	since := bucketStart(time.Now(), granularityWeek).AddDate(0, 0, -7*(sharedReportWeeks-1))
+	entries, err := queryEntries(since, time.Time{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recent, err := queryScorePoints(time.Now().Add(-trendWindow))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	loc := requestLocalizer(r)
+	page := sharedReportPage{Label: label, ExpiresAt: loc.DateLong(expires)}
+	_, page.Trend = describeTrend(loc, recent)
+	chart := chartImage{Width: 680, Height: 220, Title: "Weekly average score"}
+	for _, b := range bucketEntries(entries, granularityWeek) {
+		page.Weeks = append(page.Weeks, reportRow{
+			Date:     loc.Day(b.Start),
+			CheckIns: len(b.Entries),
+			Stats:    summarize(b.Entries),
+		})
+		chart.Labels = append(chart.Labels, loc.Day(b.Start))
+		chart.Scores = append(chart.Scores, roundTo(averageOf(b.Entries, entryScore), 1))
+	}
+	page.Chart = template.HTML(chart.SVG())
+
+	tmpl, err := loadTemplate("shared_report.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	tmpl.Execute(w, page)
+}
+
+// activeShareLink returns the label and expiry of an active link, or
+// sql.ErrNoRows when the token is unknown, revoked or expired
+func activeShareLink(token string) (label string, expires time.Time, err error) {
+	err = db.QueryRow(`SELECT label, expires_at FROM share_links
+		WHERE token_hash = ? AND revoked_at IS NULL`, hashShareToken(token)).Scan(&label, &expires)
+	if err == nil && time.Now().After(expires) {
+		err = sql.ErrNoRows
+	}
+	return label, expires, err
+}
+
+// requestOrigin is BASE_URL when set, otherwise the scheme and host the
+// client used to reach us
+func requestOrigin(r *http.Request) string {
+	if server.BaseURL != "" {
+		return server.BaseURL
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// handleSharedQR renders a QR code of the shared report's full URL, so an
+// advisor can open it on their own device. Only the token holder can ask for
+// it because the server keeps nothing but the token's hash.
+func handleSharedQR(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	if _, _, err := activeShareLink(token); err == sql.ErrNoRows {
+		http.Error(w, "This link has expired or been revoked.", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	png, err := qrcode.Encode(requestOrigin(r)+"/shared/"+token, qrcode.Medium, 320)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
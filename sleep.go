@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+const sleepLogSchema = `
+	CREATE TABLE IF NOT EXISTS sleep_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME NOT NULL,
+		quality INTEGER NOT NULL,
+		nap BOOLEAN NOT NULL DEFAULT 0
+	);
+`
+
+const (
+	// maxNapCredit caps how much napping can stand in for night sleep
+	maxNapCredit = 1.5
+	// qualityStep scales a segment by 10% per quality point away from 3
+	qualityStep = 0.1
+)
+
+// SleepSegment is one block of sleep. A night may be split into several
+// segments, and naps are logged the same way with Nap set. Segments count
+// towards the day on which they end.
+type SleepSegment struct {
+	ID        int64     `json:"id"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Quality   int       `json:"quality"` // 1-5
+	Nap       bool      `json:"nap"`
+	Hours     float64   `json:"hours"`
+}
+
+// validate checks a segment submitted by a client
+func (s *SleepSegment) validate() error {
+	if s.StartedAt.IsZero() || s.EndedAt.IsZero() {
+		return fmt.Errorf("started_at and ended_at are required (RFC 3339)")
+	}
+	if !s.EndedAt.After(s.StartedAt) {
+		return fmt.Errorf("ended_at must be after started_at")
+	}
+	if s.EndedAt.Sub(s.StartedAt) > 16*time.Hour {
+		return fmt.Errorf("a single segment cannot exceed 16 hours")
+	}
+	if s.Quality < 1 || s.Quality > 5 {
+		return fmt.Errorf("quality must be between 1 and 5")
+	}
+	return nil
+}
+
+// querySleepSegments loads segments that ended in [since, until)
+func querySleepSegments(since, until time.Time) ([]SleepSegment, error) {
+	rows, err := db.Query(`
+		SELECT id, started_at, ended_at, quality, nap FROM sleep_log
+		WHERE ended_at >= ? AND ended_at < ? ORDER BY ended_at DESC`, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []SleepSegment{}
+	for rows.Next() {
+		var s SleepSegment
+		if err := rows.Scan(&s.ID, &s.StartedAt, &s.EndedAt, &s.Quality, &s.Nap); err != nil {
+			return nil, err
+		}
+		s.Hours = roundTo(s.EndedAt.Sub(s.StartedAt).Hours(), 2)
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
+
+// SleepSummary is one day's sleep, raw and quality-adjusted
+type SleepSummary struct {
+	Date       string         `json:"date"`
+	Segments   []SleepSegment `json:"segments"`
+	NightHours float64        `json:"night_hours"`
+	NapHours   float64        `json:"nap_hours"`
+	Effective  float64        `json:"effective_hours"`
+}
+
+// summarizeSleep aggregates the segments ending on the day containing t.
+// Each segment is weighted by its quality rating (3 counts at face value,
+// every point above or below shifts it by qualityStep) and nap time is
+// capped at maxNapCredit before it is added to the night.
+func summarizeSleep(t time.Time) (SleepSummary, error) {
+	day := bucketStart(t, granularityDay)
+	segments, err := querySleepSegments(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return SleepSummary{}, err
+	}
+
+	summary := SleepSummary{Date: day.Format("2006-01-02"), Segments: segments}
+	var night, nap float64
+	for _, s := range segments {
+		hours := s.EndedAt.Sub(s.StartedAt).Hours()
+		weighted := hours * (1 + qualityStep*float64(s.Quality-3))
+		if s.Nap {
+			summary.NapHours += hours
+			nap += weighted
+		} else {
+			summary.NightHours += hours
+			night += weighted
+		}
+	}
+	summary.NightHours = roundTo(summary.NightHours, 2)
+	summary.NapHours = roundTo(summary.NapHours, 2)
+	summary.Effective = roundTo(night+math.Min(nap, maxNapCredit), 1)
+	return summary, nil
+}
+
+// effectiveSleep returns the quality-adjusted sleep for the day containing t
+// and whether anything was logged for it
+func effectiveSleep(t time.Time) (float64, bool, error) {
+	summary, err := summarizeSleep(t)
+	if err != nil {
+		return 0, false, err
+	}
+	return summary.Effective, len(summary.Segments) > 0, nil
+}
+
+// handleSleepLog lists segments from the last ?days (GET) or logs one (POST)
+func handleSleepLog(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		days, err := parseRangeDays(r, 7)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		segments, err := querySleepSegments(time.Now().AddDate(0, 0, -days), time.Now().Add(time.Minute))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, segments)
+
+	case "POST":
+		var s SleepSegment
+		if err := decodeJSON(r, &s); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := s.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`INSERT INTO sleep_log (started_at, ended_at, quality, nap) VALUES (?, ?, ?, ?)`,
+			s.StartedAt.UTC(), s.EndedAt.UTC(), s.Quality, s.Nap)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.ID, _ = res.LastInsertId()
+		s.Hours = roundTo(s.EndedAt.Sub(s.StartedAt).Hours(), 2)
+		writeJSON(w, http.StatusCreated, s)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSleepSegment deletes a logged segment
+func handleSleepSegment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := db.Exec(`DELETE FROM sleep_log WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Sleep segment not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSleepSummary returns the effective-sleep breakdown for ?date (default today)
+func handleSleepSummary(w http.ResponseWriter, r *http.Request) {
+	day, err := parseDateParam(r, "date", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	summary, err := summarizeSleep(day)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// timedDriverName is the sqlite3 driver with every statement counted and
+// timed for /metrics
+const timedDriverName = "sqlite3-timed"
+
+func init() {
+	sql.Register(timedDriverName, timedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+// timedDriver opens sqlite3 connections wrapped in timedConn
+type timedDriver struct{ driver.Driver }
+
+func (d timedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{c.(*sqlite3.SQLiteConn)}, nil
+}
+
+// timedConn forwards to the sqlite3 connection, recording how long each
+// Exec and Query took and retrying while the database is locked (see
+// dbpool.go). Prepared statements are timed when they run. Every
+// successful Exec also counts as a change for the ETags in etag.go.
+type timedConn struct{ *sqlite3.SQLiteConn }
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	res, err := retryBusy(ctx, query, func() (driver.Result, error) {
+		return c.SQLiteConn.ExecContext(ctx, query, args)
+	})
+	if err == nil {
+		dataChanged()
+	}
+	return res, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return retryBusy(ctx, query, func() (driver.Rows, error) {
+		return c.SQLiteConn.QueryContext(ctx, query, args)
+	})
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	s, err := c.SQLiteConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{s.(*sqlite3.SQLiteStmt), query}, nil
+}
+
+// BeginTx wraps the transaction so its commit counts as a change: writes
+// inside it are only visible to other connections from then on
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	tx, err := c.SQLiteConn.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return committedTx{tx}, nil
+}
+
+// committedTx bumps dataGeneration on commit
+type committedTx struct{ driver.Tx }
+
+func (t committedTx) Commit() error {
+	err := t.Tx.Commit()
+	dataChanged()
+	return err
+}
+
+// timedStmt times a prepared statement's executions
+type timedStmt struct {
+	*sqlite3.SQLiteStmt
+	query string
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	res, err := retryBusy(ctx, s.query, func() (driver.Result, error) {
+		return s.SQLiteStmt.ExecContext(ctx, args)
+	})
+	if err == nil {
+		dataChanged()
+	}
+	return res, err
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return retryBusy(ctx, s.query, func() (driver.Rows, error) {
+		return s.SQLiteStmt.QueryContext(ctx, args)
+	})
+}
+
+// observeQuery records one statement under its operation
+func observeQuery(query string, start time.Time, err error) {
+	op := queryOperation(query)
+	metrics.count(metrics.queries, op, result(err))
+	metrics.time(metrics.queryTimes, time.Since(start), op)
+}
+
+// queryOperation is a statement's leading keyword, which keeps the label
+// set small: select, insert, update, delete, create...
+func queryOperation(query string) string {
+	op, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	op = strings.ToLower(strings.TrimSpace(op))
+	switch op {
+	case "select", "insert", "update", "delete", "with", "create", "drop", "alter", "pragma", "begin", "commit", "rollback", "vacuum":
+		return op
+	}
+	return "other"
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// sharedState is the state instances behind a load balancer have to agree
+// on: short-lived tokens a request on one instance hands out and a request
+// on another redeems (undo), which instance runs each scheduled job, and
+// when cached responses went stale. STATE_BACKEND picks the implementation:
+//
+//	memory    one instance (the default)
+//	database  instances sharing one database file, e.g. two processes on a
+//	          host or a blue/green pair
+//
+// A Postgres or Redis backend would implement the same four methods.
+type sharedState interface {
+	// Put stores value under key until ttl passes
+	Put(key string, value []byte, ttl time.Duration) error
+	// Take removes key and returns its value, if it has not expired
+	Take(key string) ([]byte, bool, error)
+	// Claim reserves one scheduled run of a job for this instance; it
+	// reports false when another instance already took it
+	Claim(job string, slot time.Time) (bool, error)
+	// Generation increases whenever any instance may have written to the
+	// database; the ETags and response cache are keyed by it
+	Generation() uint64
+}
+
+// state is the running instance's backend, set at startup
+var state sharedState = newMemoryState()
+
+// loadSharedState sets up STATE_BACKEND; call it once the database at
+// path is open
+func loadSharedState(path string) (sharedState, error) {
+	switch backend := envOr("STATE_BACKEND", "memory"); backend {
+	case "memory":
+		return newMemoryState(), nil
+	case "database":
+		return newDatabaseState(db, path)
+	default:
+		return nil, fmt.Errorf("state: STATE_BACKEND must be memory or database, got %q", backend)
+	}
+}
+
+// memoryState keeps everything in this process
+type memoryState struct {
+	mu     sync.Mutex
+	values map[string]memoryValue
+}
+
+type memoryValue struct {
+	value   []byte
+	expires time.Time
+}
+
+func newMemoryState() *memoryState {
+	return &memoryState{values: map[string]memoryValue{}}
+}
+
+func (m *memoryState) Put(key string, value []byte, ttl time.Duration) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.values {
+		if now.After(v.expires) {
+			delete(m.values, k)
+		}
+	}
+	m.values[key] = memoryValue{value: value, expires: now.Add(ttl)}
+	return nil
+}
+
+func (m *memoryState) Take(key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[key]
+	delete(m.values, key)
+	return v.value, ok && !time.Now().After(v.expires), nil
+}
+
+// Claim always succeeds: there is no one to share runs with
+func (m *memoryState) Claim(string, time.Time) (bool, error) { return true, nil }
+
+func (m *memoryState) Generation() uint64 { return dataGeneration.Load() }
+
+const sharedStateSchema = `
+	CREATE TABLE IF NOT EXISTS shared_state (
+		key TEXT PRIMARY KEY,
+		value BLOB NOT NULL,
+		expires_at DATETIME NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS job_claims (
+		job TEXT NOT NULL,
+		slot DATETIME NOT NULL,
+		instance TEXT NOT NULL,
+		claimed_at DATETIME NOT NULL,
+		PRIMARY KEY (job, slot)
+	);
+`
+
+// jobClaimRetention is how long claimed runs are remembered; anything
+// older can no longer collide
+const jobClaimRetention = 7 * 24 * time.Hour
+
+// generationPollInterval is how often Generation asks the database whether
+// another connection wrote; in between it answers from the last poll, so
+// another instance's writes can take this long to invalidate our caches
+const generationPollInterval = 500 * time.Millisecond
+
+// databaseState keeps shared state in the database itself
+type databaseState struct {
+	db       *sql.DB
+	instance string
+
+	// watch is a pool of its own, held at one connection, for PRAGMA
+	// data_version, which changes whenever another connection, in this
+	// process or another, commits a write. Keeping it outside db means it
+	// never takes a connection requests are waiting for, whatever
+	// DB_MAX_OPEN_CONNS says.
+	mu        sync.Mutex
+	watch     *sql.DB
+	version   int64
+	polled    time.Time
+	remoteGen atomic.Uint64
+}
+
+func newDatabaseState(db *sql.DB, path string) (*databaseState, error) {
+	watch, err := sql.Open(timedDriverName, path)
+	if err != nil {
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	watch.SetMaxOpenConns(1)
+	watch.SetMaxIdleConns(1)
+	watch.SetConnMaxLifetime(0)
+	host, _ := os.Hostname()
+	s := &databaseState{db: db, watch: watch, instance: fmt.Sprintf("%s/%d", host, os.Getpid())}
+	if err := watch.QueryRowContext(context.Background(), `PRAGMA data_version`).Scan(&s.version); err != nil {
+		watch.Close()
+		return nil, fmt.Errorf("state: %w", err)
+	}
+	s.polled = time.Now()
+	return s, nil
+}
+
+func (s *databaseState) Put(key string, value []byte, ttl time.Duration) error {
+	now := time.Now().UTC()
+	if _, err := s.db.Exec(`DELETE FROM shared_state WHERE expires_at < ?`, now); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO shared_state (key, value, expires_at) VALUES (?, ?, ?)`, key, value, now.Add(ttl))
+	return err
+}
+
+func (s *databaseState) Take(key string) ([]byte, bool, error) {
+	var value []byte
+	var expires time.Time
+	err := s.db.QueryRow(`DELETE FROM shared_state WHERE key = ? RETURNING value, expires_at`, key).Scan(&value, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, !time.Now().After(expires), nil
+}
+
+// Claim relies on the primary key: of the instances inserting the same
+// job and slot, exactly one insert goes through
+func (s *databaseState) Claim(job string, slot time.Time) (bool, error) {
+	now := time.Now().UTC()
+	if _, err := s.db.Exec(`DELETE FROM job_claims WHERE claimed_at < ?`, now.Add(-jobClaimRetention)); err != nil {
+		return false, err
+	}
+	res, err := s.db.Exec(`INSERT OR IGNORE INTO job_claims (job, slot, instance, claimed_at) VALUES (?, ?, ?, ?)`,
+		job, slot.UTC(), s.instance, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Generation adds the writes other connections committed, as seen through
+// data_version, to this process's own count. Both only grow, so the sum
+// does too, which the response cache relies on. data_version is read at
+// most once per generationPollInterval; this process's own writes still
+// show up at once through dataGeneration.
+func (s *databaseState) Generation() uint64 {
+	s.mu.Lock()
+	if now := time.Now(); now.Sub(s.polled) >= generationPollInterval {
+		s.polled = now
+		var v int64
+		err := s.watch.QueryRowContext(context.Background(), `PRAGMA data_version`).Scan(&v)
+		if err == nil && v != s.version {
+			s.version = v
+			s.remoteGen.Add(1)
+		}
+	}
+	s.mu.Unlock()
+	return dataGeneration.Load() + s.remoteGen.Load()
+}
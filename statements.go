@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// stmts are the statements on the hot paths, prepared once at startup so
+// SQLite doesn't re-parse them on every check-in and chart poll.
+// database/sql prepares each one again on other pool connections as
+// needed, so they are safe to share.
+var stmts struct {
+	// insertEntry stores a check-in from /calculate
+	insertEntry *sql.Stmt
+	// getEntry reads one entry by id
+	getEntry *sql.Stmt
+	// recentScores is the newest rawChartPoints scores for /history-chart
+	recentScores *sql.Stmt
+	// scoresSince is every score from a time on, for the aggregated charts
+	scoresSince *sql.Stmt
+	// settings reads the settings table, which nearly every page consults
+	settings *sql.Stmt
+}
+
+// prepareStatements prepares stmts; it runs after the migrations, since
+// preparing checks the tables and columns exist
+func prepareStatements() error {
+	var err error
+	prepare := func(query string) *sql.Stmt {
+		if err != nil {
+			return nil
+		}
+		var s *sql.Stmt
+		s, err = db.Prepare(query)
+		return s
+	}
+	stmts.insertEntry = prepare(`
+		INSERT INTO entries (sleep, study_hours, deadlines, mood, stress, exercise, score, level, advice, share_with_cohort, journal)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	stmts.getEntry = prepare(`SELECT ` + entryColumns + ` FROM entries WHERE id = ?`)
+	stmts.recentScores = prepare(`
+		SELECT created_at, score, id FROM (
+			SELECT created_at, score, id FROM entries ORDER BY created_at DESC LIMIT ?
+		) ORDER BY created_at ASC`)
+	stmts.scoresSince = prepare(`
+		SELECT created_at, score FROM entries
+		WHERE created_at >= ?
+		ORDER BY created_at ASC`)
+	stmts.settings = prepare(`SELECT key, value FROM settings`)
+	return err
+}
+
+// closeStatements releases stmts before the database is closed
+func closeStatements() error {
+	var errs []error
+	for _, s := range []*sql.Stmt{stmts.insertEntry, stmts.getEntry, stmts.recentScores, stmts.scoresSince, stmts.settings} {
+		if s != nil {
+			errs = append(errs, s.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
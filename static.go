@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// staticFiles is everything under static/, built into the binary. Third-party
+// libraries go in static/vendor (see `make vendor-assets`); until they are
+// there the pages fall back to the CDN copies.
+//
+//go:embed static
+var staticFiles embed.FS
+
+// staticAsset is one embedded file and its content-hashed URL
+type staticAsset struct {
+	name string
+	url  string
+	data []byte
+	etag string
+}
+
+// staticAssets maps both the plain and the hashed path (relative to
+// static/) to the asset
+var staticAssets = loadStaticAssets()
+
+// loadStaticAssets hashes every embedded file once at startup
+func loadStaticAssets() map[string]*staticAsset {
+	assets := map[string]*staticAsset{}
+	fs.WalkDir(staticFiles, "static", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := staticFiles.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])[:10]
+		name := strings.TrimPrefix(p, "static/")
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hash + ext
+
+		a := &staticAsset{name: name, url: "/static/" + hashed, data: data, etag: `"` + hash + `"`}
+		assets[name] = a
+		assets[hashed] = a
+		return nil
+	})
+	return assets
+}
+
+// assetURL returns the content-hashed URL of a static file. When the file
+// is not embedded (an un-vendored library) the first fallback is used.
+func assetURL(name string, fallback ...string) string {
+	if a, ok := staticAssets[name]; ok {
+		return a.url
+	}
+	if len(fallback) > 0 {
+		return fallback[0]
+	}
+	return "/static/" + name
+}
+
+// handleStatic serves embedded assets. Hashed URLs never change content, so
+// they are cached for a year; plain URLs must be revalidated via the ETag.
+func handleStatic(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/static/")
+	a, ok := staticAssets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if "/static/"+name == a.url {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", a.etag)
+	http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(a.data))
+}
@@ -0,0 +1,43 @@
+// Small form behaviours shared by the pages. Markup opts in with data attributes, since the Content-Security-Policy allows no
+// inline scripts or event handlers.
+
+// <select data-autosubmit>: submit the form as soon as the choice changes
+document.querySelectorAll('[data-autosubmit]').forEach(function (el) {
+    el.addEventListener('change', function () { el.form.submit(); });
+});
+
+// <form data-refocus="field">: after a successful HTMX submit, select the
+// named field again so the next row can be typed straight away
+document.querySelectorAll('form[data-refocus]').forEach(function (form) {
+    form.addEventListener('htmx:afterRequest', function (evt) {
+        if (!evt.detail.successful) return;
+        const field = form.elements[form.dataset.refocus];
+        field.focus();
+        field.select();
+    });
+});
+
+// <form data-idle-reset="ms">: reload an empty form after that long
+// without input, so someone who walks away from a kiosk mid-way does not
+// leave their answers for the next person
+document.querySelectorAll('form[data-idle-reset]').forEach(function (form) {
+    let timer;
+    function arm() {
+        clearTimeout(timer);
+        timer = setTimeout(function () { location.replace(form.action); }, Number(form.dataset.idleReset));
+    }
+    ['input', 'change', 'pointerdown', 'keydown'].forEach(function (e) { form.addEventListener(e, arm); });
+    arm();
+});
+
+// <input type="range" data-echo="id">: show the value in the element with
+// that ID as the slider moves, or in the <output> just before it when no ID
+// is given; data-decimals fixes the number of decimals shown
+document.querySelectorAll('input[data-echo]').forEach(function (el) {
+    const target = el.dataset.echo ? document.getElementById(el.dataset.echo) : el.previousElementSibling;
+    el.addEventListener('input', function () {
+        const value = el.dataset.decimals ? (+el.value).toFixed(Number(el.dataset.decimals)) : el.value;
+        if (target.tagName === 'OUTPUT') target.value = value;
+        else target.textContent = value;
+    });
+});
@@ -0,0 +1,56 @@
+// Service worker: keeps the check-in page and its assets available offline.
+// /sw.js prepends CACHE_NAME and PRECACHE (the page and every hashed asset)
+// to this file, so a new build installs a fresh cache and drops the old one.
+
+self.addEventListener('install', event => {
+    event.waitUntil(
+        caches.open(CACHE_NAME)
+            .then(cache => cache.addAll(PRECACHE))
+            .then(() => self.skipWaiting())
+    );
+});
+
+self.addEventListener('activate', event => {
+    event.waitUntil(
+        caches.keys()
+            .then(names => Promise.all(names.filter(n => n !== CACHE_NAME).map(n => caches.delete(n))))
+            .then(() => self.clients.claim())
+    );
+});
+
+self.addEventListener('fetch', event => {
+    const req = event.request;
+    if (req.method !== 'GET') return;
+    const url = new URL(req.url);
+
+    // Pages: network first so data stays fresh, the cached copy when offline
+    if (req.mode === 'navigate') {
+        event.respondWith(
+            fetch(req)
+                .then(res => {
+                    if (res.ok && !res.redirected && url.pathname === '/') {
+                        const copy = res.clone();
+                        caches.open(CACHE_NAME).then(cache => cache.put('/', copy));
+                    }
+                    return res;
+                })
+                .catch(() => caches.match(req).then(hit => hit || caches.match('/')))
+        );
+        return;
+    }
+
+    // Hashed assets never change; CDN fallbacks (before `make vendor-assets`)
+    // are cached on first use
+    const cdn = url.origin !== self.location.origin && ['script', 'style', 'font'].includes(req.destination);
+    if (url.pathname.startsWith('/static/') || cdn) {
+        event.respondWith(
+            caches.match(req).then(hit => hit || fetch(req).then(res => {
+                if (res.ok || res.type === 'opaque') {
+                    const copy = res.clone();
+                    caches.open(CACHE_NAME).then(cache => cache.put(req, copy));
+                }
+                return res;
+            }))
+        );
+    }
+});
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Stats is the payload of /api/stats
+type Stats struct {
+	TotalEntries         int      `json:"total_entries"`
+	LastScore            *float64 `json:"last_score"`
+	LastLevel            string   `json:"last_level,omitempty"`
+	LastPercentile       *float64 `json:"last_percentile"`
+	CheckInStreak        int      `json:"checkin_streak"`
+	LongestCheckInStreak int      `json:"longest_checkin_streak"`
+	HealthyStreak        int      `json:"healthy_streak"`
+	RiskIndex            *float64 `json:"risk_index"`
+}
+
+// Streaks counts consecutive days of logging and of healthy scores
+type Streaks struct {
+	CheckIn        int
+	LongestCheckIn int
+	Healthy        int
+}
+
+// computeStreaks walks the daily buckets backwards from today. The current
+// check-in streak survives until the end of today, so a streak ending
+// yesterday still counts. The healthy streak counts consecutive healthy days
+// back from today, or from yesterday while today has no entry yet, and is 0
+// when that day was not healthy or not logged.
+func computeStreaks(entries []BurnoutEntry, now time.Time) Streaks {
+	var s Streaks
+	days := bucketEntries(entries, granularityDay)
+	if len(days) == 0 {
+		return s
+	}
+
+	run := 1
+	s.LongestCheckIn = 1
+	for i := 1; i < len(days); i++ {
+		if days[i].Start.Equal(days[i-1].Start.AddDate(0, 0, 1)) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > s.LongestCheckIn {
+			s.LongestCheckIn = run
+		}
+	}
+
+	today := bucketStart(now, granularityDay)
+	last := days[len(days)-1].Start
+	if last.Equal(today) || last.Equal(today.AddDate(0, 0, -1)) {
+		s.CheckIn = run
+	}
+
+	anchor := today
+	if !last.Equal(today) {
+		anchor = today.AddDate(0, 0, -1)
+	}
+	for i := len(days) - 1; i >= 0; i-- {
+		if !days[i].Start.Equal(anchor.AddDate(0, 0, -s.Healthy)) {
+			break
+		}
+		if scoreBucket(averageOf(days[i].Entries, entryScore)) != heatmapHealthy {
+			break
+		}
+		s.Healthy++
+	}
+	return s
+}
+
+// loadStreaks computes streaks over every stored entry
+func loadStreaks() (Streaks, error) {
+	entries, err := queryEntries(time.Time{}, time.Time{})
+	if err != nil {
+		return Streaks{}, err
+	}
+	return computeStreaks(entries, time.Now()), nil
+}
+
+// loadStats gathers the headline numbers for /api/stats
+func loadStats() (Stats, error) {
+	var stats Stats
+	if err := db.QueryRow(`SELECT COUNT(*) FROM entries`).Scan(&stats.TotalEntries); err != nil {
+		return stats, err
+	}
+
+	recent, err := queryRecentEntries(1)
+	if err != nil {
+		return stats, err
+	}
+	if len(recent) == 1 {
+		score := roundTo(recent[0].Score, 1)
+		stats.LastScore = &score
+		stats.LastLevel = recent[0].Level
+
+		pct, n, err := scorePercentile(recent[0].Score, int64(recent[0].ID))
+		if err != nil {
+			return stats, err
+		}
+		if n > 0 {
+			stats.LastPercentile = &pct
+		}
+	}
+
+	streaks, err := loadStreaks()
+	if err != nil {
+		return stats, err
+	}
+	stats.CheckInStreak = streaks.CheckIn
+	stats.LongestCheckInStreak = streaks.LongestCheckIn
+	stats.HealthyStreak = streaks.Healthy
+
+	// The stored series is written nightly; stats show today's live value
+	idx, ok, err := computeRiskIndex(time.Now())
+	if err != nil {
+		return stats, err
+	}
+	if ok {
+		stats.RiskIndex = &idx.Value
+	}
+	return stats, nil
+}
+
+// handleStats returns headline numbers and streaks
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := loadStats()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// scorePercentile returns the share (0-100) of the other entries that scored
+// lower than score, counting ties as half, along with how many entries it was
+// compared against
+func scorePercentile(score float64, excludeID int64) (float64, int, error) {
+	var total int
+	var lower float64
+	err := db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN score < ? THEN 1.0 WHEN score = ? THEN 0.5 ELSE 0 END), 0)
+		FROM entries WHERE id != ?`, score, score, excludeID).Scan(&total, &lower)
+	if err != nil || total == 0 {
+		return 0, total, err
+	}
+	return roundTo(lower/float64(total)*100, 0), total, nil
+}
+
+// percentileSentence phrases a percentile for the result card
+func percentileSentence(loc localizer, pct float64, n int) string {
+	if n == 0 {
+		return loc.T("result.percentile_first")
+	}
+	if pct >= 50 {
+		return loc.T("result.percentile_worse", "Pct", fmt.Sprintf("%.0f", pct), "Count", n)
+	}
+	return loc.T("result.percentile_better", "Pct", fmt.Sprintf("%.0f", 100-pct), "Count", n)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const studySessionsSchema = `
+	CREATE TABLE IF NOT EXISTS study_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subject TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME NOT NULL,
+		intensity INTEGER NOT NULL
+	);
+`
+
+// StudySession is a logged block of study on one subject
+type StudySession struct {
+	ID        int64     `json:"id"`
+	Subject   string    `json:"subject"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Intensity int       `json:"intensity"` // 1-5
+	Hours     float64   `json:"hours"`
+}
+
+// validate checks a session submitted by a client
+func (s *StudySession) validate() error {
+	s.Subject = strings.TrimSpace(s.Subject)
+	if s.Subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+	if s.StartedAt.IsZero() || s.EndedAt.IsZero() {
+		return fmt.Errorf("started_at and ended_at are required (RFC 3339)")
+	}
+	if !s.EndedAt.After(s.StartedAt) {
+		return fmt.Errorf("ended_at must be after started_at")
+	}
+	if s.EndedAt.Sub(s.StartedAt) > 16*time.Hour {
+		return fmt.Errorf("a single session cannot exceed 16 hours")
+	}
+	if s.Intensity < 1 || s.Intensity > 5 {
+		return fmt.Errorf("intensity must be between 1 and 5")
+	}
+	return nil
+}
+
+// queryStudySessions loads sessions started in [since, until)
+func queryStudySessions(since, until time.Time) ([]StudySession, error) {
+	rows, err := db.Query(`
+		SELECT id, subject, started_at, ended_at, intensity FROM study_sessions
+		WHERE started_at >= ? AND started_at < ? ORDER BY started_at DESC`, since.UTC(), until.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []StudySession{}
+	for rows.Next() {
+		var s StudySession
+		if err := rows.Scan(&s.ID, &s.Subject, &s.StartedAt, &s.EndedAt, &s.Intensity); err != nil {
+			return nil, err
+		}
+		s.Hours = roundTo(s.EndedAt.Sub(s.StartedAt).Hours(), 2)
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// studySessionHours sums logged study session time on the day containing t
+func studySessionHours(t time.Time) (float64, error) {
+	day := bucketStart(t, granularityDay)
+	sessions, err := queryStudySessions(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return 0, err
+	}
+	var hours float64
+	for _, s := range sessions {
+		hours += s.EndedAt.Sub(s.StartedAt).Hours()
+	}
+	return hours, nil
+}
+
+// handleStudySessions lists sessions from the last ?days (GET) or logs one (POST)
+func handleStudySessions(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		days, err := parseRangeDays(r, 7)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sessions, err := queryStudySessions(time.Now().AddDate(0, 0, -days), time.Now().Add(time.Minute))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, sessions)
+
+	case "POST":
+		var s StudySession
+		if err := decodeJSON(r, &s); err != nil {
+			writeBodyError(w, err)
+			return
+		}
+		if err := s.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res, err := db.Exec(`INSERT INTO study_sessions (subject, started_at, ended_at, intensity) VALUES (?, ?, ?, ?)`,
+			s.Subject, s.StartedAt.UTC(), s.EndedAt.UTC(), s.Intensity)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.ID, _ = res.LastInsertId()
+		s.Hours = roundTo(s.EndedAt.Sub(s.StartedAt).Hours(), 2)
+		writeJSON(w, http.StatusCreated, s)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStudySession deletes a logged session
+func handleStudySession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := pathID(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := db.Exec(`DELETE FROM study_sessions WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Study session not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SubjectStats aggregates the study sessions of one subject
+type SubjectStats struct {
+	Subject      string  `json:"subject"`
+	Sessions     int     `json:"sessions"`
+	Hours        float64 `json:"hours"`
+	Share        float64 `json:"share"`
+	AvgIntensity float64 `json:"avg_intensity"`
+}
+
+// handleStudySubjects returns per-subject totals over the last ?days (default 30)
+func handleStudySubjects(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultInsightDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sessions, err := queryStudySessions(time.Now().AddDate(0, 0, -days), time.Now().Add(time.Minute))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bySubject := map[string]*SubjectStats{}
+	intensity := map[string]float64{}
+	var total float64
+	for _, s := range sessions {
+		key := strings.ToLower(s.Subject)
+		st, ok := bySubject[key]
+		if !ok {
+			st = &SubjectStats{Subject: s.Subject}
+			bySubject[key] = st
+		}
+		hours := s.EndedAt.Sub(s.StartedAt).Hours()
+		st.Sessions++
+		st.Hours += hours
+		intensity[key] += float64(s.Intensity) * hours
+		total += hours
+	}
+
+	stats := []SubjectStats{}
+	for key, st := range bySubject {
+		st.AvgIntensity = roundTo(intensity[key]/st.Hours, 1) // weighted by time spent
+		if total > 0 {
+			st.Share = roundTo(st.Hours/total*100, 0)
+		}
+		st.Hours = roundTo(st.Hours, 1)
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Hours > stats[j].Hours })
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+)
+
+// PeriodStats summarises the scores and inputs over a period
+type PeriodStats struct {
+	Entries   int     `json:"entries"`
+	AvgScore  float64 `json:"avg_score"`
+	MinScore  float64 `json:"min_score"`
+	MaxScore  float64 `json:"max_score"`
+	AvgSleep  float64 `json:"avg_sleep"`
+	AvgStudy  float64 `json:"avg_study_hours"`
+	AvgMood   float64 `json:"avg_mood"`
+	AvgStress float64 `json:"avg_stress"`
+}
+
+// DaySummary identifies a single day by its average score
+type DaySummary struct {
+	Date     string  `json:"date"`
+	AvgScore float64 `json:"avg_score"`
+}
+
+// WeeklySummary is the payload of /api/summary/weekly
+type WeeklySummary struct {
+	WeekStart string             `json:"week_start"`
+	WeekEnd   string             `json:"week_end"`
+	Current   PeriodStats        `json:"current"`
+	Previous  PeriodStats        `json:"previous"`
+	Deltas    map[string]float64 `json:"deltas,omitempty"`
+	BestDay   *DaySummary        `json:"best_day,omitempty"`
+	WorstDay  *DaySummary        `json:"worst_day,omitempty"`
+	TopDriver string             `json:"top_driver,omitempty"`
+	Goals     []Goal             `json:"goals"`
+}
+
+// summarize computes PeriodStats for a set of entries
+func summarize(entries []BurnoutEntry) PeriodStats {
+	stats := PeriodStats{Entries: len(entries)}
+	if len(entries) == 0 {
+		return stats
+	}
+	stats.MinScore, stats.MaxScore = math.Inf(1), math.Inf(-1)
+	for _, e := range entries {
+		stats.MinScore = math.Min(stats.MinScore, e.Score)
+		stats.MaxScore = math.Max(stats.MaxScore, e.Score)
+	}
+	stats.MinScore = roundTo(stats.MinScore, 1)
+	stats.MaxScore = roundTo(stats.MaxScore, 1)
+	stats.AvgScore = roundTo(averageOf(entries, entryScore), 1)
+	stats.AvgSleep = roundTo(averageOf(entries, entrySleep), 1)
+	stats.AvgStudy = roundTo(averageOf(entries, entryStudyHours), 1)
+	stats.AvgMood = roundTo(averageOf(entries, entryMood), 1)
+	stats.AvgStress = roundTo(averageOf(entries, entryStress), 1)
+	return stats
+}
+
+// bestAndWorstDays picks the days with the lowest and highest average score
+func bestAndWorstDays(entries []BurnoutEntry) (best, worst *DaySummary) {
+	for _, b := range bucketEntries(entries, granularityDay) {
+		d := &DaySummary{Date: b.Start.Format("2006-01-02"), AvgScore: roundTo(averageOf(b.Entries, entryScore), 1)}
+		if best == nil || d.AvgScore < best.AvgScore {
+			best = d
+		}
+		if worst == nil || d.AvgScore > worst.AvgScore {
+			worst = d
+		}
+	}
+	return best, worst
+}
+
+// buildWeeklySummary summarises the Monday-based week containing day. It is
+// the single source for any weekly view so they all report the same numbers.
+func buildWeeklySummary(day time.Time) (WeeklySummary, error) {
+	start := bucketStart(day, granularityWeek)
+	end := start.AddDate(0, 0, 7)
+	prevStart := start.AddDate(0, 0, -7)
+
+	current, err := queryEntries(start, end)
+	if err != nil {
+		return WeeklySummary{}, err
+	}
+	previous, err := queryEntries(prevStart, start)
+	if err != nil {
+		return WeeklySummary{}, err
+	}
+
+	summary := WeeklySummary{
+		WeekStart: start.Format("2006-01-02"),
+		WeekEnd:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Current:   summarize(current),
+		Previous:  summarize(previous),
+	}
+	if summary.Current.Entries > 0 && summary.Previous.Entries > 0 {
+		summary.Deltas = map[string]float64{
+			"avg_score":       roundTo(summary.Current.AvgScore-summary.Previous.AvgScore, 1),
+			"avg_sleep":       roundTo(summary.Current.AvgSleep-summary.Previous.AvgSleep, 1),
+			"avg_study_hours": roundTo(summary.Current.AvgStudy-summary.Previous.AvgStudy, 1),
+			"avg_mood":        roundTo(summary.Current.AvgMood-summary.Previous.AvgMood, 1),
+			"avg_stress":      roundTo(summary.Current.AvgStress-summary.Previous.AvgStress, 1),
+		}
+	}
+	summary.BestDay, summary.WorstDay = bestAndWorstDays(current)
+
+	// A single week rarely has enough samples for a stable correlation, so the
+	// driver is taken from the month leading up to the end of the week
+	window, err := queryEntries(end.AddDate(0, 0, -defaultInsightDays), end)
+	if err != nil {
+		return WeeklySummary{}, err
+	}
+	summary.TopDriver = buildCorrelationReport(localizer{}, window, defaultInsightDays).StrongestInput
+
+	goals, err := listGoals(true)
+	if err != nil {
+		return WeeklySummary{}, err
+	}
+	summary.Goals = []Goal{}
+	for _, g := range goals {
+		if g.Period != "week" {
+			continue
+		}
+		p, err := g.evaluate(start)
+		if err != nil {
+			return WeeklySummary{}, err
+		}
+		g.Progress = &p
+		summary.Goals = append(summary.Goals, g)
+	}
+	return summary, nil
+}
+
+// parseDateParam reads an optional YYYY-MM-DD query parameter
+func parseDateParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	t, err := parseDay(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q (use YYYY-MM-DD)", name, v)
+	}
+	return t, nil
+}
+
+// handleWeeklySummary returns structured stats for a week (default: this week)
+func handleWeeklySummary(w http.ResponseWriter, r *http.Request) {
+	day, err := parseDateParam(r, "week", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	summary, err := buildWeeklySummary(day)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	// maxSyncBatch bounds how many queued check-ins one sync request carries
+	maxSyncBatch = 100
+	// syncClockSkew is how far in the future a client timestamp may be
+	syncClockSkew = 5 * time.Minute
+)
+
+var clientIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// syncEntry is a check-in made while offline. ClientID is a UUID generated
+// by the device, so a batch can be resent after a dropped connection
+// without duplicating entries. Blank sleep, study and deadline fields are
+// filled from the logs for the day of the check-in, as the form does.
+type syncEntry struct {
+	ClientID        string    `json:"client_id"`
+	RecordedAt      time.Time `json:"recorded_at"`
+	Sleep           *float64  `json:"sleep"`
+	StudyHours      *float64  `json:"study_hours"`
+	Deadlines       *int      `json:"deadlines"`
+	Mood            int       `json:"mood"`
+	Stress          int       `json:"stress"`
+	Exercise        bool      `json:"exercise"`
+	ShareWithCohort bool      `json:"share_with_cohort"`
+	Journal         string    `json:"journal"`
+}
+
+// syncResult reports what happened to one queued check-in: "created",
+// "duplicate" (already synced; ID is the stored entry) or "invalid"
+type syncResult struct {
+	ClientID string `json:"client_id"`
+	Status   string `json:"status"`
+	ID       int64  `json:"id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// input resolves blank fields and validates the check-in
+func (s syncEntry) input() (entryInput, error) {
+	in := entryInput{Mood: s.Mood, Stress: s.Stress, Exercise: s.Exercise, Journal: s.Journal}
+	if s.Sleep != nil {
+		in.Sleep = *s.Sleep
+	} else if effective, ok, err := effectiveSleep(s.RecordedAt); err == nil && ok {
+		in.Sleep = effective
+	}
+	if s.StudyHours != nil {
+		in.StudyHours = *s.StudyHours
+	} else if logged, err := loggedStudyHours(s.RecordedAt); err == nil {
+		in.StudyHours = logged
+	}
+	if s.Deadlines != nil {
+		in.Deadlines = *s.Deadlines
+	} else if load, err := upcomingDeadlineLoad(s.RecordedAt); err == nil {
+		in.Deadlines = load
+	}
+	return in, in.validate()
+}
+
+// syncCheckin is a queued check-in that passed validation, with its score,
+// level and advice worked out before the batch's transaction is opened
+type syncCheckin struct {
+	syncEntry
+	in     entryInput
+	score  float64
+	level  string
+	advice string
+}
+
+// prepareSync validates a queued check-in and scores it. It reads the logs
+// and the scoring formula through the pool, so it runs before handleSync
+// takes a connection for its transaction.
+func prepareSync(s syncEntry) (syncCheckin, error) {
+	if !clientIDPattern.MatchString(s.ClientID) {
+		return syncCheckin{}, fmt.Errorf("client_id must be a UUID")
+	}
+	if s.RecordedAt.IsZero() {
+		s.RecordedAt = time.Now()
+	}
+	if s.RecordedAt.After(time.Now().Add(syncClockSkew)) {
+		return syncCheckin{}, fmt.Errorf("recorded_at is in the future")
+	}
+	in, err := s.input()
+	if err != nil {
+		return syncCheckin{}, err
+	}
+	score := burnoutScore(in.Sleep, in.StudyHours, in.Deadlines, in.Stress, recoveryCredit(in.Exercise, s.RecordedAt))
+	return syncCheckin{syncEntry: s, in: in, score: score, level: scoreLevel(score),
+		advice: generateAIAdvice(in.Sleep, in.Deadlines, in.Stress, score)}, nil
+}
+
+// syncOne stores a prepared check-in unless its client ID was seen before.
+// It only uses tx.
+func syncOne(ctx context.Context, tx *sql.Tx, c syncCheckin) syncResult {
+	result := syncResult{ClientID: c.ClientID}
+	err := tx.QueryRowContext(ctx, `SELECT id FROM entries WHERE client_id = ?`, c.ClientID).Scan(&result.ID)
+	if err == nil {
+		result.Status = "duplicate"
+		return result
+	}
+	if err != sql.ErrNoRows {
+		result.Status, result.Error = "invalid", err.Error()
+		return result
+	}
+
+	in := c.in
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO entries (client_id, created_at, sleep, study_hours, deadlines, mood, stress, exercise, score, level, advice, share_with_cohort, journal)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.ClientID, c.RecordedAt.UTC().Format("2006-01-02 15:04:05"), in.Sleep, in.StudyHours, in.Deadlines,
+		in.Mood, in.Stress, in.Exercise, c.score, c.level, c.advice, c.ShareWithCohort, in.Journal)
+	if err != nil {
+		result.Status, result.Error = "invalid", err.Error()
+		return result
+	}
+	result.ID, _ = res.LastInsertId()
+	result.Status = "created"
+	return result
+}
+
+// handleSync stores a batch of check-ins queued by the app while offline.
+// Each entry is reported on separately, so the client can drop everything
+// that is created, duplicate or invalid and keep only what failed to send.
+func handleSync(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Entries []syncEntry `json:"entries"`
+	}
+	if err := decodeJSON(r, &body); err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	if len(body.Entries) > maxSyncBatch {
+		http.Error(w, fmt.Sprintf("at most %d entries can be synced at once", maxSyncBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	results := make([]syncResult, len(body.Entries))
+	prepared := make([]syncCheckin, len(body.Entries))
+	for i, s := range body.Entries {
+		c, err := prepareSync(s)
+		if err != nil {
+			results[i] = syncResult{ClientID: s.ClientID, Status: "invalid", Error: err.Error()}
+			continue
+		}
+		prepared[i] = c
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	for i, c := range prepared {
+		if results[i].Status == "" {
+			results[i] = syncOne(r.Context(), tx, c)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, res := range results {
+		if res.Status != "created" {
+			continue
+		}
+		if entry, err := getEntry(res.ID); err == nil {
+			if err := trackChallenges(entry); err != nil {
+				requestLog(r).Error("challenge tracking failed", "entry_id", res.ID, "err", err)
+			}
+			if err := notifyWebhooks(entry); err != nil {
+				requestLog(r).Error("queueing webhooks failed", "entry_id", res.ID, "err", err)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
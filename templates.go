@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// templateFuncs are available to every page template. The localized ones
+// ("t", "lang" and the date functions) speak the default language in the
+// server's zone here; localizedTemplate rebinds them per request.
+var templateFuncs = template.FuncMap{
+	"asset":      assetURL,
+	"snippet":    snippet,
+	"themeClass": themeClass,
+	"brand":      func() branding { return brand },
+	"demo":       func() bool { return demoMode },
+}
+
+func init() {
+	for name, fn := range localizedFuncs(localizer{Lang: defaultLanguage}) {
+		templateFuncs[name] = fn
+	}
+}
+
+// templatePollInterval is how often dev mode checks the templates directory for edits
+const templatePollInterval = 500 * time.Millisecond
+
+// templateCache holds every page in the templates directory, parsed once. In dev mode a
+// watcher re-parses them whenever a file changes, so edits show up on the
+// next reload without restarting the server.
+type templateCache struct {
+	dir    string
+	mu     sync.RWMutex
+	pages  map[string]*template.Template
+	stamps map[string]time.Time
+	// localized holds each page bound to one language and zone, keyed by
+	// page, language and zone name, so requests don't clone it every time
+	localized map[string]*template.Template
+}
+
+var templates = &templateCache{dir: "templates"}
+
+// load parses all templates; on error the previous set stays in use
+func (c *templateCache) load() error {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.html"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no templates found in %s", c.dir)
+	}
+	pages := map[string]*template.Template{}
+	stamps := map[string]time.Time{}
+	for _, file := range files {
+		name := filepath.Base(file)
+		tmpl, err := template.New(name).Funcs(templateFuncs).ParseFiles(file)
+		if err != nil {
+			return err
+		}
+		pages[name] = tmpl
+		if info, err := os.Stat(file); err == nil {
+			stamps[name] = info.ModTime()
+		}
+	}
+
+	c.mu.Lock()
+	c.pages, c.stamps = pages, stamps
+	c.localized = map[string]*template.Template{}
+	c.mu.Unlock()
+	return nil
+}
+
+// changed reports whether any template was added, removed or modified
+func (c *templateCache) changed() bool {
+	files, _ := filepath.Glob(filepath.Join(c.dir, "*.html"))
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(files) != len(c.stamps) {
+		return true
+	}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		stamp, ok := c.stamps[filepath.Base(file)]
+		if err != nil || !ok || !info.ModTime().Equal(stamp) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch re-parses the templates whenever they change (dev mode only)
+func (c *templateCache) watch() {
+	var lastErr string
+	for range time.Tick(templatePollInterval) {
+		if !c.changed() {
+			continue
+		}
+		// A broken file keeps looking changed until it is fixed, so each
+		// distinct error is only reported once
+		if err := c.load(); err != nil {
+			if err.Error() != lastErr {
+				slog.Warn("template reload failed, keeping the previous version", "err", err)
+				lastErr = err.Error()
+			}
+			continue
+		}
+		lastErr = ""
+		slog.Info("templates reloaded")
+	}
+}
+
+// loadTemplate returns a parsed page from the templates directory
+func loadTemplate(name string) (*template.Template, error) {
+	templates.mu.RLock()
+	defer templates.mu.RUnlock()
+	tmpl, ok := templates.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	return tmpl, nil
+}
+
+// localizedPage returns the page with loc's template functions, cloning
+// and caching it the first time a language and zone ask for it
+func (c *templateCache) localizedPage(name string, loc localizer) (*template.Template, error) {
+	key := name + "\x00" + loc.Lang + "\x00" + loc.zone().String()
+	c.mu.RLock()
+	tmpl, ok := c.localized[key]
+	c.mu.RUnlock()
+	if ok {
+		return tmpl, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tmpl, ok := c.localized[key]; ok {
+		return tmpl, nil
+	}
+	page, ok := c.pages[name]
+	if !ok {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	clone, err := page.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl = clone.Funcs(localizedFuncs(loc))
+	c.localized[key] = tmpl
+	return tmpl, nil
+}
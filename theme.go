@@ -0,0 +1,15 @@
+package main
+
+import "log/slog"
+
+// themeClass is the class set on <html> for the saved theme, so pages are
+// rendered in it from the first paint. "theme-system" defers to the
+// browser's prefers-color-scheme in app.css.
+func themeClass() string {
+	s, err := loadSettings()
+	if err != nil {
+		slog.Error("theme setting unreadable", "err", err)
+		return "theme-system"
+	}
+	return "theme-" + s.Theme
+}
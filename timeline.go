@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// maxJournalLength bounds the journal note stored with a check-in
+	maxJournalLength = 2000
+	// journalSnippetLength is how much of a note the timeline shows
+	journalSnippetLength = 280
+	// defaultTimelineDays covers roughly one semester
+	defaultTimelineDays = 120
+)
+
+// TimelineItem is one moment in the timeline: either a check-in with its
+// mood, score and journal snippet, or a user annotation such as "Exam week"
+type TimelineItem struct {
+	Kind    string  `json:"kind"` // "checkin" or "annotation"
+	At      string  `json:"at"`
+	Date    string  `json:"date"`
+	EntryID int     `json:"entry_id,omitempty"`
+	Mood    int     `json:"mood,omitempty"`
+	Score   float64 `json:"score,omitempty"`
+	Level   string  `json:"level,omitempty"`
+	Journal string  `json:"journal,omitempty"`
+	Label   string  `json:"label,omitempty"`
+	// MoodShift is the change in mood since the previous check-in
+	MoodShift int `json:"mood_shift,omitempty"`
+}
+
+// snippet shortens a journal note to journalSnippetLength runes on a word boundary
+func snippet(text string) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= journalSnippetLength {
+		return string(runes)
+	}
+	cut := string(runes[:journalSnippetLength])
+	if i := strings.LastIndexByte(cut, ' '); i > journalSnippetLength/2 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
+
+// buildTimeline merges check-ins and annotations from the last days into
+// one chronological list, oldest first
+func buildTimeline(days int) ([]TimelineItem, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	entries, err := queryEntries(since, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := listAnnotations()
+	if err != nil {
+		return nil, err
+	}
+
+	items := []TimelineItem{}
+	for i, e := range entries {
+		item := TimelineItem{
+			Kind:    "checkin",
+			At:      e.CreatedAt.UTC().Format(time.RFC3339),
+			Date:    dayKey(e.CreatedAt),
+			EntryID: e.ID,
+			Mood:    e.Mood,
+			Score:   e.Score,
+			Level:   e.Level,
+			Journal: snippet(e.Journal),
+		}
+		if i > 0 {
+			item.MoodShift = e.Mood - entries[i-1].Mood
+		}
+		items = append(items, item)
+	}
+
+	sinceDate := dayKey(since)
+	for _, a := range annotations {
+		if a.Date < sinceDate {
+			continue
+		}
+		// Annotations are whole-day markers, so they open their day
+		items = append(items, TimelineItem{Kind: "annotation", At: a.Date + "T00:00:00Z", Date: a.Date, Label: a.Label})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].At < items[j].At })
+	return items, nil
+}
+
+// handleTimeline returns the timeline for the last ?days (default 120)
+func handleTimeline(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultTimelineDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items, err := buildTimeline(days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// handleTimelinePage renders the timeline newest first, grouped by day
+func handleTimelinePage(w http.ResponseWriter, r *http.Request) {
+	days, err := parseRangeDays(r, defaultTimelineDays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	items, err := buildTimeline(days)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type dayGroup struct {
+		Date  string
+		Items []TimelineItem
+	}
+	var groups []dayGroup
+	for i := len(items) - 1; i >= 0; i-- {
+		if len(groups) == 0 || groups[len(groups)-1].Date != items[i].Date {
+			groups = append(groups, dayGroup{Date: items[i].Date})
+		}
+		g := &groups[len(groups)-1]
+		g.Items = append(g.Items, items[i])
+	}
+
+	tmpl, err := loadTemplate("timeline.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, map[string]any{"Days": days, "Groups": groups})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// readHeaderTimeout bounds how long a client may take to send its request
+// headers, so a connection that trickles them in can't be held open
+const readHeaderTimeout = 10 * time.Second
+
+// timeoutMessage is the body of a 503 from a handler that ran out of time
+const timeoutMessage = "The server took too long to answer. Please try again in a moment."
+
+// resolveTimeouts reads the HTTP_*_TIMEOUT and REQUEST_TIMEOUT durations:
+//
+//	HTTP_READ_TIMEOUT   whole request including the body, e.g. an import
+//	                    upload (1m)
+//	HTTP_WRITE_TIMEOUT  from the end of the headers to the end of the
+//	                    response, so it also caps downloads and profiles (2m)
+//	HTTP_IDLE_TIMEOUT   keep-alive connections waiting for the next
+//	                    request (2m)
+//	REQUEST_TIMEOUT     how long a page or API handler may run before the
+//	                    client gets a 503 (30s)
+func (c *serverConfig) resolveTimeouts() error {
+	for _, f := range []struct {
+		v        *time.Duration
+		env, def string
+	}{
+		{&c.ReadTimeout, "HTTP_READ_TIMEOUT", "1m"},
+		{&c.WriteTimeout, "HTTP_WRITE_TIMEOUT", "2m"},
+		{&c.IdleTimeout, "HTTP_IDLE_TIMEOUT", "2m"},
+		{&c.RequestTimeout, "REQUEST_TIMEOUT", "30s"},
+	} {
+		v := envOr(f.env, f.def)
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("config: %s must be a duration such as %s, got %q", f.env, f.def, v)
+		}
+		*f.v = d
+	}
+	if c.RequestTimeout >= c.WriteTimeout {
+		return fmt.Errorf("config: REQUEST_TIMEOUT (%s) must be shorter than HTTP_WRITE_TIMEOUT (%s), or clients are cut off before they get the 503",
+			c.RequestTimeout, c.WriteTimeout)
+	}
+	return nil
+}
+
+// applyTimeouts sets the connection timeouts on srv
+func (c serverConfig) applyTimeouts(srv *http.Server) {
+	srv.ReadHeaderTimeout = readHeaderTimeout
+	srv.ReadTimeout = c.ReadTimeout
+	srv.WriteTimeout = c.WriteTimeout
+	srv.IdleTimeout = c.IdleTimeout
+}
+
+// timeout gives a page or API handler d to answer. The request's context
+// is cancelled after d, so queries made with it stop and handlers can
+// check ctx.Err(); the handler runs on the request's own goroutine, so a
+// panic keeps its stack and responses still stream. A handler that gives up
+// because of the deadline, by failing with a 5xx or writing nothing, is
+// answered 503 with timeoutMessage instead.
+//
+// The deadline is cooperative: nothing stops a handler that ignores its
+// context, which keeps running, and holding any database connection it
+// took, until it returns on its own. Such overruns are logged so the
+// handler can be fixed to pass r.Context() down. resolveTimeouts keeps d
+// below the write timeout so the 503 can still reach the client.
+func timeout(d time.Duration) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			start := time.Now()
+			tw := &timeoutWriter{statusRecorder: statusRecorder{ResponseWriter: w}, ctx: ctx}
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			if overrun := time.Since(start) - d; overrun > time.Second {
+				requestLog(r).Warn("handler kept running past its deadline", "timeout", d, "overrun", overrun.Round(time.Millisecond))
+			}
+			if tw.code == 0 && ctx.Err() == context.DeadlineExceeded {
+				http.Error(w, timeoutMessage, http.StatusServiceUnavailable)
+			}
+		})
+	}
+}
+
+// timeoutWriter turns a server error written after the deadline, usually
+// "context deadline exceeded" from a query, into the timeout answer
+type timeoutWriter struct {
+	statusRecorder
+	ctx      context.Context
+	timedOut bool
+}
+
+func (t *timeoutWriter) WriteHeader(code int) {
+	if t.code == 0 && code >= 500 && t.ctx.Err() == context.DeadlineExceeded {
+		t.timedOut = true
+		h := t.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Type", "text/plain; charset=utf-8")
+		h.Set("X-Content-Type-Options", "nosniff")
+		t.statusRecorder.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintln(t.ResponseWriter, timeoutMessage)
+		return
+	}
+	t.statusRecorder.WriteHeader(code)
+}
+
+func (t *timeoutWriter) Write(b []byte) (int, error) {
+	if t.code == 0 {
+		t.WriteHeader(http.StatusOK)
+	}
+	if t.timedOut {
+		return len(b), nil // the handler's own error text is dropped
+	}
+	return t.statusRecorder.Write(b)
+}
+
+// deadline cancels the request's context after d without buffering the
+// response, for downloads and uploads that may legitimately take longer
+// than a page; the connection's write timeout still ends them
+func deadline(d time.Duration) middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// tlsMode says how HTTPS is served: "" (plain HTTP), "files" for a
+// certificate and key on disk, or "acme" for Let's Encrypt certificates
+func (c serverConfig) tlsMode() string {
+	switch {
+	case c.TLSCert != "":
+		return "files"
+	case c.TLSDomains != "":
+		return "acme"
+	}
+	return ""
+}
+
+// validateTLS checks the HTTPS settings resolve left behind
+func (c *serverConfig) validateTLS() error {
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("config: TLS needs both a certificate and a key")
+	}
+	if c.TLSCert != "" && c.TLSDomains != "" {
+		return fmt.Errorf("config: use either a TLS certificate or TLS domains, not both")
+	}
+	switch c.tlsMode() {
+	case "files":
+		if _, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey); err != nil {
+			return fmt.Errorf("config: TLS certificate: %w", err)
+		}
+	case "acme":
+		for _, d := range c.domains() {
+			if d == "" || strings.ContainsAny(d, "/: ") {
+				return fmt.Errorf("config: invalid TLS domain %q", d)
+			}
+		}
+		if err := os.MkdirAll(c.TLSCacheDir, 0o700); err != nil {
+			return fmt.Errorf("config: TLS cache directory: %w", err)
+		}
+	default:
+		if c.HTTPPort != "" {
+			return fmt.Errorf("config: the HTTP redirect port only applies when serving HTTPS")
+		}
+		return nil
+	}
+	if c.HTTPPort != "" && (!validPort(c.HTTPPort) || c.HTTPPort == c.Port) {
+		return fmt.Errorf("config: HTTP redirect port must be 1-65535 and differ from the HTTPS port, got %q", c.HTTPPort)
+	}
+	return nil
+}
+
+// domains splits TLSDomains
+func (c serverConfig) domains() []string {
+	var out []string
+	for _, d := range strings.Split(c.TLSDomains, ",") {
+		out = append(out, strings.ToLower(strings.TrimSpace(d)))
+	}
+	return out
+}
+
+// prepareTLS sets up srv for HTTPS and returns the plain HTTP redirect
+// server to run beside it, or nil when there is none. Health data should
+// not travel in plaintext, so that server serves nothing but redirects.
+func prepareTLS(srv *http.Server, c serverConfig) *http.Server {
+	var redirect http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if c.Port != "443" {
+			host = net.JoinHostPort(host, c.Port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+
+	if c.tlsMode() == "acme" {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.domains()...),
+			Cache:      autocert.DirCache(c.TLSCacheDir),
+			Email:      c.TLSEmail,
+		}
+		srv.TLSConfig = m.TLSConfig()
+		// HTTP-01 challenges arrive on the redirect port when there is one
+		redirect = m.HTTPHandler(redirect)
+	}
+	if c.HTTPPort == "" {
+		return nil
+	}
+	redirectSrv := &http.Server{Addr: ":" + c.HTTPPort, Handler: redirect}
+	c.applyTimeouts(redirectSrv)
+	return redirectSrv
+}
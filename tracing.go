@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records this app's own spans. Until startTracing runs it is the
+// no-op tracer, so spans cost next to nothing while tracing is off.
+var tracer = otel.Tracer("burnout-detector")
+
+// tracerProvider is set while tracing is on, so shutdown can flush it
+var tracerProvider *sdktrace.TracerProvider
+
+// startTracing sends spans over OTLP/HTTP when a collector is configured,
+// using the standard OpenTelemetry variables:
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT         collector base URL, e.g.
+//	                                    http://otel-collector:4318
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT  the full traces URL, instead
+//	OTEL_EXPORTER_OTLP_HEADERS          key=value pairs, comma-separated
+//	OTEL_SERVICE_NAME                   (burnout-detector)
+//	OTEL_TRACES_SAMPLER_ARG             share of new traces kept (1); a
+//	                                    caller's sampling decision is kept
+//
+// With neither endpoint set tracing stays off.
+func startTracing(ctx context.Context) error {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil
+	}
+	ratio := 1.0
+	if v := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			return fmt.Errorf("tracing: OTEL_TRACES_SAMPLER_ARG must be a number from 0 to 1, got %q", v)
+		}
+		ratio = f
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("burnout-detector")),
+		resource.WithFromEnv(), // OTEL_SERVICE_NAME wins over the default
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return fmt.Errorf("tracing: %w", err)
+	}
+	tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	slog.Info("tracing on", "sample_ratio", ratio)
+	return nil
+}
+
+// stopTracing sends the spans still waiting
+func stopTracing(ctx context.Context) error {
+	if tracerProvider == nil {
+		return nil
+	}
+	return tracerProvider.Shutdown(ctx)
+}
+
+// traceRequests gives every request a server span, continuing the
+// caller's trace when a proxy or client sent a traceparent. The span is
+// named after the route once it is known, and the route replaces the URL
+// path, as share links carry their token in it.
+func traceRequests(next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		route := routeOf(r)
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.URLPath(route), attribute.String("request.id", requestID(r)))
+	})
+	return otelhttp.NewHandler(named, "http")
+}
+
+// tracedTransport records a client span for each outgoing request, such as
+// a backup upload or an export webhook, and passes the trace on
+func tracedTransport() http.RoundTripper {
+	return otelhttp.NewTransport(http.DefaultTransport)
+}
+
+// startChildSpan begins a span for work that is only worth one as part of
+// a trace, such as a single query or a step of a check-in: without a span
+// in ctx it records nothing
+func startChildSpan(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	if !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	return tracer.Start(ctx, name, opts...)
+}
+
+// endSpan records err, if any, on span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// undoWindow is how long a deletion can be taken back
+const undoWindow = time.Minute
+
+// deletedRows is a snapshot of rows taken just before they were deleted,
+// with every column, so they come back with the same ids
+type deletedRows struct {
+	Columns []string
+	Values  [][]any
+}
+
+// undoStore keeps restore tokens in the shared state (see state.go), so a
+// deletion made through one instance can be undone through another. They
+// are meant to cover a misclick and expire after undoWindow.
+type undoStore struct{}
+
+var undos undoStore
+
+// add stores a snapshot and returns its restore token
+func (undoStore) add(rows deletedRows) (string, error) {
+	token, err := newShareToken()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(rows); err != nil {
+		return "", err
+	}
+	return token, state.Put("undo:"+token, buf.Bytes(), undoWindow)
+}
+
+// take removes and returns the snapshot for a token that has not expired
+func (undoStore) take(token string) (deletedRows, bool, error) {
+	var rows deletedRows
+	data, ok, err := state.Take("undo:" + token)
+	if err != nil || !ok {
+		return rows, false, err
+	}
+	err = gob.NewDecoder(bytes.NewReader(data)).Decode(&rows)
+	return rows, err == nil, err
+}
+
+// deleteEntries deletes the entries matching where (all of them when it is
+// empty) and returns a snapshot of what was removed
+func deleteEntries(where string, args ...any) (deletedRows, error) {
+	var snap deletedRows
+	tx, err := db.Begin()
+	if err != nil {
+		return snap, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT * FROM entries`
+	if where != "" {
+		query += ` WHERE ` + where
+	}
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return snap, err
+	}
+	if snap.Columns, err = rows.Columns(); err != nil {
+		rows.Close()
+		return snap, err
+	}
+	for rows.Next() {
+		values := make([]any, len(snap.Columns))
+		ptrs := make([]any, len(values))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			rows.Close()
+			return snap, err
+		}
+		for i, v := range values {
+			// The driver reads DATETIME columns as time.Time and would write
+			// them back in its own layout; keep CURRENT_TIMESTAMP's, which
+			// the import dedupe and string comparisons rely on
+			if t, ok := v.(time.Time); ok {
+				values[i] = t.UTC().Format("2006-01-02 15:04:05")
+			}
+		}
+		snap.Values = append(snap.Values, values)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return snap, err
+	}
+
+	query = `DELETE FROM entries`
+	if where != "" {
+		query += ` WHERE ` + where
+	}
+	if _, err := tx.Exec(query, args...); err != nil {
+		return snap, err
+	}
+	return snap, tx.Commit()
+}
+
+// restoreRows puts a snapshot back into the entries table
+func restoreRows(snap deletedRows) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(snap.Columns)), ", ")
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO entries (%s) VALUES (%s)`, strings.Join(snap.Columns, ", "), placeholders))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, values := range snap.Values {
+		if _, err := stmt.Exec(values...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// undoResponse is the JSON returned by a deletion
+type undoResponse struct {
+	Deleted    int       `json:"deleted"`
+	UndoToken  string    `json:"undo_token"`
+	UndoURL    string    `json:"undo_url"`
+	UndoBefore time.Time `json:"undo_before"`
+}
+
+// respondDeleted stores the snapshot and answers with its restore token:
+// JSON for API clients, an undo toast fragment for HTMX
+func respondDeleted(w http.ResponseWriter, r *http.Request, snap deletedRows) {
+	now := time.Now()
+	token, err := undos.add(snap)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res := undoResponse{Deleted: len(snap.Values), UndoToken: token, UndoURL: "/api/undo/" + token,
+		UndoBefore: now.Add(undoWindow)}
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Trigger", "entryUpdated")
+		renderEntryFragment(w, r, "undo", res)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// handleUndo restores a deletion while its token is still valid. HTMX
+// requests get the restored row back for a single entry, and a page
+// refresh after clearing the history.
+func handleUndo(w http.ResponseWriter, r *http.Request) {
+	rows, ok, err := undos.take(r.PathValue("token"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Nothing to undo: the link has expired or was already used", http.StatusGone)
+		return
+	}
+	if err := restoreRows(rows); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	restored := len(rows.Values)
+	if r.Header.Get("HX-Request") != "true" {
+		writeJSON(w, http.StatusOK, map[string]int{"restored": restored})
+		return
+	}
+	w.Header().Set("HX-Trigger", "entryUpdated")
+	if restored != 1 {
+		w.Header().Set("HX-Refresh", "true")
+		return
+	}
+	var id int64
+	for i, column := range rows.Columns {
+		if column == "id" {
+			id, _ = rows.Values[0][i].(int64)
+		}
+	}
+	entry, err := getEntry(id)
+	if err == sql.ErrNoRows {
+		w.Header().Set("HX-Refresh", "true")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	renderEntryFragment(w, r, "row", entry)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const webhooksSchema = `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		events TEXT NOT NULL,
+		secret TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		last_status TEXT NOT NULL DEFAULT '',
+		last_delivery_at DATETIME
+	);
+`
+
+// Webhook events. A severe check-in is sent as both.
+const (
+	eventCheckin       = "checkin.created"
+	eventSevereCheckin = "checkin.severe"
+)
+
+// webhookEvents lists the events in the order /admin offers them
+var webhookEvents = []string{eventCheckin, eventSevereCheckin}
+
+// webhookAttempts is how often the queue tries a delivery before leaving
+// it dead on /admin
+const webhookAttempts = 5
+
+// Webhook is a URL that is POSTed new check-ins. The secret signs each
+// body; it is kept to sign with but never shown again after it is set.
+type Webhook struct {
+	ID             int64
+	URL            string
+	Events         []string
+	Signed         bool
+	CreatedAt      time.Time
+	LastStatus     string
+	LastDeliveryAt *time.Time
+}
+
+// webhookPayload is the body of a delivery. The journal is left out: it is
+// the user's private note.
+type webhookPayload struct {
+	Event string      `json:"event"`
+	Entry entryRecord `json:"entry"`
+}
+
+// webhookJob is a queued delivery. The body is built when the check-in is
+// made, so a retry sends, and signs, exactly the same bytes.
+type webhookJob struct {
+	WebhookID int64           `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Body      json.RawMessage `json:"body"`
+}
+
+// listWebhooks returns every webhook, oldest first
+func listWebhooks() ([]Webhook, error) {
+	rows, err := db.Query(`SELECT id, url, events, secret != '', created_at, last_status, last_delivery_at FROM webhooks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		var h Webhook
+		var events string
+		var delivered sql.NullTime
+		if err := rows.Scan(&h.ID, &h.URL, &events, &h.Signed, &h.CreatedAt, &h.LastStatus, &delivered); err != nil {
+			return nil, err
+		}
+		if delivered.Valid {
+			h.LastDeliveryAt = &delivered.Time
+		}
+		h.Events = strings.Split(events, ",")
+		hooks = append(hooks, h)
+	}
+	return hooks, rows.Err()
+}
+
+// addWebhook stores a webhook after checking its URL and events
+func addWebhook(rawURL string, events []string, secret string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook: the URL must be http:// or https://")
+	}
+	if len(events) == 0 {
+		return fmt.Errorf("webhook: pick at least one event")
+	}
+	for _, e := range events {
+		if !slices.Contains(webhookEvents, e) {
+			return fmt.Errorf("webhook: unknown event %q", e)
+		}
+	}
+	_, err = db.Exec(`INSERT INTO webhooks (url, events, secret, created_at) VALUES (?, ?, ?, ?)`,
+		u.String(), strings.Join(events, ","), secret, time.Now().UTC())
+	return err
+}
+
+// deleteWebhook removes a webhook, reporting false when there is no such
+// webhook. Deliveries already queued for it are dropped when they run.
+func deleteWebhook(id int64) (bool, error) {
+	res, err := db.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// notifyWebhooks queues a delivery of a new check-in to every webhook that
+// wants it. Call it after the entry is committed, outside any transaction.
+func notifyWebhooks(entry BurnoutEntry) error {
+	events := []string{eventCheckin}
+	if currentScoring().level(entry.Score) == levelSevere {
+		events = append(events, eventSevereCheckin)
+	}
+	hooks, err := listWebhooks()
+	if err != nil {
+		return err
+	}
+	record := newEntryRecord(entry)
+	record.Journal = ""
+	for _, h := range hooks {
+		for _, event := range events {
+			if !slices.Contains(h.Events, event) {
+				continue
+			}
+			body, err := json.Marshal(webhookPayload{Event: event, Entry: record})
+			if err != nil {
+				return err
+			}
+			if _, err := queue.enqueue("webhook", webhookJob{WebhookID: h.ID, Event: event, Body: body}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// webhookTimeout bounds how long a slow receiver holds a queue worker
+const webhookTimeout = 30 * time.Second
+
+// runWebhookJob delivers one queued check-in. Headers follow the scheduled
+// export webhook: X-Burnout-Event names the event, and X-Burnout-Signature
+// is "sha256=" and the hex HMAC-SHA256 of the body under the secret.
+func runWebhookJob(payload json.RawMessage) (any, error) {
+	var job webhookJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return nil, err
+	}
+	var target, secret string
+	err := db.QueryRow(`SELECT url, secret FROM webhooks WHERE id = ?`, job.WebhookID).Scan(&target, &secret)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "webhook deleted", nil
+		}
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", target, bytes.NewReader(job.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Burnout-Event", job.Event)
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(job.Body)
+		req.Header.Set("X-Burnout-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	var status string
+	var runErr error
+	resp, err := (&http.Client{Timeout: webhookTimeout, Transport: tracedTransport()}).Do(req)
+	if err != nil {
+		status, runErr = err.Error(), err
+	} else {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		status = resp.Status
+		if resp.StatusCode >= 300 {
+			runErr = fmt.Errorf("webhook: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		}
+	}
+	if _, err := db.Exec(`UPDATE webhooks SET last_status = ?, last_delivery_at = ? WHERE id = ?`,
+		status, time.Now().UTC(), job.WebhookID); err != nil && runErr == nil {
+		runErr = err
+	}
+	if runErr != nil {
+		return nil, runErr
+	}
+	return status, nil
+}
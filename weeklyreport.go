@@ -0,0 +1,79 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// reportRow is one row (a day or a week) of a report table
+type reportRow struct {
+	Date     string
+	Weekday  string
+	CheckIns int
+	Stats    PeriodStats
+}
+
+// weeklyReportPage is the data behind templates/report_weekly.html
+type weeklyReportPage struct {
+	Summary     WeeklySummary
+	Days        []reportRow
+	Chart       template.HTML
+	TopDriver   string
+	GeneratedAt string
+}
+
+// buildWeeklyReportPage gathers the summary, a per-day breakdown and an
+// inline SVG chart for the week containing day
+func buildWeeklyReportPage(day time.Time, loc localizer) (weeklyReportPage, error) {
+	summary, err := buildWeeklySummary(day)
+	if err != nil {
+		return weeklyReportPage{}, err
+	}
+	start, _ := parseDay(summary.WeekStart)
+	entries, err := queryEntries(start, start.AddDate(0, 0, 7))
+	if err != nil {
+		return weeklyReportPage{}, err
+	}
+
+	page := weeklyReportPage{
+		Summary:     summary,
+		TopDriver:   inputDisplayName(loc, summary.TopDriver),
+		GeneratedAt: loc.DateLong(time.Now()),
+	}
+	chart := chartImage{Width: 680, Height: 220, Title: "Daily average score"}
+	for _, b := range bucketEntries(entries, granularityDay) {
+		page.Days = append(page.Days, reportRow{
+			Date:     loc.Day(b.Start),
+			Weekday:  loc.Format(b.Start, "Monday"),
+			CheckIns: len(b.Entries),
+			Stats:    summarize(b.Entries),
+		})
+		chart.Labels = append(chart.Labels, loc.Weekday(b.Start))
+		chart.Scores = append(chart.Scores, roundTo(averageOf(b.Entries, entryScore), 1))
+	}
+	// The SVG is generated by our own renderer with escaped labels
+	page.Chart = template.HTML(chart.SVG())
+	return page, nil
+}
+
+// handleWeeklyReport renders a print-friendly weekly report (?week=YYYY-MM-DD)
+func handleWeeklyReport(w http.ResponseWriter, r *http.Request) {
+	day, err := parseDateParam(r, "week", time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := buildWeeklyReportPage(day, requestLocalizer(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tmpl, err := loadTemplate("report_weekly.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpl.Execute(w, page)
+}
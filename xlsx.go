@@ -0,0 +1,199 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxSheet is one worksheet of a generated workbook. The first row is
+// rendered bold as a header. If Chart is set the sheet gets a line chart
+// instead of data.
+type xlsxSheet struct {
+	Name  string
+	Rows  [][]any
+	Chart *xlsxChart
+}
+
+// xlsxChart is a line chart whose series reference ranges on another sheet
+type xlsxChart struct {
+	Title      string
+	DataSheet  string
+	Categories string   // e.g. "$A$2:$A$10"
+	Series     []string // value ranges, one per series
+	SeriesName []string // header cell of each series, e.g. "$C$1"
+}
+
+// xlsxColumn converts a zero-based column index to its letters (0 -> A)
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxCell renders one cell; strings are stored inline so no shared
+// string table is needed
+func xlsxCell(ref string, v any, style int) string {
+	s := ""
+	if style > 0 {
+		s = fmt.Sprintf(` s="%d"`, style)
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case int:
+		return fmt.Sprintf(`<c r="%s"%s><v>%d</v></c>`, ref, s, v)
+	case float64:
+		return fmt.Sprintf(`<c r="%s"%s><v>%s</v></c>`, ref, s, strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		b := 0
+		if v {
+			b = 1
+		}
+		return fmt.Sprintf(`<c r="%s"%s t="b"><v>%d</v></c>`, ref, s, b)
+	default:
+		return fmt.Sprintf(`<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`,
+			ref, s, html.EscapeString(xmlSafe(fmt.Sprint(v))))
+	}
+}
+
+// xmlSafe drops control characters that XML 1.0 cannot represent
+func xmlSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sheetXML renders a worksheet's cells, plus a drawing reference for charts
+func (sh xlsxSheet) sheetXML() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	if len(sh.Rows) > 0 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	}
+	b.WriteString(`<sheetData>`)
+	for r, row := range sh.Rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, v := range row {
+			style := 0
+			if r == 0 {
+				style = 1
+			}
+			b.WriteString(xlsxCell(fmt.Sprintf("%s%d", xlsxColumn(c), r+1), v, style))
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData>`)
+	if sh.Chart != nil {
+		b.WriteString(`<drawing r:id="rId1"/>`)
+	}
+	b.WriteString(`</worksheet>`)
+	return b.String()
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// chartXML renders a line chart part
+func (c xlsxChart) chartXML() string {
+	var b strings.Builder
+	b.WriteString(xmlHeader + `<c:chartSpace xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><c:chart>`)
+	fmt.Fprintf(&b, `<c:title><c:tx><c:rich><a:bodyPr/><a:p><a:r><a:t>%s</a:t></a:r></a:p></c:rich></c:tx><c:overlay val="0"/></c:title>`, html.EscapeString(c.Title))
+	b.WriteString(`<c:autoTitleDeleted val="0"/><c:plotArea><c:layout/><c:lineChart><c:grouping val="standard"/><c:varyColors val="0"/>`)
+	// Quoted for the formula, then escaped for the XML around it
+	sheet := html.EscapeString("'" + strings.ReplaceAll(c.DataSheet, "'", "''") + "'")
+	for i, values := range c.Series {
+		fmt.Fprintf(&b, `<c:ser><c:idx val="%d"/><c:order val="%d"/>`, i, i)
+		fmt.Fprintf(&b, `<c:tx><c:strRef><c:f>%s!%s</c:f></c:strRef></c:tx>`, sheet, c.SeriesName[i])
+		b.WriteString(`<c:marker><c:symbol val="circle"/></c:marker>`)
+		fmt.Fprintf(&b, `<c:cat><c:strRef><c:f>%s!%s</c:f></c:strRef></c:cat>`, sheet, c.Categories)
+		fmt.Fprintf(&b, `<c:val><c:numRef><c:f>%s!%s</c:f></c:numRef></c:val><c:smooth val="0"/></c:ser>`, sheet, values)
+	}
+	b.WriteString(`<c:marker val="1"/><c:axId val="1"/><c:axId val="2"/></c:lineChart>`)
+	b.WriteString(`<c:catAx><c:axId val="1"/><c:scaling><c:orientation val="minMax"/></c:scaling><c:delete val="0"/><c:axPos val="b"/><c:tickLblPos val="nextTo"/><c:crossAx val="2"/><c:crosses val="autoZero"/></c:catAx>`)
+	b.WriteString(`<c:valAx><c:axId val="2"/><c:scaling><c:orientation val="minMax"/><c:max val="100"/><c:min val="0"/></c:scaling><c:delete val="0"/><c:axPos val="l"/><c:majorGridlines/><c:numFmt formatCode="General" sourceLinked="0"/><c:tickLblPos val="nextTo"/><c:crossAx val="1"/><c:crosses val="autoZero"/></c:valAx>`)
+	b.WriteString(`</c:plotArea><c:legend><c:legendPos val="b"/><c:overlay val="0"/></c:legend><c:plotVisOnly val="1"/></c:chart></c:chartSpace>`)
+	return b.String()
+}
+
+// drawingXML anchors chart rId1 over roughly A1:P25
+const drawingXML = xmlHeader + `<xdr:wsDr xmlns:xdr="http://schemas.openxmlformats.org/drawingml/2006/spreadsheetDrawing" xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:c="http://schemas.openxmlformats.org/drawingml/2006/chart">` +
+	`<xdr:twoCellAnchor><xdr:from><xdr:col>0</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>0</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:from>` +
+	`<xdr:to><xdr:col>15</xdr:col><xdr:colOff>0</xdr:colOff><xdr:row>25</xdr:row><xdr:rowOff>0</xdr:rowOff></xdr:to>` +
+	`<xdr:graphicFrame macro=""><xdr:nvGraphicFramePr><xdr:cNvPr id="2" name="Chart 1"/><xdr:cNvGraphicFramePr/></xdr:nvGraphicFramePr>` +
+	`<xdr:xfrm><a:off x="0" y="0"/><a:ext cx="0" cy="0"/></xdr:xfrm><a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/chart">` +
+	`<c:chart r:id="rId1"/></a:graphicData></a:graphic></xdr:graphicFrame><xdr:clientData/></xdr:twoCellAnchor></xdr:wsDr>`
+
+// stylesXML defines style 0 (default) and style 1 (bold header)
+const stylesXML = xmlHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+// writeXLSX writes a minimal Office Open XML workbook containing sheets
+func writeXLSX(w io.Writer, sheets []xlsxSheet) error {
+	zw := zip.NewWriter(w)
+	var err error
+	add := func(name, content string) {
+		if err != nil {
+			return
+		}
+		var f io.Writer
+		if f, err = zw.Create(name); err == nil {
+			_, err = io.WriteString(f, content)
+		}
+	}
+
+	var types, workbook, workbookRels strings.Builder
+	types.WriteString(xmlHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xmlHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(xmlHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	charts := 0
+	for i, sh := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, html.EscapeString(sh.Name), n, n)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		add(fmt.Sprintf("xl/worksheets/sheet%d.xml", n), sh.sheetXML())
+
+		if sh.Chart != nil {
+			charts++
+			fmt.Fprintf(&types, `<Override PartName="/xl/drawings/drawing%d.xml" ContentType="application/vnd.openxmlformats-officedocument.drawing+xml"/>`, charts)
+			fmt.Fprintf(&types, `<Override PartName="/xl/charts/chart%d.xml" ContentType="application/vnd.openxmlformats-officedocument.drawingml.chart+xml"/>`, charts)
+			add(fmt.Sprintf("xl/worksheets/_rels/sheet%d.xml.rels", n), fmt.Sprintf(xmlHeader+`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/drawing" Target="../drawings/drawing%d.xml"/></Relationships>`, charts))
+			add(fmt.Sprintf("xl/drawings/drawing%d.xml", charts), drawingXML)
+			add(fmt.Sprintf("xl/drawings/_rels/drawing%d.xml.rels", charts), fmt.Sprintf(xmlHeader+`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/chart" Target="../charts/chart%d.xml"/></Relationships>`, charts))
+			add(fmt.Sprintf("xl/charts/chart%d.xml", charts), sh.Chart.chartXML())
+		}
+	}
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	workbookRels.WriteString(`</Relationships>`)
+
+	add("[Content_Types].xml", types.String())
+	add("_rels/.rels", xmlHeader+`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`)
+	add("xl/workbook.xml", workbook.String())
+	add("xl/_rels/workbook.xml.rels", workbookRels.String())
+	add("xl/styles.xml", stylesXML)
+
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
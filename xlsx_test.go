@@ -0,0 +1,207 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// xlsxFixture is what the test reads back out of a workbook: the parts by
+// name, each already checked to be well-formed XML
+type xlsxFixture map[string][]byte
+
+func openXLSX(t *testing.T, data []byte) xlsxFixture {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip: %v", err)
+	}
+	parts := xlsxFixture{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := xml.NewDecoder(bytes.NewReader(body))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not well-formed XML: %v", f.Name, err)
+			}
+		}
+		parts[f.Name] = body
+	}
+	return parts
+}
+
+func (p xlsxFixture) decode(t *testing.T, name string, v any) {
+	t.Helper()
+	body, ok := p[name]
+	if !ok {
+		t.Fatalf("missing part %s", name)
+	}
+	if err := xml.Unmarshal(body, v); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+// relationships follows a .rels part and checks every target is present
+func (p xlsxFixture) relationships(t *testing.T, rels string) map[string]string {
+	t.Helper()
+	var doc struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	p.decode(t, rels, &doc)
+	// A part's .rels sits in _rels next to it; targets are relative to the part
+	base := path.Dir(path.Dir(rels))
+	targets := map[string]string{}
+	for _, r := range doc.Relationships {
+		target := path.Clean(path.Join(base, r.Target))
+		if _, ok := p[target]; !ok {
+			t.Errorf("%s: %s points at missing %s", rels, r.ID, target)
+		}
+		targets[r.ID] = target
+	}
+	return targets
+}
+
+// xlsxCellXML is a cell as SpreadsheetML stores it
+type xlsxCellXML struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  string `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+// TestWriteXLSX opens a workbook as a zip and reads it back through its
+// content types, relationships and sheet XML
+func TestWriteXLSX(t *testing.T) {
+	wide := []any{}
+	for i := range 28 {
+		wide = append(wide, i)
+	}
+	sheets := []xlsxSheet{
+		{Name: "Entries & notes", Rows: [][]any{
+			{"date", "score", "exercise", "journal", "empty"},
+			{"2026-10-01", 42.5, true, "tired <but> \"fine\" & ok\x07", nil},
+			{"2026-10-02", 7, false, "", nil},
+			wide,
+		}},
+		{Name: "Chart", Chart: &xlsxChart{Title: "Score & stress", DataSheet: "Entries & notes",
+			Categories: "$A$2:$A$3", Series: []string{"$B$2:$B$3"}, SeriesName: []string{"$B$1"}}},
+	}
+	var out bytes.Buffer
+	if err := writeXLSX(&out, sheets); err != nil {
+		t.Fatal(err)
+	}
+	parts := openXLSX(t, out.Bytes())
+
+	// Every part but the content types has a content type
+	var types struct {
+		Defaults []struct {
+			Extension string `xml:"Extension,attr"`
+		} `xml:"Default"`
+		Overrides []struct {
+			PartName string `xml:"PartName,attr"`
+		} `xml:"Override"`
+	}
+	parts.decode(t, "[Content_Types].xml", &types)
+	typed := map[string]bool{}
+	for _, d := range types.Defaults {
+		typed["."+d.Extension] = true
+	}
+	for _, o := range types.Overrides {
+		name := strings.TrimPrefix(o.PartName, "/")
+		if _, ok := parts[name]; !ok {
+			t.Errorf("content type for missing part %s", name)
+		}
+		typed[name] = true
+	}
+	for name := range parts {
+		if name != "[Content_Types].xml" && !typed[name] && !typed[path.Ext(name)] {
+			t.Errorf("%s has no content type", name)
+		}
+	}
+
+	// The package points at the workbook, which names its sheets
+	if root := parts.relationships(t, "_rels/.rels"); root["rId1"] != "xl/workbook.xml" {
+		t.Fatalf("officeDocument is %q", root["rId1"])
+	}
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	parts.decode(t, "xl/workbook.xml", &workbook)
+	sheetParts := parts.relationships(t, "xl/_rels/workbook.xml.rels")
+	if len(workbook.Sheets) != 2 || workbook.Sheets[0].Name != "Entries & notes" || workbook.Sheets[1].Name != "Chart" {
+		t.Fatalf("sheets %+v", workbook.Sheets)
+	}
+
+	// The data sheet holds the rows, with a bold header
+	var data struct {
+		Rows []struct {
+			Ref   string        `xml:"r,attr"`
+			Cells []xlsxCellXML `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	parts.decode(t, sheetParts[workbook.Sheets[0].RID], &data)
+	if len(data.Rows) != 4 {
+		t.Fatalf("%d rows, want 4", len(data.Rows))
+	}
+	want := [][]xlsxCellXML{
+		{{Ref: "A1", Type: "inlineStr", Style: "1", Inline: "date"}, {Ref: "B1", Type: "inlineStr", Style: "1", Inline: "score"},
+			{Ref: "C1", Type: "inlineStr", Style: "1", Inline: "exercise"}, {Ref: "D1", Type: "inlineStr", Style: "1", Inline: "journal"},
+			{Ref: "E1", Type: "inlineStr", Style: "1", Inline: "empty"}},
+		{{Ref: "A2", Type: "inlineStr", Inline: "2026-10-01"}, {Ref: "B2", Value: "42.5"}, {Ref: "C2", Type: "b", Value: "1"},
+			{Ref: "D2", Type: "inlineStr", Inline: `tired <but> "fine" & ok`}},
+		{{Ref: "A3", Type: "inlineStr", Inline: "2026-10-02"}, {Ref: "B3", Value: "7"}, {Ref: "C3", Type: "b", Value: "0"},
+			{Ref: "D3", Type: "inlineStr"}},
+	}
+	for r, cells := range want {
+		if data.Rows[r].Ref != strconv.Itoa(r+1) {
+			t.Errorf("row %d has r=%q", r+1, data.Rows[r].Ref)
+		}
+		if len(data.Rows[r].Cells) != len(cells) {
+			t.Errorf("row %d: %+v, want %+v", r+1, data.Rows[r].Cells, cells)
+			continue
+		}
+		for c, cell := range cells {
+			if data.Rows[r].Cells[c] != cell {
+				t.Errorf("cell %s: %+v, want %+v", cell.Ref, data.Rows[r].Cells[c], cell)
+			}
+		}
+	}
+	last := data.Rows[3].Cells
+	if len(last) != 28 || last[25].Ref != "Z4" || last[26].Ref != "AA4" || last[27].Ref != "AB4" || last[27].Value != "27" {
+		t.Errorf("wide row ends %+v", last[len(last)-3:])
+	}
+
+	// The chart sheet draws a chart over the data sheet
+	drawing := parts.relationships(t, "xl/worksheets/_rels/sheet2.xml.rels")["rId1"]
+	chart := parts.relationships(t, path.Join(path.Dir(drawing), "_rels", path.Base(drawing)+".rels"))["rId1"]
+	var chartSpace struct {
+		Title  string   `xml:"chart>title>tx>rich>p>r>t"`
+		Values []string `xml:"chart>plotArea>lineChart>ser>val>numRef>f"`
+	}
+	parts.decode(t, chart, &chartSpace)
+	if chartSpace.Title != "Score & stress" || len(chartSpace.Values) != 1 || chartSpace.Values[0] != "'Entries & notes'!$B$2:$B$3" {
+		t.Errorf("chart %+v", chartSpace)
+	}
+}